@@ -0,0 +1,131 @@
+package acl
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+)
+
+var (
+	_ IAccessList = &sAccessList{}
+)
+
+type sAccessList struct {
+	fMutex    sync.RWMutex
+	fGroups   map[string]map[string]asymmetric.IPubKey
+	fPolicies map[uint32]*sRoutePolicy
+}
+
+// Policy with the addresses of public keys to check the sender.
+type sRoutePolicy struct {
+	fPolicy  IPolicy
+	fPubKeys map[string]asymmetric.IPubKey
+}
+
+func NewAccessList() IAccessList {
+	return &sAccessList{
+		fGroups:   make(map[string]map[string]asymmetric.IPubKey, 16),
+		fPolicies: make(map[uint32]*sRoutePolicy, 64),
+	}
+}
+
+// Sender is allowed to call the route if the route has not the policy, or
+// the public key of sender is in the policy, or the sender is in the group of policy.
+func (p *sAccessList) IsAllowed(pHead uint32, pSender asymmetric.IPubKey) bool {
+	p.fMutex.RLock()
+	defer p.fMutex.RUnlock()
+
+	routePolicy, ok := p.fPolicies[pHead]
+	if !ok {
+		return true
+	}
+
+	addr := pSender.GetHasher().ToString()
+	if _, ok := routePolicy.fPubKeys[addr]; ok {
+		return true
+	}
+	for _, group := range routePolicy.fPolicy.GetGroups() {
+		if _, ok := p.fGroups[group][addr]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *sAccessList) GetGroup(pName string) ([]asymmetric.IPubKey, bool) {
+	p.fMutex.RLock()
+	defer p.fMutex.RUnlock()
+
+	members, ok := p.fGroups[pName]
+	if !ok {
+		return nil, false
+	}
+
+	pubKeys := make([]asymmetric.IPubKey, 0, len(members))
+	for _, pubKey := range members {
+		pubKeys = append(pubKeys, pubKey)
+	}
+	slices.SortFunc(pubKeys, func(a, b asymmetric.IPubKey) int {
+		return slices.Compare(a.GetHasher().ToBytes(), b.GetHasher().ToBytes())
+	})
+	return pubKeys, true
+}
+
+// Replaces the members of group. The policies use the group by the name,
+// so the change of members is applied to all of them.
+func (p *sAccessList) SetGroup(pName string, pPubKeys []asymmetric.IPubKey) IAccessList {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	p.fGroups[pName] = newPubKeysMap(pPubKeys)
+	return p
+}
+
+// Members of the deleted group lose the access to the routes of group.
+func (p *sAccessList) DelGroup(pName string) IAccessList {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	delete(p.fGroups, pName)
+	return p
+}
+
+func (p *sAccessList) GetPolicy(pHead uint32) (IPolicy, bool) {
+	p.fMutex.RLock()
+	defer p.fMutex.RUnlock()
+
+	routePolicy, ok := p.fPolicies[pHead]
+	if !ok {
+		return nil, false
+	}
+	return routePolicy.fPolicy, true
+}
+
+func (p *sAccessList) SetPolicy(pHead uint32, pPolicy IPolicy) IAccessList {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	p.fPolicies[pHead] = &sRoutePolicy{
+		fPolicy:  pPolicy,
+		fPubKeys: newPubKeysMap(pPolicy.GetPubKeys()),
+	}
+	return p
+}
+
+// Route without the policy is allowed to all friends.
+func (p *sAccessList) DelPolicy(pHead uint32) IAccessList {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	delete(p.fPolicies, pHead)
+	return p
+}
+
+func newPubKeysMap(pPubKeys []asymmetric.IPubKey) map[string]asymmetric.IPubKey {
+	mapping := make(map[string]asymmetric.IPubKey, len(pPubKeys))
+	for _, pubKey := range pPubKeys {
+		mapping[pubKey.GetHasher().ToString()] = pubKey
+	}
+	return mapping
+}
@@ -0,0 +1,80 @@
+package acl
+
+import (
+	"testing"
+
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+)
+
+const (
+	tcHead = 123
+)
+
+func TestAccessList(t *testing.T) {
+	t.Parallel()
+
+	pubKey1 := asymmetric.NewPrivKey().GetPubKey()
+	pubKey2 := asymmetric.NewPrivKey().GetPubKey()
+	pubKey3 := asymmetric.NewPrivKey().GetPubKey()
+
+	accessList := NewAccessList()
+	if !accessList.IsAllowed(tcHead, pubKey1) {
+		t.Error("route without policy is denied")
+		return
+	}
+
+	accessList.
+		SetGroup("admins", []asymmetric.IPubKey{pubKey2}).
+		SetPolicy(tcHead, NewPolicy([]asymmetric.IPubKey{pubKey1}, []string{"admins", "unknown"})).
+		SetPolicy(tcHead+1, NewPolicy(nil, nil))
+
+	if !accessList.IsAllowed(tcHead, pubKey1) {
+		t.Error("public key of policy is denied")
+		return
+	}
+	if !accessList.IsAllowed(tcHead, pubKey2) {
+		t.Error("member of group is denied")
+		return
+	}
+	if accessList.IsAllowed(tcHead, pubKey3) {
+		t.Error("public key outside the policy is allowed")
+		return
+	}
+	if accessList.IsAllowed(tcHead+1, pubKey1) {
+		t.Error("empty policy allows the route")
+		return
+	}
+	if !accessList.IsAllowed(tcHead+2, pubKey3) {
+		t.Error("route without policy is denied")
+		return
+	}
+
+	policy, ok := accessList.GetPolicy(tcHead)
+	if !ok || len(policy.GetPubKeys()) != 1 || len(policy.GetGroups()) != 2 {
+		t.Error("got invalid policy")
+		return
+	}
+
+	// members of group are changed for all the policies
+	accessList.SetGroup("admins", []asymmetric.IPubKey{pubKey3, pubKey3})
+	if accessList.IsAllowed(tcHead, pubKey2) || !accessList.IsAllowed(tcHead, pubKey3) {
+		t.Error("members of group are not changed")
+		return
+	}
+	if members, ok := accessList.GetGroup("admins"); !ok || len(members) != 1 {
+		t.Error("got invalid members of group")
+		return
+	}
+
+	accessList.DelGroup("admins")
+	if _, ok := accessList.GetGroup("admins"); ok || accessList.IsAllowed(tcHead, pubKey3) {
+		t.Error("member of deleted group is allowed")
+		return
+	}
+
+	accessList.DelPolicy(tcHead)
+	if _, ok := accessList.GetPolicy(tcHead); ok || !accessList.IsAllowed(tcHead, pubKey3) {
+		t.Error("route with deleted policy is denied")
+		return
+	}
+}
@@ -0,0 +1,6 @@
+// Package acl allows you to restrict the routes of anonymity node to the friends.
+//
+// The policy of route contains the public keys and the groups of friends
+// allowed to call it. Routes without the policy can be called by all friends.
+// The access list is passed to the node by the HandleAccess(list.IsAllowed).
+package acl
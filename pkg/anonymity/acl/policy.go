@@ -0,0 +1,33 @@
+package acl
+
+import (
+	"slices"
+
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+)
+
+var (
+	_ IPolicy = &sPolicy{}
+)
+
+type sPolicy struct {
+	fPubKeys []asymmetric.IPubKey
+	fGroups  []string
+}
+
+// Route of the policy is allowed to the public keys and to the members of groups.
+// The policy without public keys and groups denies the route to all friends.
+func NewPolicy(pPubKeys []asymmetric.IPubKey, pGroups []string) IPolicy {
+	return &sPolicy{
+		fPubKeys: slices.Clone(pPubKeys),
+		fGroups:  slices.Clone(pGroups),
+	}
+}
+
+func (p *sPolicy) GetPubKeys() []asymmetric.IPubKey {
+	return slices.Clone(p.fPubKeys)
+}
+
+func (p *sPolicy) GetGroups() []string {
+	return slices.Clone(p.fGroups)
+}
@@ -0,0 +1,22 @@
+package acl
+
+import (
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+)
+
+type IAccessList interface {
+	IsAllowed(uint32, asymmetric.IPubKey) bool
+
+	GetGroup(string) ([]asymmetric.IPubKey, bool)
+	SetGroup(string, []asymmetric.IPubKey) IAccessList
+	DelGroup(string) IAccessList
+
+	GetPolicy(uint32) (IPolicy, bool)
+	SetPolicy(uint32, IPolicy) IAccessList
+	DelPolicy(uint32) IAccessList
+}
+
+type IPolicy interface {
+	GetPubKeys() []asymmetric.IPubKey
+	GetGroups() []string
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/number571/go-peer/pkg/message/layer2"
 	"github.com/number571/go-peer/pkg/payload"
 	"github.com/number571/go-peer/pkg/state"
+	"github.com/number571/go-peer/pkg/storage/cache"
 	"github.com/number571/go-peer/pkg/storage/database"
 
 	anon_logger "github.com/number571/go-peer/pkg/anonymity/logger"
@@ -36,7 +37,16 @@ type sNode struct {
 	fQBProcessor   queue.IQBProblemProcessor
 	fMapPubKeys    asymmetric.IMapPubKeys
 	fHandleRoutes  map[uint32]IHandlerF
-	fHandleActions map[string]chan []byte
+	fChainRoutes   map[uint32]IHandlerF
+	fMiddlewares   []IMiddlewareF
+	fRouteMiddles  map[uint32][]IMiddlewareF
+	fHandleActions map[string]chan sResponse
+	fAccessF       IAccessF
+	fResponses     cache.ICache
+	fInFlight      map[string]struct{}
+	fLateActions   cache.ICache
+	fLateHandler   ILateResponseF
+	fFetches       map[string]uint64
 }
 
 func NewNode(
@@ -46,7 +56,7 @@ func NewNode(
 	pKVDatavase database.IKVDatabase,
 	pQBProcessor queue.IQBProblemProcessor,
 ) INode {
-	return &sNode{
+	node := &sNode{
 		fState:         state.NewBoolState(),
 		fSettings:      pSett,
 		fLogger:        pLogger,
@@ -55,8 +65,17 @@ func NewNode(
 		fQBProcessor:   pQBProcessor,
 		fMapPubKeys:    asymmetric.NewMapPubKeys(),
 		fHandleRoutes:  make(map[uint32]IHandlerF, 64),
-		fHandleActions: make(map[string]chan []byte, 64),
+		fChainRoutes:   make(map[uint32]IHandlerF, 64),
+		fRouteMiddles:  make(map[uint32][]IMiddlewareF, 64),
+		fHandleActions: make(map[string]chan sResponse, 64),
+		fInFlight:      make(map[string]struct{}, 64),
+		fLateActions:   cache.NewLRUCache(cLateActionsSize),
+		fFetches:       make(map[string]uint64, 64),
 	}
+	if size := pSett.GetResponseCacheSize(); size != 0 {
+		node.fResponses = cache.NewLRUCache(size)
+	}
+	return node
 }
 
 func (p *sNode) Run(pCtx context.Context) error {
@@ -161,6 +180,52 @@ func (p *sNode) HandleFunc(pHead uint32, pHandle IHandlerF) INode {
 	return p
 }
 
+// Middlewares wrap the handlers of all routes. The first middleware
+// is the outermost and is called before the middlewares of route.
+func (p *sNode) UseMiddleware(pMiddlewares ...IMiddlewareF) INode {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	p.fMiddlewares = append(p.fMiddlewares, pMiddlewares...)
+	for head := range p.fHandleRoutes {
+		p.buildRoute(head)
+	}
+	return p
+}
+
+// Middlewares wrap the handler of one route. They can be
+// added before or after the registration of handler.
+func (p *sNode) UseRouteMiddleware(pHead uint32, pMiddlewares ...IMiddlewareF) INode {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	p.fRouteMiddles[pHead] = append(p.fRouteMiddles[pHead], pMiddlewares...)
+	if _, ok := p.fHandleRoutes[pHead]; ok {
+		p.buildRoute(pHead)
+	}
+	return p
+}
+
+// Requests of the senders denied by the function are not passed to the
+// handlers. All friends can call all the routes if the function is nil.
+func (p *sNode) HandleAccess(pAccess IAccessF) INode {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	p.fAccessF = pAccess
+	return p
+}
+
+// Responses received after the timeout or the cancel of
+// requests are passed to the handler instead of dropping.
+func (p *sNode) HandleLateResponse(pHandle ILateResponseF) INode {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	p.fLateHandler = pHandle
+	return p
+}
+
 // Send message without response waiting.
 func (p *sNode) SendPayload(
 	_ context.Context,
@@ -176,17 +241,38 @@ func (p *sNode) SendPayload(
 }
 
 // Send message with response waiting.
-// Payload head must be uint32.
+// Payload head must be uint32. The request is sent again with
+// the same action if the response is not received (see retry count).
 func (p *sNode) FetchPayload(
 	pCtx context.Context,
 	pRecv asymmetric.IPubKey,
 	pPld payload.IPayload32,
 ) ([]byte, error) {
+	future, err := p.FetchPayloadAsync(pCtx, pRecv, pPld)
+	if err != nil {
+		return nil, err
+	}
+	// future is finished by the context of request
+	<-future.Done()
+	return future.Result()
+}
+
+// Send message without blocking. The response is received by the future.
+// Request is canceled by the context or by the cancel of future.
+func (p *sNode) FetchPayloadAsync(
+	pCtx context.Context,
+	pRecv asymmetric.IPubKey,
+	pPld payload.IPayload32,
+) (IFuture, error) {
+	friendKey := pRecv.GetHasher().ToString()
+	if !p.addFetch(friendKey) {
+		return nil, ErrFetchLimit
+	}
+
 	headAction := sAction(random.NewRandom().GetUint64())
 	actionKey := newActionKey(pRecv, headAction)
 
 	p.setAction(actionKey)
-	defer p.delAction(actionKey)
 
 	newPld := payload.NewPayload64(
 		joinHead(headAction.setType(true), pPld.GetHead()).uint64(),
@@ -195,33 +281,101 @@ func (p *sNode) FetchPayload(
 
 	logBuilder := anon_logger.NewLogBuilder(p.fSettings.GetServiceName())
 	if err := p.enqueuePayload(logBuilder, pRecv, newPld); err != nil {
+		p.delAction(actionKey)
+		p.delFetch(friendKey)
 		// internal logger
 		return nil, errors.Join(ErrEnqueuePayload, err)
 	}
 
-	resp, err := p.recvResponse(pCtx, actionKey)
-	if err != nil {
-		return nil, errors.Join(ErrFetchResponse, err)
+	ctx, cancel := context.WithCancel(pCtx)
+	future := newFuture(headAction.uint31(), cancel)
+
+	go func() {
+		defer cancel()
+
+		resp, err := p.fetchResponse(ctx, pRecv, actionKey, newPld)
+		if err != nil {
+			// response can be received after the timeout or the cancel
+			_ = p.fLateActions.Set([]byte(actionKey), []byte{})
+		}
+
+		p.delAction(actionKey)
+		p.delFetch(friendKey)
+		future.setResult(resp, err)
+	}()
+
+	return future, nil
+}
+
+// Waits for the response. The request is sent again with the same action.
+func (p *sNode) fetchResponse(
+	pCtx context.Context,
+	pRecv asymmetric.IPubKey,
+	pActionKey string,
+	pPld payload.IPayload64,
+) ([]byte, error) {
+	retryCount := p.fSettings.GetRetryCount()
+	retryDelay := p.fSettings.GetRetryBackoff()
+	route := loadHead(pPld.GetHead()).getRoute()
+
+	for i := uint64(0); ; i++ {
+		resp, err := p.recvResponse(pCtx, pActionKey)
+		if err == nil {
+			if resp.isDenied(route) {
+				return nil, errors.Join(ErrFetchResponse, ErrAccessDenied)
+			}
+			if resp.isLimited(route) {
+				return nil, errors.Join(ErrFetchResponse, ErrRequestLimited)
+			}
+			return resp.fBody, nil
+		}
+		if i >= retryCount || !errors.Is(err, ErrActionTimeout) {
+			return nil, errors.Join(ErrFetchResponse, err)
+		}
+
+		if err := waitRetry(pCtx, retryDelay); err != nil {
+			return nil, errors.Join(ErrFetchResponse, err)
+		}
+		retryDelay *= 2
+
+		logBuilder := anon_logger.NewLogBuilder(p.fSettings.GetServiceName())
+		if err := p.enqueuePayload(logBuilder, pRecv, pPld); err != nil {
+			// internal logger
+			return nil, errors.Join(ErrEnqueuePayload, err)
+		}
 	}
+}
 
-	return resp, nil
+func waitRetry(pCtx context.Context, pDelay time.Duration) error {
+	if pDelay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(pDelay)
+	defer timer.Stop()
+
+	select {
+	case <-pCtx.Done():
+		return pCtx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
-func (p *sNode) recvResponse(pCtx context.Context, pActionKey string) ([]byte, error) {
+func (p *sNode) recvResponse(pCtx context.Context, pActionKey string) (sResponse, error) {
 	action, ok := p.getAction(pActionKey)
 	if !ok {
-		return nil, ErrActionIsNotFound
+		return sResponse{}, ErrActionIsNotFound
 	}
 	select {
 	case <-pCtx.Done():
-		return nil, pCtx.Err()
+		return sResponse{}, pCtx.Err()
 	case result, opened := <-action:
 		if !opened {
-			return nil, ErrActionIsClosed
+			return sResponse{}, ErrActionIsClosed
 		}
 		return result, nil
 	case <-time.After(p.fSettings.GetFetchTimeout()):
-		return nil, ErrActionTimeout
+		return sResponse{}, ErrActionTimeout
 	}
 }
 
@@ -293,27 +447,57 @@ func (p *sNode) handleDoAction(
 	}
 
 	// got response message from our side request
-	p.handleResponse(pCtx, pLogBuilder, pSender, action, body)
+	p.handleResponse(pCtx, pLogBuilder, pSender, head, body)
 	return nil
 }
 
 func (p *sNode) handleResponse(
-	_ context.Context,
+	pCtx context.Context,
 	pLogBuilder anon_logger.ILogBuilder,
 	pSender asymmetric.IPubKey,
-	pAction iAction,
+	pHead iHead,
 	pBody []byte,
 ) {
 	// get session by payload head
-	actionKey := newActionKey(pSender, pAction)
+	actionKey := newActionKey(pSender, pHead.getAction())
 	action, ok := p.getAction(actionKey)
 	if !ok {
+		if p.handleLateResponse(pCtx, pLogBuilder, pSender, actionKey, pHead.getAction(), pBody) {
+			return
+		}
 		p.fLogger.PushWarn(pLogBuilder.WithType(anon_logger.CLogBaseGetResponse))
 		return
 	}
 
 	p.fLogger.PushInfo(pLogBuilder.WithType(anon_logger.CLogBaseGetResponse))
-	action <- pBody
+
+	// response can be received again by the retry of request
+	select {
+	case action <- sResponse{fRoute: pHead.getRoute(), fBody: pBody}:
+	default:
+	}
+}
+
+// Passes the response of the timed out or canceled request to the handler.
+func (p *sNode) handleLateResponse(
+	pCtx context.Context,
+	pLogBuilder anon_logger.ILogBuilder,
+	pSender asymmetric.IPubKey,
+	pActionKey string,
+	pAction iAction,
+	pBody []byte,
+) bool {
+	f := p.getLateHandler()
+	if f == nil {
+		return false
+	}
+	if _, ok := p.fLateActions.Get([]byte(pActionKey)); !ok {
+		return false
+	}
+
+	p.fLogger.PushInfo(pLogBuilder.WithType(anon_logger.CLogInfoLateResponse))
+	f(pCtx, p, pSender, pAction.uint31(), pBody)
+	return true
 }
 
 func (p *sNode) handleRequest(
@@ -330,24 +514,133 @@ func (p *sNode) handleRequest(
 		return
 	}
 
+	// access is checked before the replay of saved response
+	if !p.isAllowed(pHead.getRoute(), pSender) {
+		p.fLogger.PushWarn(pLogBuilder.WithType(anon_logger.CLogWarnAccessDenied))
+		p.enqueueNegative(pLogBuilder, pSender, pHead, []byte{})
+		return
+	}
+
+	// repeated request (retry of fetch) gets the saved response,
+	// retry of the request which is handled now is dropped
+	respKey := p.getResponseKey(pSender, pHead)
+	if respKey != nil {
+		if !p.startInFlight(respKey) {
+			p.fLogger.PushInfo(pLogBuilder.WithType(anon_logger.CLogInfoRepeatedRequest))
+			return
+		}
+		defer p.stopInFlight(respKey)
+
+		if saved, ok := p.fResponses.Get(respKey); ok {
+			p.fLogger.PushInfo(pLogBuilder.WithType(anon_logger.CLogInfoRepeatedRequest))
+			if resp, ok := loadSavedResponse(saved); ok {
+				p.enqueueResponse(pLogBuilder, pSender, pHead, resp)
+			}
+			return
+		}
+	}
+
 	// response can be nil
-	resp, err := f(pCtx, p, pSender, pBody)
+	resp, err := p.callHandler(pCtx, f, pSender, pBody)
 	if err != nil {
+		if errors.Is(err, ErrHandlerPanic) {
+			p.fLogger.PushWarn(pLogBuilder.WithType(anon_logger.CLogWarnHandlerPanic))
+			return
+		}
+		if errors.Is(err, ErrRequestLimited) {
+			// request is rejected by the middleware (rate limit)
+			p.fLogger.PushWarn(pLogBuilder.WithType(anon_logger.CLogWarnRequestLimited))
+			p.enqueueNegative(pLogBuilder, pSender, pHead, gLimitedBody)
+			return
+		}
 		p.fLogger.PushWarn(pLogBuilder.WithType(anon_logger.CLogWarnIncorrectResponse))
 		return
 	}
+	if respKey != nil {
+		_ = p.fResponses.Set(respKey, newSavedResponse(resp))
+	}
 	if resp == nil {
 		p.fLogger.PushInfo(pLogBuilder.WithType(anon_logger.CLogInfoWithoutResponse))
 		return
 	}
 
+	p.enqueueResponse(pLogBuilder, pSender, pHead, resp)
+}
+
+// Negative response is sent only to the requests (not to the broadcasts).
+// The route of response is inverted and the body is the reason of rejection.
+func (p *sNode) enqueueNegative(
+	pLogBuilder anon_logger.ILogBuilder,
+	pSender asymmetric.IPubKey,
+	pHead iHead,
+	pBody []byte,
+) {
+	if !p.fSettings.GetDeniedResponse() || pHead.getAction().uint31() == 0 {
+		return
+	}
+	negativeHead := joinHead(pHead.getAction(), ^pHead.getRoute())
+	p.enqueueResponse(pLogBuilder, pSender, negativeHead, pBody)
+}
+
+// Panic of the handler does not stop the consumer of messages.
+func (p *sNode) callHandler(
+	pCtx context.Context,
+	pHandle IHandlerF,
+	pSender asymmetric.IPubKey,
+	pBody []byte,
+) (resp []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			resp, err = nil, ErrHandlerPanic
+		}
+	}()
+	return pHandle(pCtx, p, pSender, pBody)
+}
+
+// Marks the request as handled now. Returns false if the request
+// is already handled (the handler is not called twice by the retries).
+func (p *sNode) startInFlight(pRespKey []byte) bool {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	key := string(pRespKey)
+	if _, ok := p.fInFlight[key]; ok {
+		return false
+	}
+	p.fInFlight[key] = struct{}{}
+	return true
+}
+
+// Is called after the response of handler is saved to the cache.
+func (p *sNode) stopInFlight(pRespKey []byte) {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	delete(p.fInFlight, string(pRespKey))
+}
+
+// Returns nil if the responses are not saved or the request has not the action.
+func (p *sNode) getResponseKey(pSender asymmetric.IPubKey, pHead iHead) []byte {
+	action := pHead.getAction()
+	if p.fResponses == nil || action.uint31() == 0 {
+		return nil
+	}
+	return []byte(fmt.Sprintf("%s-%d", newActionKey(pSender, action), pHead.getRoute()))
+}
+
+func (p *sNode) enqueueResponse(
+	pLogBuilder anon_logger.ILogBuilder,
+	pSender asymmetric.IPubKey,
+	pHead iHead,
+	pResp []byte,
+) {
 	// create response and put this to the queue
 	// internal logger
 	newHead := joinHead(pHead.getAction().setType(false), pHead.getRoute()).uint64()
 	_ = p.enqueuePayload(
 		pLogBuilder,
 		pSender,
-		payload.NewPayload64(newHead, resp),
+		payload.NewPayload64(newHead, pResp),
 	)
 }
 
@@ -444,17 +737,76 @@ func (p *sNode) setRoute(pHead uint32, pHandle IHandlerF) {
 	defer p.fMutex.Unlock()
 
 	p.fHandleRoutes[pHead] = pHandle
+	p.buildRoute(pHead)
 }
 
+// Returns the handler wrapped by the middlewares of node and route.
 func (p *sNode) getRoute(pHead uint32) (IHandlerF, bool) {
 	p.fMutex.RLock()
 	defer p.fMutex.RUnlock()
 
-	f, ok := p.fHandleRoutes[pHead]
+	f, ok := p.fChainRoutes[pHead]
 	return f, ok
 }
 
-func (p *sNode) getAction(pActionKey string) (chan []byte, bool) {
+// Wraps the handler by the middlewares once, when the handler
+// or the middlewares are set (not for each request).
+func (p *sNode) buildRoute(pHead uint32) {
+	f := p.fHandleRoutes[pHead]
+	if f == nil {
+		p.fChainRoutes[pHead] = nil
+		return
+	}
+
+	routeMiddles := p.fRouteMiddles[pHead]
+	for i := len(routeMiddles) - 1; i >= 0; i-- {
+		f = routeMiddles[i](pHead, f)
+	}
+	for i := len(p.fMiddlewares) - 1; i >= 0; i-- {
+		f = p.fMiddlewares[i](pHead, f)
+	}
+	p.fChainRoutes[pHead] = f
+}
+
+func (p *sNode) isAllowed(pHead uint32, pSender asymmetric.IPubKey) bool {
+	p.fMutex.RLock()
+	f := p.fAccessF
+	p.fMutex.RUnlock()
+
+	return f == nil || f(pHead, pSender)
+}
+
+func (p *sNode) getLateHandler() ILateResponseF {
+	p.fMutex.RLock()
+	defer p.fMutex.RUnlock()
+
+	return p.fLateHandler
+}
+
+// Increments the count of requests of friend if it is less than the limit.
+func (p *sNode) addFetch(pFriendKey string) bool {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	limit := p.fSettings.GetMaxFetchesPerFriend()
+	if limit != 0 && p.fFetches[pFriendKey] >= limit {
+		return false
+	}
+	p.fFetches[pFriendKey]++
+	return true
+}
+
+func (p *sNode) delFetch(pFriendKey string) {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	p.fFetches[pFriendKey]--
+	if p.fFetches[pFriendKey] == 0 {
+		delete(p.fFetches, pFriendKey)
+	}
+}
+
+func (p *sNode) getAction(pActionKey string) (chan sResponse, bool) {
 	p.fMutex.RLock()
 	defer p.fMutex.RUnlock()
 
@@ -466,7 +818,8 @@ func (p *sNode) setAction(pActionKey string) {
 	p.fMutex.Lock()
 	defer p.fMutex.Unlock()
 
-	p.fHandleActions[pActionKey] = make(chan []byte)
+	// response is not lost if it is received between the retries
+	p.fHandleActions[pActionKey] = make(chan sResponse, 1)
 }
 
 func (p *sNode) delAction(pActionKey string) {
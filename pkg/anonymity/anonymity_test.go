@@ -81,7 +81,7 @@ func TestNodeSettings(t *testing.T) {
 func TestSettings(t *testing.T) {
 	t.Parallel()
 
-	for i := 0; i < 1; i++ {
+	for i := 0; i < 2; i++ {
 		testSettings(t, i)
 	}
 }
@@ -93,9 +93,14 @@ func testSettings(t *testing.T, n int) {
 			return
 		}
 	}()
-	switch n { // nolint: gocritic
+	switch n {
 	case 0:
 		_ = NewSettings(&SSettings{})
+	case 1:
+		_ = NewSettings(&SSettings{
+			FFetchTimeout: time.Minute,
+			FRetryCount:   1,
+		})
 	}
 }
 
@@ -459,6 +464,481 @@ func TestHandleWrapper(t *testing.T) {
 	}
 }
 
+func TestFetchRetry(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, err := database.NewKVDatabase(fmt.Sprintf(tcPathDBTemplate, 10, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	fetchTimeout := 100 * time.Millisecond
+	sett := NewSettings(&SSettings{
+		FServiceName:       "TEST",
+		FFetchTimeout:      fetchTimeout,
+		FRetryCount:        2,
+		FRetryBackoff:      10 * time.Millisecond,
+		FResponseCacheSize: 16,
+	})
+
+	_node, _ := testRunNodeWithSettings(ctx, sett, time.Minute, "", db)
+	defer testFreeNodes([]INode{_node}, 10)
+
+	node := _node.(*sNode)
+	pubKey := node.fQBProcessor.GetClient().GetPrivKey().GetPubKey()
+
+	start := time.Now()
+	_, err1 := node.FetchPayload(ctx, pubKey, payload.NewPayload32(tcHead, []byte(tcMsgBody)))
+	if !errors.Is(err1, ErrActionTimeout) {
+		t.Error("success fetch payload without response")
+		return
+	}
+	if time.Since(start) < 3*fetchTimeout+30*time.Millisecond {
+		t.Error("request is not sent again after the timeout")
+		return
+	}
+
+	go func() {
+		// response is received by the second try
+		time.Sleep(fetchTimeout + fetchTimeout/2)
+
+		node.fMutex.RLock()
+		defer node.fMutex.RUnlock()
+
+		for _, action := range node.fHandleActions {
+			action <- sResponse{fRoute: tcHead, fBody: []byte("response")}
+		}
+	}()
+
+	resp, err2 := node.FetchPayload(ctx, pubKey, payload.NewPayload32(tcHead, []byte(tcMsgBody)))
+	if err2 != nil {
+		t.Error(err2)
+		return
+	}
+	if string(resp) != "response" {
+		t.Error("got invalid response")
+		return
+	}
+}
+
+func TestRepeatedRequest(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, err := database.NewKVDatabase(fmt.Sprintf(tcPathDBTemplate, 11, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sett := NewSettings(&SSettings{
+		FServiceName:       "TEST",
+		FFetchTimeout:      time.Minute,
+		FResponseCacheSize: 16,
+	})
+
+	_node, _ := testRunNodeWithSettings(ctx, sett, time.Minute, "", db)
+	defer testFreeNodes([]INode{_node}, 11)
+
+	node := _node.(*sNode)
+	client := node.fQBProcessor.GetClient()
+	pubKey := client.GetPrivKey().GetPubKey()
+	node.GetMapPubKeys().SetPubKey(pubKey)
+
+	handleCount := 0
+	node.HandleFunc(
+		111,
+		func(_ context.Context, _ INode, _ asymmetric.IPubKey, _ []byte) ([]byte, error) {
+			handleCount++
+			return []byte("response"), nil
+		},
+	)
+
+	// request without response is saved too
+	handleNilCount := 0
+	node.HandleFunc(
+		112,
+		func(_ context.Context, _ INode, _ asymmetric.IPubKey, _ []byte) ([]byte, error) {
+			handleNilCount++
+			return nil, nil
+		},
+	)
+
+	msgSett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: layer1.NewSettings(&layer1.SSettings{}),
+	})
+
+	// each encryption of request is the new message with the same action
+	for _, action := range []sAction{1, 1, 2} {
+		msg, err := client.EncryptMessage(
+			pubKey,
+			payload.NewPayload64(
+				joinHead(action.setType(true), 111).uint64(),
+				[]byte(tcMsgBody),
+			).ToBytes(),
+		)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := node.consumeMessage(ctx, node.testNewNetworkMessage(msgSett, msg)); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if handleCount != 2 {
+		t.Errorf("repeated request is handled again (%d)", handleCount)
+		return
+	}
+
+	for _, action := range []sAction{3, 3} {
+		msg, err := client.EncryptMessage(
+			pubKey,
+			payload.NewPayload64(
+				joinHead(action.setType(true), 112).uint64(),
+				[]byte(tcMsgBody),
+			).ToBytes(),
+		)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := node.consumeMessage(ctx, node.testNewNetworkMessage(msgSett, msg)); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if handleNilCount != 1 {
+		t.Errorf("repeated request without response is handled again (%d)", handleNilCount)
+		return
+	}
+
+	// retry of request which is handled now does not call the handler again
+	handleSlowCount := 0
+	chStarted := make(chan struct{})
+	chRelease := make(chan struct{})
+	node.HandleFunc(
+		113,
+		func(_ context.Context, _ INode, _ asymmetric.IPubKey, _ []byte) ([]byte, error) {
+			handleSlowCount++
+			close(chStarted)
+			<-chRelease
+			return []byte("response"), nil
+		},
+	)
+
+	slowHead := joinHead(sAction(4).setType(true), 113)
+	chDone := make(chan struct{})
+	go func() {
+		defer close(chDone)
+		node.handleRequest(ctx, anon_logger.NewLogBuilder("TEST"), pubKey, slowHead, []byte(tcMsgBody))
+	}()
+	<-chStarted
+
+	logBuilder := anon_logger.NewLogBuilder("TEST")
+	node.handleRequest(ctx, logBuilder, pubKey, slowHead, []byte(tcMsgBody))
+	if logBuilder.Build().GetType() != anon_logger.CLogInfoRepeatedRequest {
+		t.Error("retry of request in flight is not dropped")
+		return
+	}
+
+	close(chRelease)
+	<-chDone
+
+	// next retry gets the saved response
+	node.handleRequest(ctx, anon_logger.NewLogBuilder("TEST"), pubKey, slowHead, []byte(tcMsgBody))
+
+	if handleSlowCount != 1 {
+		t.Errorf("retry of request in flight is handled again (%d)", handleSlowCount)
+		return
+	}
+}
+
+func TestFetchPayloadAsync(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, err := database.NewKVDatabase(fmt.Sprintf(tcPathDBTemplate, 12, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sett := NewSettings(&SSettings{
+		FServiceName:         "TEST",
+		FFetchTimeout:        time.Minute,
+		FMaxFetchesPerFriend: 1,
+	})
+
+	_node, _ := testRunNodeWithSettings(ctx, sett, time.Minute, "", db)
+	defer testFreeNodes([]INode{_node}, 12)
+
+	node := _node.(*sNode)
+	pubKey := node.fQBProcessor.GetClient().GetPrivKey().GetPubKey()
+
+	chLate := make(chan []byte, 1)
+	node.HandleLateResponse(func(_ context.Context, _ INode, _ asymmetric.IPubKey, _ uint32, pBody []byte) {
+		chLate <- pBody
+	})
+
+	future, err := node.FetchPayloadAsync(ctx, pubKey, payload.NewPayload32(tcHead, []byte(tcMsgBody)))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := future.Result(); !errors.Is(err, ErrFutureIsPending) {
+		t.Error("got result of pending future")
+		return
+	}
+
+	if _, err := node.FetchPayloadAsync(ctx, pubKey, payload.NewPayload32(tcHead, []byte(tcMsgBody))); !errors.Is(err, ErrFetchLimit) {
+		t.Error("success fetch payload over the limit of friend")
+		return
+	}
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer waitCancel()
+	if _, err := future.Wait(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("got result without response")
+		return
+	}
+
+	future.Cancel()
+	if _, err := future.Wait(ctx); !errors.Is(err, ErrFetchResponse) {
+		t.Error("success result of canceled future")
+		return
+	}
+
+	// response of the canceled request is passed to the handler
+	logBuilder := anon_logger.NewLogBuilder("TEST")
+	node.handleResponse(ctx, logBuilder, pubKey, joinHead(sAction(future.GetAction()).setType(false), tcHead), []byte("late"))
+	select {
+	case body := <-chLate:
+		if string(body) != "late" {
+			t.Error("got invalid late response")
+			return
+		}
+	case <-time.After(time.Second):
+		t.Error("late response is not handled")
+		return
+	}
+
+	// limit of friend is released by the finished request
+	future2, err := node.FetchPayloadAsync(ctx, pubKey, payload.NewPayload32(tcHead, []byte(tcMsgBody)))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	go func() {
+		node.fMutex.RLock()
+		defer node.fMutex.RUnlock()
+
+		for _, action := range node.fHandleActions {
+			action <- sResponse{fRoute: tcHead, fBody: []byte("response")}
+		}
+	}()
+	resp, err := future2.Wait(ctx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(resp) != "response" {
+		t.Error("got invalid response")
+		return
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, err := database.NewKVDatabase(fmt.Sprintf(tcPathDBTemplate, 13, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sett := NewSettings(&SSettings{
+		FServiceName:  "TEST",
+		FFetchTimeout: time.Minute,
+	})
+
+	_node, _ := testRunNodeWithSettings(ctx, sett, time.Minute, "", db)
+	defer testFreeNodes([]INode{_node}, 13)
+
+	node := _node.(*sNode)
+	pubKey := node.fQBProcessor.GetClient().GetPrivKey().GetPubKey()
+
+	calls := make([]string, 0, 8)
+	wraps := 0
+	newMiddleware := func(pName string) IMiddlewareF {
+		return func(pHead uint32, pNext IHandlerF) IHandlerF {
+			wraps++
+			return func(pCtx context.Context, pNode INode, pSender asymmetric.IPubKey, pBody []byte) ([]byte, error) {
+				calls = append(calls, fmt.Sprintf("%s-%d", pName, pHead))
+				return pNext(pCtx, pNode, pSender, pBody)
+			}
+		}
+	}
+
+	// middlewares of route can be added before the handler
+	node.UseRouteMiddleware(tcHead, newMiddleware("route1"), newMiddleware("route2"))
+	node.HandleFunc(tcHead, func(_ context.Context, _ INode, _ asymmetric.IPubKey, pBody []byte) ([]byte, error) {
+		calls = append(calls, "handler")
+		return pBody, nil
+	})
+	node.HandleFunc(tcHead+1, func(_ context.Context, _ INode, _ asymmetric.IPubKey, _ []byte) ([]byte, error) {
+		panic("handler panic")
+	})
+	node.UseMiddleware(newMiddleware("global1"), newMiddleware("global2"))
+
+	f, ok := node.getRoute(tcHead)
+	if !ok {
+		t.Error("route is not found")
+		return
+	}
+	if _, err := f(ctx, node, pubKey, []byte(tcMsgBody)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := fmt.Sprintf("global1-%[1]d global2-%[1]d route1-%[1]d route2-%[1]d handler", tcHead)
+	if got := fmt.Sprint(calls); got != "["+expected+"]" {
+		t.Errorf("invalid order of middlewares: %s", got)
+		return
+	}
+
+	// chain of middlewares is not rebuilt for each request
+	wrapsBefore := wraps
+	for i := 0; i < 3; i++ {
+		f, _ := node.getRoute(tcHead)
+		if _, err := f(ctx, node, pubKey, []byte(tcMsgBody)); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if wraps != wrapsBefore {
+		t.Error("chain of middlewares is rebuilt by the request")
+		return
+	}
+
+	// panic of handler does not stop the consumer
+	logBuilder := anon_logger.NewLogBuilder("TEST")
+	node.handleRequest(ctx, logBuilder, pubKey, joinHead(sAction(1).setType(true), tcHead+1), []byte(tcMsgBody))
+	if logBuilder.Build().GetType() != anon_logger.CLogWarnHandlerPanic {
+		t.Error("panic of handler is not logged")
+		return
+	}
+	if _, err := node.callHandler(ctx, f, pubKey, []byte(tcMsgBody)); err != nil {
+		t.Error(err)
+		return
+	}
+}
+
+func TestAccessDenied(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, err := database.NewKVDatabase(fmt.Sprintf(tcPathDBTemplate, 14, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sett := NewSettings(&SSettings{
+		FServiceName:    "TEST",
+		FFetchTimeout:   time.Minute,
+		FDeniedResponse: true,
+	})
+
+	_node, _ := testRunNodeWithSettings(ctx, sett, time.Minute, "", db)
+	defer testFreeNodes([]INode{_node}, 14)
+
+	node := _node.(*sNode)
+	pubKey := node.fQBProcessor.GetClient().GetPrivKey().GetPubKey()
+
+	handleCount := 0
+	node.HandleFunc(tcHead, func(_ context.Context, _ INode, _ asymmetric.IPubKey, pBody []byte) ([]byte, error) {
+		handleCount++
+		return pBody, nil
+	})
+	node.HandleAccess(func(pHead uint32, _ asymmetric.IPubKey) bool {
+		return pHead != tcHead
+	})
+
+	logBuilder := anon_logger.NewLogBuilder("TEST")
+	node.handleRequest(ctx, logBuilder, pubKey, joinHead(sAction(1).setType(true), tcHead), []byte(tcMsgBody))
+	if handleCount != 0 {
+		t.Error("denied request is handled")
+		return
+	}
+	if logBuilder.Build().GetType() != anon_logger.CLogBaseEnqueueResponse {
+		t.Error("negative response is not enqueued")
+		return
+	}
+
+	// negative response fails the fetch without waiting
+	future, err := node.FetchPayloadAsync(ctx, pubKey, payload.NewPayload32(tcHead, []byte(tcMsgBody)))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	deniedHead := joinHead(sAction(future.GetAction()).setType(false), ^uint32(tcHead))
+	node.handleResponse(ctx, logBuilder, pubKey, deniedHead, []byte{})
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, time.Second)
+	defer waitCancel()
+	if _, err := future.Wait(waitCtx); !errors.Is(err, ErrAccessDenied) {
+		t.Error("fetch is not failed by the negative response")
+		return
+	}
+
+	node.HandleAccess(nil)
+	node.handleRequest(ctx, logBuilder, pubKey, joinHead(sAction(2).setType(true), tcHead), []byte(tcMsgBody))
+	if handleCount != 1 {
+		t.Error("allowed request is not handled")
+		return
+	}
+
+	// request rejected by the middleware gets the limited response
+	node.HandleFunc(tcHead+1, func(_ context.Context, _ INode, _ asymmetric.IPubKey, _ []byte) ([]byte, error) {
+		return nil, ErrRequestLimited
+	})
+	limitedBuilder := anon_logger.NewLogBuilder("TEST")
+	node.handleRequest(ctx, limitedBuilder, pubKey, joinHead(sAction(3).setType(true), tcHead+1), []byte(tcMsgBody))
+	if limitedBuilder.Build().GetType() != anon_logger.CLogBaseEnqueueResponse {
+		t.Error("limited response is not enqueued")
+		return
+	}
+
+	future2, err := node.FetchPayloadAsync(ctx, pubKey, payload.NewPayload32(tcHead+1, []byte(tcMsgBody)))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	limitedHead := joinHead(sAction(future2.GetAction()).setType(false), ^uint32(tcHead+1))
+	node.handleResponse(ctx, logBuilder, pubKey, limitedHead, gLimitedBody)
+
+	if _, err := future2.Wait(waitCtx); !errors.Is(err, ErrRequestLimited) {
+		t.Error("fetch is not failed by the limited response")
+		return
+	}
+}
+
 func TestStoreHashWithBroadcastMessage(t *testing.T) {
 	t.Parallel()
 
@@ -608,12 +1088,12 @@ func testRunNodes(ctx context.Context, t *testing.T, timeWait time.Duration, add
 	}
 
 	go func() {
-		if err := networkNodes[2].Run(ctx); err != nil && !errors.Is(err, net.ErrClosed) {
+		if err := networkNodes[2].Run(ctx); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, net.ErrClosed) {
 			t.Error(err)
 		}
 	}()
 	go func() {
-		if err := networkNodes[4].Run(ctx); err != nil && !errors.Is(err, net.ErrClosed) {
+		if err := networkNodes[4].Run(ctx); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, net.ErrClosed) {
 			t.Error(err)
 		}
 	}()
@@ -675,6 +1155,14 @@ func (p *stLogging) HasErro() bool {
 */
 
 func testRunNodeWithDB(ctx context.Context, timeWait time.Duration, addr string, db database.IKVDatabase) (INode, network.INode) {
+	sett := NewSettings(&SSettings{
+		FServiceName:  "TEST",
+		FFetchTimeout: timeWait,
+	})
+	return testRunNodeWithSettings(ctx, sett, timeWait, addr, db)
+}
+
+func testRunNodeWithSettings(ctx context.Context, sett ISettings, timeWait time.Duration, addr string, db database.IKVDatabase) (INode, network.INode) {
 	msgChan := make(chan layer1.IMessage)
 	parallel := uint64(1)
 	networkMask := uint32(1)
@@ -702,10 +1190,7 @@ func testRunNodeWithDB(ctx context.Context, timeWait time.Duration, addr string,
 		return nil
 	})
 	node := NewNode(
-		NewSettings(&SSettings{
-			FServiceName:  "TEST",
-			FFetchTimeout: timeWait,
-		}),
+		sett,
 		// internal_std_logger.NewStdLogger(&stLogging{}, internal_anon_logger.GetLogFunc()),
 		logger.NewLogger(
 			logger.NewSettings(&logger.SSettings{}),
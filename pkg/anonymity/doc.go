@@ -2,4 +2,9 @@
 //
 // The package basically uses the fifth^ stage of anonymity with a queue-based problem.
 // All applied connections use friend-to-friend (F2F) communications.
+// The list of friends can be persisted by the friends.IFriendStore
+// synchronized with the map of public keys (GetMapPubKeys).
+// Data larger than one message can be sent by the chunks of stream.IStreamer.
+// Handlers of routes can be wrapped by the middlewares (UseMiddleware, UseRouteMiddleware)
+// and restricted to the friends by the access list of acl.IAccessList (HandleAccess).
 package anonymity
@@ -30,4 +30,9 @@ var (
 	ErrRunning               = &SAnonymityError{"node running"}
 	ErrProcessRun            = &SAnonymityError{"process run"}
 	ErrHashAlreadyExist      = &SAnonymityError{"hash already exist"}
+	ErrFetchLimit            = &SAnonymityError{"fetch limit of friend"}
+	ErrFutureIsPending       = &SAnonymityError{"future is pending"}
+	ErrHandlerPanic          = &SAnonymityError{"handler panic"}
+	ErrAccessDenied          = &SAnonymityError{"access denied"}
+	ErrRequestLimited        = &SAnonymityError{"request limited"}
 )
@@ -0,0 +1,6 @@
+// Package friends allows you to store the list of friends (F2F) of the anonymity node.
+//
+// Each friend has the alias, the public key, the time of adding and the metadata.
+// The list is persisted into the key-value database, so it survives restarts,
+// and the public keys are synchronized with the map of the node (GetMapPubKeys).
+package friends
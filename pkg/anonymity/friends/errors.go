@@ -0,0 +1,24 @@
+package friends
+
+const (
+	errPrefix = "pkg/anonymity/friends = "
+)
+
+type SFriendsError struct {
+	str string
+}
+
+func (err *SFriendsError) Error() string {
+	return errPrefix + err.str
+}
+
+var (
+	ErrLoadFriends      = &SFriendsError{"load friends"}
+	ErrSaveFriends      = &SFriendsError{"save friends"}
+	ErrDecodeFriends    = &SFriendsError{"decode friends"}
+	ErrInvalidAlias     = &SFriendsError{"invalid alias"}
+	ErrFriendIsExist    = &SFriendsError{"friend is exist"}
+	ErrFriendIsNotExist = &SFriendsError{"friend is not exist"}
+	ErrPubKeyIsExist    = &SFriendsError{"public key is exist"}
+	ErrInvalidPubKey    = &SFriendsError{"invalid public key"}
+)
@@ -0,0 +1,263 @@
+package friends
+
+import (
+	"errors"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+	"github.com/number571/go-peer/pkg/encoding"
+	"github.com/number571/go-peer/pkg/storage/database"
+)
+
+const (
+	cMaxAliasSize = 255
+)
+
+var (
+	gFriendsKey = []byte("__pkg/anonymity/friends__")
+)
+
+var (
+	_ IFriendStore = &sFriendStore{}
+	_ IFriend      = &sFriend{}
+)
+
+type sFriendStore struct {
+	fMutex      sync.RWMutex
+	fKVDB       database.IKVDatabase
+	fMapPubKeys asymmetric.IMapPubKeys
+	fFriends    map[string]*sFriend
+}
+
+type sFriend struct {
+	FAlias    string            `json:"alias"`
+	FPubKey   string            `json:"pub_key"`
+	FAddedAt  time.Time         `json:"added_at"`
+	FMetadata map[string]string `json:"metadata,omitempty"`
+
+	fPubKey asymmetric.IPubKey
+}
+
+// Creates the store of friends and loads the saved friends from the database.
+// Public keys of the friends are added to the map (usually node.GetMapPubKeys()).
+func NewFriendStore(pKVDB database.IKVDatabase, pMapPubKeys asymmetric.IMapPubKeys) (IFriendStore, error) {
+	friendStore := &sFriendStore{
+		fKVDB:       pKVDB,
+		fMapPubKeys: pMapPubKeys,
+		fFriends:    make(map[string]*sFriend, 64),
+	}
+
+	data, err := pKVDB.Get(gFriendsKey)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return friendStore, nil
+		}
+		return nil, errors.Join(ErrLoadFriends, err)
+	}
+
+	var friends []*sFriend
+	if err := encoding.DeserializeJSON(data, &friends); err != nil {
+		return nil, errors.Join(ErrDecodeFriends, err)
+	}
+
+	for _, friend := range friends {
+		friend.fPubKey = asymmetric.LoadPubKey(friend.FPubKey)
+		if friend.fPubKey == nil {
+			return nil, ErrDecodeFriends
+		}
+		friendStore.fFriends[friend.FAlias] = friend
+	}
+
+	// map is changed only after the full loading of friends
+	for _, friend := range friendStore.fFriends {
+		pMapPubKeys.SetPubKey(friend.fPubKey)
+	}
+
+	return friendStore, nil
+}
+
+// Returns the map of public keys synchronized with the friends.
+func (p *sFriendStore) GetMapPubKeys() asymmetric.IMapPubKeys {
+	return p.fMapPubKeys
+}
+
+// Returns the friends ordered by the aliases.
+func (p *sFriendStore) GetFriends() []IFriend {
+	p.fMutex.RLock()
+	defer p.fMutex.RUnlock()
+
+	friends := p.sortedFriends()
+	result := make([]IFriend, 0, len(friends))
+	for _, friend := range friends {
+		result = append(result, friend.copy())
+	}
+	return result
+}
+
+func (p *sFriendStore) GetFriend(pAlias string) (IFriend, bool) {
+	p.fMutex.RLock()
+	defer p.fMutex.RUnlock()
+
+	friend, ok := p.fFriends[pAlias]
+	if !ok {
+		return nil, false
+	}
+	return friend.copy(), true
+}
+
+// Adds the new friend. The alias and the public key must be unique.
+func (p *sFriendStore) AddFriend(pAlias string, pPubKey asymmetric.IPubKey) error {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	if pAlias == "" || len(pAlias) > cMaxAliasSize {
+		return ErrInvalidAlias
+	}
+	if pPubKey == nil {
+		return ErrInvalidPubKey
+	}
+	if _, ok := p.fFriends[pAlias]; ok {
+		return ErrFriendIsExist
+	}
+	if p.getAliasByPubKey(pPubKey) != "" {
+		return ErrPubKeyIsExist
+	}
+
+	p.fFriends[pAlias] = &sFriend{
+		FAlias:   pAlias,
+		FPubKey:  pPubKey.ToString(),
+		FAddedAt: time.Now(),
+		fPubKey:  pPubKey,
+	}
+	if err := p.save(); err != nil {
+		delete(p.fFriends, pAlias)
+		return err
+	}
+
+	p.fMapPubKeys.SetPubKey(pPubKey)
+	return nil
+}
+
+func (p *sFriendStore) DelFriend(pAlias string) error {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	friend, ok := p.fFriends[pAlias]
+	if !ok {
+		return ErrFriendIsNotExist
+	}
+
+	delete(p.fFriends, pAlias)
+	if err := p.save(); err != nil {
+		p.fFriends[pAlias] = friend
+		return err
+	}
+
+	p.fMapPubKeys.DelPubKey(friend.fPubKey)
+	return nil
+}
+
+// Changes the alias of friend. The public key and the metadata are saved.
+func (p *sFriendStore) RenameFriend(pOldAlias, pNewAlias string) error {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	if pNewAlias == "" || len(pNewAlias) > cMaxAliasSize {
+		return ErrInvalidAlias
+	}
+
+	friend, ok := p.fFriends[pOldAlias]
+	if !ok {
+		return ErrFriendIsNotExist
+	}
+	if _, ok := p.fFriends[pNewAlias]; ok {
+		return ErrFriendIsExist
+	}
+
+	delete(p.fFriends, pOldAlias)
+	friend.FAlias = pNewAlias
+	p.fFriends[pNewAlias] = friend
+
+	if err := p.save(); err != nil {
+		delete(p.fFriends, pNewAlias)
+		friend.FAlias = pOldAlias
+		p.fFriends[pOldAlias] = friend
+		return err
+	}
+	return nil
+}
+
+// Replaces the metadata of friend. Nil metadata deletes the previous one.
+func (p *sFriendStore) SetMetadata(pAlias string, pMetadata map[string]string) error {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	friend, ok := p.fFriends[pAlias]
+	if !ok {
+		return ErrFriendIsNotExist
+	}
+
+	oldMetadata := friend.FMetadata
+	friend.FMetadata = maps.Clone(pMetadata)
+
+	if err := p.save(); err != nil {
+		friend.FMetadata = oldMetadata
+		return err
+	}
+	return nil
+}
+
+func (p *sFriendStore) getAliasByPubKey(pPubKey asymmetric.IPubKey) string {
+	pubKeyStr := pPubKey.ToString()
+	for alias, friend := range p.fFriends {
+		if friend.FPubKey == pubKeyStr {
+			return alias
+		}
+	}
+	return ""
+}
+
+func (p *sFriendStore) sortedFriends() []*sFriend {
+	friends := make([]*sFriend, 0, len(p.fFriends))
+	for _, friend := range p.fFriends {
+		friends = append(friends, friend)
+	}
+
+	sort.Slice(friends, func(i, j int) bool {
+		return friends[i].FAlias < friends[j].FAlias
+	})
+
+	return friends
+}
+
+func (p *sFriendStore) save() error {
+	if err := p.fKVDB.Set(gFriendsKey, encoding.SerializeJSON(p.sortedFriends())); err != nil {
+		return errors.Join(ErrSaveFriends, err)
+	}
+	return nil
+}
+
+func (p *sFriend) copy() *sFriend {
+	friendCopy := *p
+	friendCopy.FMetadata = maps.Clone(p.FMetadata)
+	return &friendCopy
+}
+
+func (p *sFriend) GetAlias() string {
+	return p.FAlias
+}
+
+func (p *sFriend) GetPubKey() asymmetric.IPubKey {
+	return p.fPubKey
+}
+
+func (p *sFriend) GetAddedAt() time.Time {
+	return p.FAddedAt
+}
+
+func (p *sFriend) GetMetadata() map[string]string {
+	return maps.Clone(p.FMetadata)
+}
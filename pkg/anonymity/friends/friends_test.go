@@ -0,0 +1,189 @@
+package friends
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+	"github.com/number571/go-peer/pkg/storage/database"
+)
+
+const (
+	tcPathDBTemplate = "database_test_%d.db"
+)
+
+func TestError(t *testing.T) {
+	t.Parallel()
+
+	str := "value"
+	err := &SFriendsError{str}
+	if err.Error() != errPrefix+str {
+		t.Error("incorrect err.Error()")
+		return
+	}
+}
+
+func TestFriendStore(t *testing.T) {
+	t.Parallel()
+
+	dbPath := fmt.Sprintf(tcPathDBTemplate, 1)
+	defer os.RemoveAll(dbPath)
+
+	kvDB, err := database.NewKVDatabase(dbPath)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer kvDB.Close()
+
+	mapPubKeys := asymmetric.NewMapPubKeys()
+	friendStore, err := NewFriendStore(kvDB, mapPubKeys)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	pubKey1 := asymmetric.NewPrivKey().GetPubKey()
+	pubKey2 := asymmetric.NewPrivKey().GetPubKey()
+
+	if err := friendStore.AddFriend("alice", pubKey1); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := friendStore.AddFriend("bob", pubKey2); err != nil {
+		t.Error(err)
+		return
+	}
+	if mapPubKeys.GetPubKey(pubKey1.GetHasher().ToBytes()) == nil {
+		t.Error("public key of friend is not added to the map")
+		return
+	}
+
+	if err := friendStore.AddFriend("alice", pubKey2); !errors.Is(err, ErrFriendIsExist) {
+		t.Error("success add friend with existing alias")
+		return
+	}
+	if err := friendStore.AddFriend("carol", pubKey1); !errors.Is(err, ErrPubKeyIsExist) {
+		t.Error("success add friend with existing public key")
+		return
+	}
+	if err := friendStore.AddFriend("", pubKey1); !errors.Is(err, ErrInvalidAlias) {
+		t.Error("success add friend with empty alias")
+		return
+	}
+	if err := friendStore.AddFriend("carol", nil); !errors.Is(err, ErrInvalidPubKey) {
+		t.Error("success add friend without public key")
+		return
+	}
+
+	if err := friendStore.SetMetadata("alice", map[string]string{"note": "hello"}); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := friendStore.RenameFriend("alice", "alice2"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := friendStore.RenameFriend("alice2", "bob"); !errors.Is(err, ErrFriendIsExist) {
+		t.Error("success rename friend to existing alias")
+		return
+	}
+	if err := friendStore.RenameFriend("unknown", "carol"); !errors.Is(err, ErrFriendIsNotExist) {
+		t.Error("success rename unknown friend")
+		return
+	}
+
+	// friends are loaded by the new store from the database
+	newMapPubKeys := asymmetric.NewMapPubKeys()
+	newFriendStore, err := NewFriendStore(kvDB, newMapPubKeys)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	friends := newFriendStore.GetFriends()
+	if len(friends) != 2 || friends[0].GetAlias() != "alice2" || friends[1].GetAlias() != "bob" {
+		t.Error("invalid loaded friends")
+		return
+	}
+	if friends[0].GetPubKey().ToString() != pubKey1.ToString() {
+		t.Error("invalid public key of loaded friend")
+		return
+	}
+	if friends[0].GetMetadata()["note"] != "hello" || friends[0].GetAddedAt().IsZero() {
+		t.Error("invalid metadata of loaded friend")
+		return
+	}
+	if newMapPubKeys.GetPubKey(pubKey2.GetHasher().ToBytes()) == nil {
+		t.Error("public key of loaded friend is not added to the map")
+		return
+	}
+
+	if err := newFriendStore.DelFriend("bob"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := newFriendStore.DelFriend("bob"); !errors.Is(err, ErrFriendIsNotExist) {
+		t.Error("success delete unknown friend")
+		return
+	}
+	if newMapPubKeys.GetPubKey(pubKey2.GetHasher().ToBytes()) != nil {
+		t.Error("public key of deleted friend is not deleted from the map")
+		return
+	}
+	if _, ok := newFriendStore.GetFriend("bob"); ok {
+		t.Error("deleted friend is exist")
+		return
+	}
+
+	friend, ok := newFriendStore.GetFriend("alice2")
+	if !ok {
+		t.Error("friend is not exist")
+		return
+	}
+	friend.GetMetadata()["note"] = "changed"
+	if f, _ := newFriendStore.GetFriend("alice2"); f.GetMetadata()["note"] != "hello" {
+		t.Error("metadata of friend is changed outside the store")
+		return
+	}
+}
+
+func TestInvalidFriendStore(t *testing.T) {
+	t.Parallel()
+
+	dbPath := fmt.Sprintf(tcPathDBTemplate, 2)
+	defer os.RemoveAll(dbPath)
+
+	kvDB, err := database.NewKVDatabase(dbPath)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer kvDB.Close()
+
+	if err := kvDB.Set(gFriendsKey, []byte("invalid")); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := NewFriendStore(kvDB, asymmetric.NewMapPubKeys()); err == nil {
+		t.Error("success load invalid friends")
+		return
+	}
+
+	if err := kvDB.Set(gFriendsKey, []byte(`[{"alias":"alice","pub_key":"invalid"}]`)); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := NewFriendStore(kvDB, asymmetric.NewMapPubKeys()); !errors.Is(err, ErrDecodeFriends) {
+		t.Error("success load friend with invalid public key")
+		return
+	}
+
+	kvDB.Close()
+	if _, err := NewFriendStore(kvDB, asymmetric.NewMapPubKeys()); err == nil {
+		t.Error("success load friends from closed database")
+		return
+	}
+}
@@ -0,0 +1,26 @@
+package friends
+
+import (
+	"time"
+
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+)
+
+type IFriendStore interface {
+	GetMapPubKeys() asymmetric.IMapPubKeys
+
+	GetFriends() []IFriend
+	GetFriend(string) (IFriend, bool)
+
+	AddFriend(string, asymmetric.IPubKey) error
+	DelFriend(string) error
+	RenameFriend(string, string) error
+	SetMetadata(string, map[string]string) error
+}
+
+type IFriend interface {
+	GetAlias() string
+	GetPubKey() asymmetric.IPubKey
+	GetAddedAt() time.Time
+	GetMetadata() map[string]string
+}
@@ -0,0 +1,80 @@
+package anonymity
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	// Count of the remembered actions finished without the response.
+	cLateActionsSize = (1 << 10)
+)
+
+var (
+	_ IFuture = &sFuture{}
+)
+
+type sFuture struct {
+	fMutex  sync.Mutex
+	fAction uint32
+	fDone   chan struct{}
+	fCancel context.CancelFunc
+	fResult []byte
+	fErr    error
+}
+
+func newFuture(pAction uint32, pCancel context.CancelFunc) *sFuture {
+	return &sFuture{
+		fAction: pAction,
+		fDone:   make(chan struct{}),
+		fCancel: pCancel,
+	}
+}
+
+// Identifier of the request. Late responses are passed with it.
+func (p *sFuture) GetAction() uint32 {
+	return p.fAction
+}
+
+// Channel is closed when the result is ready.
+func (p *sFuture) Done() <-chan struct{} {
+	return p.fDone
+}
+
+// Returns the result without waiting (poll).
+func (p *sFuture) Result() ([]byte, error) {
+	select {
+	case <-p.fDone:
+	default:
+		return nil, ErrFutureIsPending
+	}
+
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	return p.fResult, p.fErr
+}
+
+// Waits for the result. The request is not canceled by the context of waiting.
+func (p *sFuture) Wait(pCtx context.Context) ([]byte, error) {
+	select {
+	case <-pCtx.Done():
+		return nil, pCtx.Err()
+	case <-p.fDone:
+		return p.Result()
+	}
+}
+
+// Stops the waiting of response. The response received after
+// the cancellation is passed to the handler of late responses.
+func (p *sFuture) Cancel() {
+	p.fCancel()
+}
+
+func (p *sFuture) setResult(pResult []byte, pErr error) {
+	p.fMutex.Lock()
+	p.fResult, p.fErr = pResult, pErr
+	p.fMutex.Unlock()
+
+	close(p.fDone)
+}
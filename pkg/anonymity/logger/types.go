@@ -23,12 +23,18 @@ const (
 	CLogInfoExist
 	CLogInfoUndecryptable
 	CLogInfoWithoutResponse
+	CLogInfoRepeatedRequest
+	CLogInfoLateResponse
+	CLogInfoHandleRequest
 
 	// WARN
 	CLogWarnMessageNull
 	CLogWarnPayloadNull
 	CLogWarnUnknownRoute
 	CLogWarnIncorrectResponse
+	CLogWarnHandlerPanic
+	CLogWarnAccessDenied
+	CLogWarnRequestLimited
 
 	// ERRO
 	CLogErroDatabaseGet
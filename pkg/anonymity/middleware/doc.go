@@ -0,0 +1,7 @@
+// Package middleware contains the middlewares of the route handlers of anonymity node.
+//
+// Middlewares are registered by the node for all routes (UseMiddleware) or for
+// the one route (UseRouteMiddleware). They allow you to add the rate limits of friends,
+// the logging of requests, the recovery of panics and the metrics without changing
+// the handlers. Access of friends to the routes is checked by the node (see acl package).
+package middleware
@@ -0,0 +1,17 @@
+package middleware
+
+const (
+	errPrefix = "pkg/anonymity/middleware = "
+)
+
+type SMiddlewareError struct {
+	str string
+}
+
+func (err *SMiddlewareError) Error() string {
+	return errPrefix + err.str
+}
+
+var (
+	ErrRateLimit = &SMiddlewareError{"rate limit"}
+)
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	_ IMetrics    = &sMetrics{}
+	_ IRouteStats = &sRouteStats{}
+)
+
+type sMetrics struct {
+	fMutex  sync.Mutex
+	fRoutes map[uint32]*sRouteStats
+}
+
+type sRouteStats struct {
+	fRequests uint64
+	fErrors   uint64
+	fDuration time.Duration
+}
+
+// Creates the metrics of requests grouped by the routes.
+func NewMetrics() IMetrics {
+	return &sMetrics{
+		fRoutes: make(map[uint32]*sRouteStats, 64),
+	}
+}
+
+func (p *sMetrics) Observe(pHead uint32, pDuration time.Duration, pErr error) {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	stats, ok := p.fRoutes[pHead]
+	if !ok {
+		stats = &sRouteStats{}
+		p.fRoutes[pHead] = stats
+	}
+
+	stats.fRequests++
+	stats.fDuration += pDuration
+	if pErr != nil {
+		stats.fErrors++
+	}
+}
+
+// Returns the snapshot of statistics for all the routes.
+func (p *sMetrics) GetStats() map[uint32]IRouteStats {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	mapping := make(map[uint32]IRouteStats, len(p.fRoutes))
+	for head, stats := range p.fRoutes {
+		statsCopy := *stats
+		mapping[head] = &statsCopy
+	}
+
+	return mapping
+}
+
+func (p *sRouteStats) GetRequests() uint64 {
+	return p.fRequests
+}
+
+// Requests finished with the error (including rejected by the middlewares).
+func (p *sRouteStats) GetErrors() uint64 {
+	return p.fErrors
+}
+
+// Total duration of the requests.
+func (p *sRouteStats) GetDuration() time.Duration {
+	return p.fDuration
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/number571/go-peer/pkg/anonymity"
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+	"github.com/number571/go-peer/pkg/network/ratelimit"
+
+	anon_logger "github.com/number571/go-peer/pkg/anonymity/logger"
+)
+
+// Limits the rate of requests of each friend. Requests over the limit are rejected
+// (the sender gets the limited response if the node sends the negative responses).
+// Waiting for the tokens would block the handling of requests of all friends,
+// so the delay on limit is not supported.
+// Limiters are shared by all the routes wrapped by this middleware.
+func RateLimit(pSett ratelimit.ISettings) anonymity.IMiddlewareF {
+	if pSett.GetDelayOnLimit() {
+		panic(`pSett.GetDelayOnLimit()`)
+	}
+
+	mutex := sync.Mutex{}
+	limiters := make(map[string]ratelimit.ILimiter, 64)
+
+	getLimiter := func(pSender asymmetric.IPubKey) ratelimit.ILimiter {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		// count of friends is limited by the map of public keys
+		key := pSender.GetHasher().ToString()
+		limiter, ok := limiters[key]
+		if !ok {
+			limiter = ratelimit.NewLimiter(pSett)
+			limiters[key] = limiter
+		}
+		return limiter
+	}
+
+	return func(_ uint32, pNext anonymity.IHandlerF) anonymity.IHandlerF {
+		return func(pCtx context.Context, pNode anonymity.INode, pSender asymmetric.IPubKey, pBody []byte) ([]byte, error) {
+			if !getLimiter(pSender).Allow(uint64(len(pBody))) {
+				return nil, errors.Join(ErrRateLimit, anonymity.ErrRequestLimited)
+			}
+			return pNext(pCtx, pNode, pSender, pBody)
+		}
+	}
+}
+
+// Pushes the request (sender and size of body) into the logger of node.
+func Logging() anonymity.IMiddlewareF {
+	return func(_ uint32, pNext anonymity.IHandlerF) anonymity.IHandlerF {
+		return func(pCtx context.Context, pNode anonymity.INode, pSender asymmetric.IPubKey, pBody []byte) ([]byte, error) {
+			logBuilder := anon_logger.NewLogBuilder(pNode.GetSettings().GetServiceName()).
+				WithPubKey(pSender).
+				WithSize(len(pBody))
+			pNode.GetLogger().PushInfo(logBuilder.WithType(anon_logger.CLogInfoHandleRequest))
+			return pNext(pCtx, pNode, pSender, pBody)
+		}
+	}
+}
+
+// Converts the panic of handler into the error of node (anonymity.ErrHandlerPanic),
+// so the outer middlewares (logging, metrics) can process it as the failed request.
+// The node recovers the panics of handlers itself, so the middleware is needed only
+// for the middlewares which wrap it.
+func Recover() anonymity.IMiddlewareF {
+	return func(_ uint32, pNext anonymity.IHandlerF) anonymity.IHandlerF {
+		return func(pCtx context.Context, pNode anonymity.INode, pSender asymmetric.IPubKey, pBody []byte) (resp []byte, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					resp, err = nil, anonymity.ErrHandlerPanic
+				}
+			}()
+			return pNext(pCtx, pNode, pSender, pBody)
+		}
+	}
+}
+
+// Passes the duration and the result of each request into the metrics.
+func Metrics(pMetrics IMetrics) anonymity.IMiddlewareF {
+	return func(pHead uint32, pNext anonymity.IHandlerF) anonymity.IHandlerF {
+		return func(pCtx context.Context, pNode anonymity.INode, pSender asymmetric.IPubKey, pBody []byte) ([]byte, error) {
+			start := time.Now()
+			resp, err := pNext(pCtx, pNode, pSender, pBody)
+			pMetrics.Observe(pHead, time.Since(start), err)
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// nolint: goerr113
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/number571/go-peer/pkg/anonymity"
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+	"github.com/number571/go-peer/pkg/logger"
+	"github.com/number571/go-peer/pkg/network/ratelimit"
+
+	anon_logger "github.com/number571/go-peer/pkg/anonymity/logger"
+)
+
+const (
+	tcHead = 123
+)
+
+func TestError(t *testing.T) {
+	t.Parallel()
+
+	str := "value"
+	err := &SMiddlewareError{str}
+	if err.Error() != errPrefix+str {
+		t.Error("incorrect err.Error()")
+		return
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	node := &tsNode{}
+	pubKey1 := asymmetric.NewPrivKey().GetPubKey()
+	pubKey2 := asymmetric.NewPrivKey().GetPubKey()
+
+	handler := RateLimit(ratelimit.NewSettings(&ratelimit.SSettings{
+		FMessagesPerSec: 1,
+	}))(tcHead, testEchoHandler)
+
+	ctx := context.Background()
+	if _, err := handler(ctx, node, pubKey1, []byte("hello")); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := handler(ctx, node, pubKey1, []byte("hello")); !errors.Is(err, ErrRateLimit) {
+		t.Error("success request over the rate limit")
+		return
+	}
+	// each friend has the own limiter
+	if _, err := handler(ctx, node, pubKey2, []byte("hello")); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// limited response is sent by the node
+	if _, err := handler(ctx, node, pubKey2, []byte("hello")); !errors.Is(err, anonymity.ErrRequestLimited) {
+		t.Error("rejected request is not limited")
+		return
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("success rate limit with delay")
+		}
+	}()
+	_ = RateLimit(ratelimit.NewSettings(&ratelimit.SSettings{
+		FMessagesPerSec: 1,
+		FDelayOnLimit:   true,
+	}))
+}
+
+func TestLogging(t *testing.T) {
+	t.Parallel()
+
+	node := &tsNode{}
+	pubKey := asymmetric.NewPrivKey().GetPubKey()
+
+	handler := Logging()(tcHead, testEchoHandler)
+	if _, err := handler(context.Background(), node, pubKey, []byte("hello")); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(node.fLogs) != 1 {
+		t.Error("request is not logged")
+		return
+	}
+	getter := node.fLogs[0].(anon_logger.ILogBuilder).Build()
+	if getter.GetType() != anon_logger.CLogInfoHandleRequest || getter.GetSize() != 5 {
+		t.Error("got invalid log of request")
+		return
+	}
+	if !bytes.Equal(getter.GetPubKey().ToBytes(), pubKey.ToBytes()) {
+		t.Error("got invalid public key of request")
+		return
+	}
+}
+
+func TestRecoverAndMetrics(t *testing.T) {
+	t.Parallel()
+
+	node := &tsNode{}
+	pubKey := asymmetric.NewPrivKey().GetPubKey()
+	metrics := NewMetrics()
+
+	panicHandler := func(_ context.Context, _ anonymity.INode, _ asymmetric.IPubKey, _ []byte) ([]byte, error) {
+		panic("handler panic")
+	}
+
+	handler := Metrics(metrics)(tcHead, Recover()(tcHead, panicHandler))
+	if _, err := handler(context.Background(), node, pubKey, []byte("hello")); !errors.Is(err, anonymity.ErrHandlerPanic) {
+		t.Error("panic of handler is not recovered")
+		return
+	}
+
+	handler = Metrics(metrics)(tcHead+1, testEchoHandler)
+	if _, err := handler(context.Background(), node, pubKey, []byte("hello")); err != nil {
+		t.Error(err)
+		return
+	}
+
+	stats := metrics.GetStats()
+	if len(stats) != 2 {
+		t.Error("invalid count of routes")
+		return
+	}
+	if stats[tcHead].GetRequests() != 1 || stats[tcHead].GetErrors() != 1 {
+		t.Error("invalid metrics of failed route")
+		return
+	}
+	if stats[tcHead+1].GetRequests() != 1 || stats[tcHead+1].GetErrors() != 0 {
+		t.Error("invalid metrics of success route")
+		return
+	}
+	if stats[tcHead+1].GetDuration() < 0 || stats[tcHead+1].GetDuration() > time.Minute {
+		t.Error("invalid duration of route")
+		return
+	}
+}
+
+func testEchoHandler(_ context.Context, _ anonymity.INode, _ asymmetric.IPubKey, pBody []byte) ([]byte, error) {
+	return pBody, nil
+}
+
+// Node saves the pushed logs.
+type tsNode struct {
+	anonymity.INode
+
+	fLogs []logger.ILogArg
+}
+
+func (p *tsNode) GetSettings() anonymity.ISettings {
+	return anonymity.NewSettings(&anonymity.SSettings{
+		FServiceName:  "TEST",
+		FFetchTimeout: time.Minute,
+	})
+}
+
+func (p *tsNode) GetLogger() logger.ILogger {
+	return p
+}
+
+func (p *tsNode) PushInfo(pArg logger.ILogArg) { p.fLogs = append(p.fLogs, pArg) }
+func (p *tsNode) PushWarn(pArg logger.ILogArg) { p.fLogs = append(p.fLogs, pArg) }
+func (p *tsNode) PushErro(pArg logger.ILogArg) { p.fLogs = append(p.fLogs, pArg) }
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"time"
+)
+
+type IMetrics interface {
+	Observe(uint32, time.Duration, error)
+	GetStats() map[uint32]IRouteStats
+}
+
+type IRouteStats interface {
+	GetRequests() uint64
+	GetErrors() uint64
+	GetDuration() time.Duration
+}
@@ -208,18 +208,19 @@ func (p *sQBProblemProcessor) fillRandPool(pCtx context.Context) error {
 }
 
 func (p *sQBProblemProcessor) pushMessage(pCtx context.Context, pQueue chan<- layer1.IMessage, pMsg []byte) error {
-	chNetMsg := make(chan layer1.IMessage)
-	go func() {
-		chNetMsg <- layer1.NewMessage(
-			p.fSettings.GetMessageConstructSettings(),
-			payload.NewPayload32(p.fSettings.GetNetworkMask(), pMsg),
-		)
-	}()
+	// proof of work is stopped when the context is done
+	netMsg, err := layer1.NewMessageWithContext(
+		pCtx,
+		p.fSettings.GetMessageConstructSettings(),
+		payload.NewPayload32(p.fSettings.GetNetworkMask(), pMsg),
+	)
+	if err != nil {
+		return err
+	}
 	select {
 	case <-pCtx.Done():
 		return pCtx.Err()
-	case netMsg := <-chNetMsg:
-		pQueue <- netMsg
+	case pQueue <- netMsg:
 		return nil
 	}
 }
@@ -0,0 +1,47 @@
+package anonymity
+
+import (
+	"bytes"
+)
+
+var (
+	// body of the negative response (access is denied = empty body)
+	gLimitedBody = []byte{1}
+)
+
+const (
+	// saved response of the repeated request
+	cSavedWithoutResponse = 0
+	cSavedWithResponse    = 1
+)
+
+// Response with the route of payload head. The route of negative
+// response is inverted and the body is the reason of rejection.
+type sResponse struct {
+	fRoute uint32
+	fBody  []byte
+}
+
+func (p sResponse) isDenied(pRoute uint32) bool {
+	return p.fRoute == ^pRoute && len(p.fBody) == 0
+}
+
+func (p sResponse) isLimited(pRoute uint32) bool {
+	return p.fRoute == ^pRoute && bytes.Equal(p.fBody, gLimitedBody)
+}
+
+// Request without the response (nil) is saved too,
+// so the repeated request does not call the handler again.
+func newSavedResponse(pResp []byte) []byte {
+	if pResp == nil {
+		return []byte{cSavedWithoutResponse}
+	}
+	return append([]byte{cSavedWithResponse}, pResp...)
+}
+
+func loadSavedResponse(pSaved []byte) ([]byte, bool) {
+	if len(pSaved) == 0 || pSaved[0] != cSavedWithResponse {
+		return nil, false
+	}
+	return pSaved[1:], true
+}
@@ -10,14 +10,24 @@ var (
 
 type SSettings sSettings
 type sSettings struct {
-	FServiceName  string
-	FFetchTimeout time.Duration
+	FServiceName         string
+	FFetchTimeout        time.Duration
+	FRetryCount          uint64
+	FRetryBackoff        time.Duration
+	FResponseCacheSize   uint64
+	FMaxFetchesPerFriend uint64
+	FDeniedResponse      bool
 }
 
 func NewSettings(pSett *SSettings) ISettings {
 	return (&sSettings{
-		FServiceName:  pSett.FServiceName,
-		FFetchTimeout: pSett.FFetchTimeout,
+		FServiceName:         pSett.FServiceName,
+		FFetchTimeout:        pSett.FFetchTimeout,
+		FRetryCount:          pSett.FRetryCount,
+		FRetryBackoff:        pSett.FRetryBackoff,
+		FResponseCacheSize:   pSett.FResponseCacheSize,
+		FMaxFetchesPerFriend: pSett.FMaxFetchesPerFriend,
+		FDeniedResponse:      pSett.FDeniedResponse,
 	}).mustNotNull()
 }
 
@@ -25,6 +35,14 @@ func (p *sSettings) mustNotNull() ISettings {
 	if p.FFetchTimeout == 0 {
 		panic(`p.FFetchTimeout == 0`)
 	}
+	if p.FRetryCount != 0 && p.FResponseCacheSize == 0 {
+		// retries of the request must not call the handler again
+		panic(`p.FRetryCount != 0 && p.FResponseCacheSize == 0`)
+	}
+	// p.FRetryCount can be = 0 (request is sent once)
+	// p.FRetryBackoff can be = 0 (request is sent again without delay)
+	// p.FResponseCacheSize can be = 0 (if p.FRetryCount = 0)
+	// p.FMaxFetchesPerFriend can be = 0 (count of requests is unlimited)
 	return p
 }
 
@@ -35,3 +53,31 @@ func (p *sSettings) GetServiceName() string {
 func (p *sSettings) GetFetchTimeout() time.Duration {
 	return p.FFetchTimeout
 }
+
+// Count of the repeated sendings of the request with the same action
+// if the response is not received during the fetch timeout.
+func (p *sSettings) GetRetryCount() uint64 {
+	return p.FRetryCount
+}
+
+// Delay before the first retry. Each next delay is doubled.
+func (p *sSettings) GetRetryBackoff() time.Duration {
+	return p.FRetryBackoff
+}
+
+// Count of the saved responses. The response is sent again to the
+// repeated request (same action) without the call of handler.
+func (p *sSettings) GetResponseCacheSize() uint64 {
+	return p.FResponseCacheSize
+}
+
+// Limit of the requests waiting for the responses from one friend.
+func (p *sSettings) GetMaxFetchesPerFriend() uint64 {
+	return p.FMaxFetchesPerFriend
+}
+
+// Sender of the denied (or limited) request gets the negative response and the fetch
+// fails without waiting, otherwise the request is dropped (fetch timeout).
+func (p *sSettings) GetDeniedResponse() bool {
+	return p.FDeniedResponse
+}
@@ -0,0 +1,22 @@
+// Package stream allows you to transfer the data larger than one message over the anonymity node.
+//
+// The data is split into the chunks with the sequence numbers. Each chunk is sent by the
+// separate message of the queue. The last message (request) contains the count of chunks
+// and the hash of the whole stream. The receiver responds with the missing chunks, so the
+// sender resends only them. The receiver gets the data as the io.Reader in the order of
+// chunks and the reader returns the error if the hash of the stream is invalid.
+//
+// Count of the streams received from one friend at the same time is limited. The stream
+// is closed if the handler does not read it until the timeout. Closed streams are
+// remembered, so the late frames do not start them again.
+/*
+	STREAM FRAME FORMAT
+
+	T || I || S || D
+	where
+		T - type of frame (1 byte: chunk, done)
+		I - identifier of stream (8 bytes)
+		S - sequence number of chunk or count of chunks (8 bytes)
+		D - bytes of chunk or hash of stream
+*/
+package stream
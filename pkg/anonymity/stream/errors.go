@@ -0,0 +1,32 @@
+package stream
+
+const (
+	errPrefix = "pkg/anonymity/stream = "
+)
+
+type SStreamError struct {
+	str string
+}
+
+func (err *SStreamError) Error() string {
+	return errPrefix + err.str
+}
+
+var (
+	ErrReadStream       = &SStreamError{"read stream"}
+	ErrSendChunk        = &SStreamError{"send chunk"}
+	ErrFetchDone        = &SStreamError{"fetch done"}
+	ErrMissingChunks    = &SStreamError{"missing chunks"}
+	ErrInvalidHash      = &SStreamError{"invalid hash of stream"}
+	ErrInvalidFrame     = &SStreamError{"invalid frame"}
+	ErrInvalidResponse  = &SStreamError{"invalid response"}
+	ErrStreamTimeout    = &SStreamError{"stream timeout"}
+	ErrStreamSizeLimit  = &SStreamError{"stream size limit"}
+	ErrPayloadLimit     = &SStreamError{"payload limit is less than frame"}
+	ErrStreamNotHandled = &SStreamError{"stream is not handled"}
+	ErrStreamRejected   = &SStreamError{"stream is rejected by receiver"}
+	ErrStreamClosed     = &SStreamError{"stream is closed"}
+	ErrStreamLimit      = &SStreamError{"limit of streams of friend"}
+	ErrStreamNotRead    = &SStreamError{"stream is not read by handler"}
+	ErrSpoolStream      = &SStreamError{"spool stream"}
+)
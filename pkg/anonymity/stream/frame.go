@@ -0,0 +1,95 @@
+package stream
+
+import (
+	"bytes"
+
+	"github.com/number571/go-peer/pkg/encoding"
+)
+
+const (
+	cFrameHeadSize = 1 + 2*encoding.CSizeUint64
+)
+
+const (
+	cFrameChunk = byte(1)
+	cFrameDone  = byte(2)
+)
+
+const (
+	cStatusComplete = byte(0)
+	cStatusMissing  = byte(1)
+	cStatusInvalid  = byte(2)
+)
+
+type sFrame struct {
+	fType byte
+	fID   uint64
+	fSeq  uint64
+	fData []byte
+}
+
+func loadFrame(pBytes []byte) (*sFrame, error) {
+	if len(pBytes) < cFrameHeadSize {
+		return nil, ErrInvalidFrame
+	}
+
+	idBytes := [encoding.CSizeUint64]byte{}
+	copy(idBytes[:], pBytes[1:])
+
+	seqBytes := [encoding.CSizeUint64]byte{}
+	copy(seqBytes[:], pBytes[1+encoding.CSizeUint64:])
+
+	frame := &sFrame{
+		fType: pBytes[0],
+		fID:   encoding.BytesToUint64(idBytes),
+		fSeq:  encoding.BytesToUint64(seqBytes),
+		fData: pBytes[cFrameHeadSize:],
+	}
+
+	switch frame.fType {
+	case cFrameChunk, cFrameDone:
+		return frame, nil
+	default:
+		return nil, ErrInvalidFrame
+	}
+}
+
+func (p *sFrame) toBytes() []byte {
+	idBytes := encoding.Uint64ToBytes(p.fID)
+	seqBytes := encoding.Uint64ToBytes(p.fSeq)
+	return bytes.Join(
+		[][]byte{
+			{p.fType},
+			idBytes[:],
+			seqBytes[:],
+			p.fData,
+		},
+		[]byte{},
+	)
+}
+
+// Response of the done frame: status || missing sequence numbers.
+func newResponse(pStatus byte, pMissing []uint64) []byte {
+	result := make([]byte, 0, 1+len(pMissing)*encoding.CSizeUint64)
+	result = append(result, pStatus)
+	for _, seq := range pMissing {
+		seqBytes := encoding.Uint64ToBytes(seq)
+		result = append(result, seqBytes[:]...)
+	}
+	return result
+}
+
+func loadResponse(pBytes []byte) (byte, []uint64, error) {
+	if len(pBytes) == 0 || (len(pBytes)-1)%encoding.CSizeUint64 != 0 {
+		return 0, nil, ErrInvalidResponse
+	}
+
+	missing := make([]uint64, 0, (len(pBytes)-1)/encoding.CSizeUint64)
+	for i := 1; i < len(pBytes); i += encoding.CSizeUint64 {
+		seqBytes := [encoding.CSizeUint64]byte{}
+		copy(seqBytes[:], pBytes[i:])
+		missing = append(missing, encoding.BytesToUint64(seqBytes))
+	}
+
+	return pBytes[0], missing, nil
+}
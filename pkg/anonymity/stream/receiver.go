@@ -0,0 +1,336 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/number571/go-peer/pkg/anonymity"
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+	"github.com/number571/go-peer/pkg/crypto/hashing"
+	"github.com/number571/go-peer/pkg/encoding"
+)
+
+const (
+	// closed streams are remembered to not start them again by the late frames
+	cClosedStreamsSize = (1 << 10)
+)
+
+// State of the received stream. Chunks are hashed in the order of
+// sequence numbers and are passed to the writer of pipe.
+type sRecvStream struct {
+	fMutex    sync.Mutex
+	fKey      string
+	fSender   string
+	fChunks   map[uint64][]byte // chunks received out of order
+	fPending  [][]byte          // ordered chunks are not written yet
+	fHasher   hash.Hash
+	fNext     uint64
+	fSize     uint64
+	fCount    uint64
+	fHash     []byte
+	fHasDone  bool
+	fFinished bool
+	fResult   error
+	fUpdated  time.Time
+	fSignal   chan struct{}
+	fWriter   *io.PipeWriter
+}
+
+func (p *sStreamer) handleFrame(
+	pCtx context.Context,
+	_ anonymity.INode,
+	pSender asymmetric.IPubKey,
+	pBody []byte,
+) ([]byte, error) {
+	frame, err := loadFrame(pBody)
+	if err != nil {
+		return nil, err
+	}
+	if frame.fType == cFrameDone && len(frame.fData) != hashing.CHasherSize {
+		return nil, ErrInvalidFrame
+	}
+
+	key := getStreamKey(pSender, frame.fID)
+	stream, err := p.getStream(pCtx, pSender, key)
+	if err != nil {
+		// repeated done frame of the closed stream gets its result
+		if status, ok := p.fClosed.Get([]byte(key)); ok && frame.fType == cFrameDone {
+			return newResponse(status[0], nil), nil
+		}
+		return nil, err
+	}
+
+	if frame.fType == cFrameChunk {
+		stream.addChunk(frame.fSeq, frame.fData, p.fSettings.GetMaxStreamSize())
+		return nil, nil
+	}
+
+	return stream.setDone(frame.fSeq, frame.fData, p.getMaxMissing()), nil
+}
+
+// Returns the state of stream. The new stream is passed to the handler.
+// Count of the streams received at the same time is limited for each friend.
+func (p *sStreamer) getStream(pCtx context.Context, pSender asymmetric.IPubKey, pKey string) (*sRecvStream, error) {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	if stream, ok := p.fStreams[pKey]; ok {
+		return stream, nil
+	}
+	if _, ok := p.fClosed.Get([]byte(pKey)); ok {
+		return nil, ErrStreamClosed
+	}
+
+	if p.fHandler == nil {
+		return nil, ErrStreamNotHandled
+	}
+
+	sender := pSender.GetHasher().ToString()
+	if p.fCounts[sender] >= p.fSettings.GetMaxFriendStreams() {
+		_ = p.fClosed.Set([]byte(pKey), []byte{cStatusInvalid})
+		return nil, ErrStreamLimit
+	}
+	p.fCounts[sender]++
+
+	reader, writer := io.Pipe()
+	stream := &sRecvStream{
+		fKey:     pKey,
+		fSender:  sender,
+		fChunks:  make(map[uint64][]byte, 16),
+		fHasher:  sha512.New384(),
+		fUpdated: time.Now(),
+		fSignal:  make(chan struct{}, 1),
+		fWriter:  writer,
+	}
+	p.fStreams[pKey] = stream
+
+	go p.runStream(stream)
+	go p.fHandler(pCtx, pSender, reader)
+
+	return stream, nil
+}
+
+// Finished stream is not counted in the limit of friend.
+func (p *sStreamer) releaseStream(pStream *sRecvStream) {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	p.fCounts[pStream.fSender]--
+	if p.fCounts[pStream.fSender] == 0 {
+		delete(p.fCounts, pStream.fSender)
+	}
+}
+
+// Result of the deleted stream is kept for the late frames.
+func (p *sStreamer) delStream(pStream *sRecvStream) {
+	pStream.fMutex.Lock()
+	status := cStatusComplete
+	if pStream.fResult != nil {
+		status = cStatusInvalid
+	}
+	pStream.fMutex.Unlock()
+
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	delete(p.fStreams, pStream.fKey)
+	_ = p.fClosed.Set([]byte(pStream.fKey), []byte{status})
+}
+
+func getStreamKey(pSender asymmetric.IPubKey, pID uint64) string {
+	return fmt.Sprintf("%s-%d", pSender.GetHasher().ToString(), pID)
+}
+
+// Response must be in the one message: status || missing sequence numbers.
+func (p *sStreamer) getMaxMissing() uint64 {
+	limit := p.fNode.GetQBProcessor().GetClient().GetPayloadLimit()
+	return (limit - encoding.CSizeUint64 - 1) / encoding.CSizeUint64
+}
+
+// Writes the ordered chunks to the pipe. The finished stream is kept
+// until the timeout to respond on the repeated done frames.
+func (p *sStreamer) runStream(pStream *sRecvStream) {
+	defer p.delStream(pStream)
+
+	timeout := p.fSettings.GetRecvTimeout()
+	closed := false
+
+	for {
+		pStream.fMutex.Lock()
+		pending := pStream.fPending
+		pStream.fPending = nil
+		finished, result := pStream.fFinished, pStream.fResult
+		updated := pStream.fUpdated
+		pStream.fMutex.Unlock()
+
+		if len(pending) != 0 {
+			for _, chunk := range pending {
+				if err := writeChunk(pStream.fWriter, chunk, timeout); err != nil {
+					// reader is closed or is not read by the handler
+					pStream.fMutex.Lock()
+					pStream.finish(ErrStreamNotRead)
+					pStream.fMutex.Unlock()
+					break
+				}
+			}
+			continue
+		}
+
+		if finished && !closed {
+			_ = pStream.fWriter.CloseWithError(result)
+			p.releaseStream(pStream)
+			closed = true
+		}
+
+		select {
+		case <-pStream.fSignal:
+		case <-time.After(time.Until(updated.Add(timeout))):
+			pStream.fMutex.Lock()
+			expired := time.Since(pStream.fUpdated) >= timeout
+			if expired && !pStream.fFinished {
+				pStream.finish(ErrStreamTimeout)
+			}
+			pStream.fMutex.Unlock()
+
+			if expired && closed {
+				return
+			}
+		}
+	}
+}
+
+// Write of the pipe waits for the reading of handler, so
+// it is interrupted by the closing of pipe after the timeout.
+func writeChunk(pWriter *io.PipeWriter, pChunk []byte, pTimeout time.Duration) error {
+	timer := time.AfterFunc(pTimeout, func() {
+		_ = pWriter.CloseWithError(ErrStreamNotRead)
+	})
+	defer timer.Stop()
+
+	_, err := pWriter.Write(pChunk)
+	return err
+}
+
+func (p *sRecvStream) addChunk(pSeq uint64, pChunk []byte, pMaxSize uint64) {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	// chunk can be received again after the resending,
+	// but it does not hold the finished stream
+	if p.fFinished {
+		return
+	}
+	p.fUpdated = time.Now()
+	if pSeq < p.fNext {
+		return
+	}
+	if _, ok := p.fChunks[pSeq]; ok {
+		return
+	}
+	if p.fHasDone && pSeq >= p.fCount {
+		p.finish(ErrInvalidFrame)
+		return
+	}
+
+	p.fSize += uint64(len(pChunk))
+	if p.fSize > pMaxSize {
+		p.finish(ErrStreamSizeLimit)
+		return
+	}
+
+	p.fChunks[pSeq] = bytes.Clone(pChunk)
+	p.orderChunks()
+}
+
+func (p *sRecvStream) setDone(pCount uint64, pHash []byte, pMaxMissing uint64) []byte {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	p.fUpdated = time.Now()
+
+	if !p.fFinished && !p.fHasDone {
+		p.fHasDone = true
+		p.fCount = pCount
+		p.fHash = bytes.Clone(pHash)
+		if p.fNext > pCount {
+			p.finish(ErrInvalidFrame)
+		}
+		for seq := range p.fChunks {
+			if seq >= pCount {
+				p.finish(ErrInvalidFrame)
+				break
+			}
+		}
+		p.orderChunks()
+	}
+
+	if p.fFinished {
+		if p.fResult != nil {
+			return newResponse(cStatusInvalid, nil)
+		}
+		return newResponse(cStatusComplete, nil)
+	}
+
+	missing := make([]uint64, 0, 16)
+	for seq := p.fNext; seq < p.fCount && uint64(len(missing)) < pMaxMissing; seq++ {
+		if _, ok := p.fChunks[seq]; !ok {
+			missing = append(missing, seq)
+		}
+	}
+	return newResponse(cStatusMissing, missing)
+}
+
+// Hashes and passes the chunks which follow the previous ones.
+func (p *sRecvStream) orderChunks() {
+	if p.fFinished {
+		return
+	}
+
+	for {
+		chunk, ok := p.fChunks[p.fNext]
+		if !ok {
+			break
+		}
+		delete(p.fChunks, p.fNext)
+		_, _ = p.fHasher.Write(chunk)
+		p.fPending = append(p.fPending, chunk)
+		p.fNext++
+	}
+	p.signal()
+
+	if !p.fHasDone || p.fNext != p.fCount {
+		return
+	}
+	if !bytes.Equal(p.fHasher.Sum(nil), p.fHash) {
+		p.finish(ErrInvalidHash)
+		return
+	}
+	p.finish(nil)
+}
+
+// Result of the stream is passed to the reader after the pending chunks.
+func (p *sRecvStream) finish(pErr error) {
+	if p.fFinished {
+		return
+	}
+	p.fFinished = true
+	p.fResult = pErr
+	p.fChunks = nil
+	if pErr != nil {
+		p.fPending = nil
+	}
+	p.signal()
+}
+
+func (p *sRecvStream) signal() {
+	select {
+	case p.fSignal <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,85 @@
+package stream
+
+import (
+	"time"
+)
+
+const (
+	cDefaultMaxFriendStreams = 4
+)
+
+var (
+	_ ISettings = &sSettings{}
+)
+
+type SSettings sSettings
+type sSettings struct {
+	FRoute         uint32
+	FChunkSize     uint64
+	FMaxStreamSize uint64
+	FRecvTimeout   time.Duration
+	FRetryCount    uint64
+
+	FMaxFriendStreams uint64
+}
+
+func NewSettings(pSett *SSettings) ISettings {
+	return (&sSettings{
+		FRoute:         pSett.FRoute,
+		FChunkSize:     pSett.FChunkSize,
+		FMaxStreamSize: pSett.FMaxStreamSize,
+		FRecvTimeout:   pSett.FRecvTimeout,
+		FRetryCount:    pSett.FRetryCount,
+
+		FMaxFriendStreams: pSett.FMaxFriendStreams,
+	}).mustNotNull()
+}
+
+func (p *sSettings) mustNotNull() ISettings {
+	// p.FRoute can be = 0
+	// p.FChunkSize can be = 0 (max size by the payload limit of client)
+	if p.FMaxStreamSize == 0 {
+		panic(`p.FMaxStreamSize == 0`)
+	}
+	if p.FRecvTimeout == 0 {
+		panic(`p.FRecvTimeout == 0`)
+	}
+	// p.FRetryCount can be = 0 (missing chunks are not resent)
+	if p.FMaxFriendStreams == 0 {
+		// streams of friend are always limited (each stream has the handler)
+		p.FMaxFriendStreams = cDefaultMaxFriendStreams
+	}
+	return p
+}
+
+// Head of the route registered in the node for the frames of streams.
+func (p *sSettings) GetRoute() uint32 {
+	return p.FRoute
+}
+
+func (p *sSettings) GetChunkSize() uint64 {
+	return p.FChunkSize
+}
+
+// Limit of the size of one received stream (bytes are buffered in memory
+// until the chunks are ordered and read by the handler).
+func (p *sSettings) GetMaxStreamSize() uint64 {
+	return p.FMaxStreamSize
+}
+
+// Received stream is closed with the error if new chunks are not received
+// during the timeout. Completed stream is forgotten after the timeout.
+func (p *sSettings) GetRecvTimeout() time.Duration {
+	return p.FRecvTimeout
+}
+
+// Count of the rounds of resending the missing chunks.
+func (p *sSettings) GetRetryCount() uint64 {
+	return p.FRetryCount
+}
+
+// Count of the streams received from one friend at the same time.
+// New streams over the limit are rejected.
+func (p *sSettings) GetMaxFriendStreams() uint64 {
+	return p.FMaxFriendStreams
+}
@@ -0,0 +1,264 @@
+package stream
+
+import (
+	"context"
+	"crypto/sha512"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/number571/go-peer/pkg/anonymity"
+	"github.com/number571/go-peer/pkg/anonymity/queue"
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+	"github.com/number571/go-peer/pkg/crypto/hashing"
+	"github.com/number571/go-peer/pkg/crypto/random"
+	"github.com/number571/go-peer/pkg/encoding"
+	"github.com/number571/go-peer/pkg/payload"
+	"github.com/number571/go-peer/pkg/storage/cache"
+)
+
+var (
+	_ IStreamer = &sStreamer{}
+)
+
+type sStreamer struct {
+	fMutex    sync.Mutex
+	fSettings ISettings
+	fNode     anonymity.INode
+	fHandler  IHandlerF
+	fStreams  map[string]*sRecvStream
+	fCounts   map[string]uint64
+	fClosed   cache.ICache
+}
+
+// Source of the chunks which are sent again by the missing of receiver.
+type sResender struct {
+	fReaderAt io.ReaderAt
+	fOffset   int64
+	fSpool    *os.File
+}
+
+// Creates the streamer and registers the route of frames in the node.
+func NewStreamer(pSett ISettings, pNode anonymity.INode) IStreamer {
+	streamer := &sStreamer{
+		fSettings: pSett,
+		fNode:     pNode,
+		fStreams:  make(map[string]*sRecvStream, 16),
+		fCounts:   make(map[string]uint64, 16),
+		fClosed:   cache.NewLRUCache(cClosedStreamsSize),
+	}
+	pNode.HandleFunc(pSett.GetRoute(), streamer.handleFrame)
+	return streamer
+}
+
+func (p *sStreamer) GetSettings() ISettings {
+	return p.fSettings
+}
+
+// Handler is called by the first frame of the new stream. The reader
+// returns io.EOF only after the check of the hash of the whole stream.
+// The reader must be read to the end or closed by the handler.
+func (p *sStreamer) HandleStream(pHandle IHandlerF) IStreamer {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	p.fHandler = pHandle
+	return p
+}
+
+// Sends the data of reader by the chunks and waits for the confirmation of receiver.
+// If the reader is io.ReaderAt with io.Seeker (from the current offset), then missing
+// chunks are read again, otherwise the chunks are saved into the temporary file until
+// the end of sending. Chunks are not saved if the retries are disabled.
+func (p *sStreamer) SendStream(pCtx context.Context, pRecv asymmetric.IPubKey, pReader io.Reader) error {
+	chunkSize, err := p.getChunkSize()
+	if err != nil {
+		return err
+	}
+
+	resender, err := newResender(pReader, p.fSettings.GetRetryCount())
+	if err != nil {
+		return err
+	}
+	defer resender.close()
+
+	streamID := random.NewRandom().GetUint64()
+	hasher := sha512.New384()
+	buffer := make([]byte, chunkSize)
+	count := uint64(0)
+
+	for {
+		n, err := io.ReadFull(pReader, buffer)
+		if n != 0 {
+			chunk := buffer[:n]
+			_, _ = hasher.Write(chunk)
+			if err := resender.store(chunk); err != nil {
+				return err
+			}
+			if err := p.sendChunk(pCtx, pRecv, streamID, count, chunk); err != nil {
+				return err
+			}
+			count++
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return errors.Join(ErrReadStream, err)
+		}
+	}
+
+	hash := hasher.Sum(nil)
+	retryCount := p.fSettings.GetRetryCount()
+
+	for i := uint64(0); ; i++ {
+		missing, err := p.fetchDone(pCtx, pRecv, streamID, count, hash)
+		if err != nil {
+			if i >= retryCount || pCtx.Err() != nil || errors.Is(err, ErrStreamRejected) {
+				return err
+			}
+			// response can be lost => done is sent again
+			continue
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		if i >= retryCount {
+			return ErrMissingChunks
+		}
+
+		for _, seq := range missing {
+			chunk, err := resender.getChunk(chunkSize, seq, count)
+			if err != nil {
+				return err
+			}
+			if err := p.sendChunk(pCtx, pRecv, streamID, seq, chunk); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *sStreamer) getChunkSize() (uint64, error) {
+	limit := p.fNode.GetQBProcessor().GetClient().GetPayloadLimit()
+
+	// done frame (with hash) must be in the one message
+	overhead := uint64(encoding.CSizeUint64 + cFrameHeadSize)
+	if limit <= overhead+hashing.CHasherSize {
+		return 0, ErrPayloadLimit
+	}
+
+	maxSize := limit - overhead
+	chunkSize := p.fSettings.GetChunkSize()
+	if chunkSize == 0 || chunkSize > maxSize {
+		return maxSize, nil
+	}
+	return chunkSize, nil
+}
+
+func newResender(pReader io.Reader, pRetryCount uint64) (*sResender, error) {
+	if pRetryCount == 0 {
+		return &sResender{}, nil
+	}
+
+	readerAt, okReaderAt := pReader.(io.ReaderAt)
+	seeker, okSeeker := pReader.(io.Seeker)
+	if okReaderAt && okSeeker {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			return &sResender{fReaderAt: readerAt, fOffset: offset}, nil
+		}
+	}
+
+	spool, err := os.CreateTemp("", "go-peer-stream-*")
+	if err != nil {
+		return nil, errors.Join(ErrSpoolStream, err)
+	}
+	return &sResender{fReaderAt: spool, fSpool: spool}, nil
+}
+
+func (p *sResender) store(pChunk []byte) error {
+	if p.fSpool == nil {
+		return nil
+	}
+	if _, err := p.fSpool.Write(pChunk); err != nil {
+		return errors.Join(ErrSpoolStream, err)
+	}
+	return nil
+}
+
+func (p *sResender) getChunk(pChunkSize, pSeq, pCount uint64) ([]byte, error) {
+	if pSeq >= pCount || p.fReaderAt == nil {
+		return nil, ErrInvalidResponse
+	}
+
+	chunk := make([]byte, pChunkSize)
+	n, err := p.fReaderAt.ReadAt(chunk, p.fOffset+int64(pSeq*pChunkSize))
+	if err != nil && !(errors.Is(err, io.EOF) && n != 0) {
+		return nil, errors.Join(ErrReadStream, err)
+	}
+	return chunk[:n], nil
+}
+
+func (p *sResender) close() {
+	if p.fSpool == nil {
+		return
+	}
+	_ = p.fSpool.Close()
+	_ = os.Remove(p.fSpool.Name())
+}
+
+// Chunk is enqueued again after the period of queue if the queue is full.
+func (p *sStreamer) sendChunk(pCtx context.Context, pRecv asymmetric.IPubKey, pID, pSeq uint64, pChunk []byte) error {
+	frame := &sFrame{fType: cFrameChunk, fID: pID, fSeq: pSeq, fData: pChunk}
+	pld := payload.NewPayload64(uint64(p.fSettings.GetRoute()), frame.toBytes())
+	period := p.fNode.GetQBProcessor().GetSettings().GetQueuePeriod()
+
+	for {
+		err := p.fNode.SendPayload(pCtx, pRecv, pld)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, queue.ErrQueueLimit) {
+			return errors.Join(ErrSendChunk, err)
+		}
+		select {
+		case <-pCtx.Done():
+			return pCtx.Err()
+		case <-time.After(period):
+		}
+	}
+}
+
+// Returns the missing chunks. The result is empty if the stream is received.
+func (p *sStreamer) fetchDone(pCtx context.Context, pRecv asymmetric.IPubKey, pID, pCount uint64, pHash []byte) ([]uint64, error) {
+	frame := &sFrame{fType: cFrameDone, fID: pID, fSeq: pCount, fData: pHash}
+	pld := payload.NewPayload32(p.fSettings.GetRoute(), frame.toBytes())
+
+	resp, err := p.fNode.FetchPayload(pCtx, pRecv, pld)
+	if err != nil {
+		return nil, errors.Join(ErrFetchDone, err)
+	}
+
+	status, missing, err := loadResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case cStatusComplete:
+		return nil, nil
+	case cStatusMissing:
+		if len(missing) == 0 {
+			return nil, ErrInvalidResponse
+		}
+		return missing, nil
+	case cStatusInvalid:
+		// invalid hash or size limit of stream
+		return nil, ErrStreamRejected
+	default:
+		return nil, ErrInvalidResponse
+	}
+}
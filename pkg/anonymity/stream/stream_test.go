@@ -0,0 +1,412 @@
+// nolint: goerr113
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/number571/go-peer/pkg/anonymity"
+	"github.com/number571/go-peer/pkg/anonymity/queue"
+	"github.com/number571/go-peer/pkg/client"
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+	"github.com/number571/go-peer/pkg/crypto/random"
+	"github.com/number571/go-peer/pkg/message/layer1"
+	"github.com/number571/go-peer/pkg/payload"
+	testutils "github.com/number571/go-peer/test/utils"
+)
+
+const (
+	tcRoute     = 123
+	tcChunkSize = 1000
+	tcMsgSize   = (8 << 10)
+)
+
+func TestError(t *testing.T) {
+	t.Parallel()
+
+	str := "value"
+	err := &SStreamError{str}
+	if err.Error() != errPrefix+str {
+		t.Error("incorrect err.Error()")
+		return
+	}
+}
+
+func TestSettings(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 2; i++ {
+		testSettings(t, i)
+	}
+}
+
+func testSettings(t *testing.T, n int) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("nothing panics")
+			return
+		}
+	}()
+	switch n {
+	case 0:
+		_ = NewSettings(&SSettings{
+			FRecvTimeout: time.Minute,
+		})
+	case 1:
+		_ = NewSettings(&SSettings{
+			FMaxStreamSize: (1 << 20),
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	node1, node2 := testNewNodes()
+	streamer1, streamer2 := testNewStreamers(node1, node2, time.Minute)
+
+	chResult := make(chan []byte, 1)
+	streamer2.HandleStream(func(_ context.Context, pSender asymmetric.IPubKey, pReader io.Reader) {
+		if !bytes.Equal(pSender.ToBytes(), node1.fPubKey.ToBytes()) {
+			chResult <- nil
+			return
+		}
+		data, err := io.ReadAll(pReader)
+		if err != nil {
+			chResult <- nil
+			return
+		}
+		chResult <- data
+	})
+
+	ctx := context.Background()
+	data := random.NewRandom().GetBytes(10*tcChunkSize + 123)
+
+	// lost chunks are resent by the response of receiver
+	node1.fDropSeqs = map[uint64]bool{2: true, 5: true}
+	if err := streamer1.SendStream(ctx, node2.fPubKey, bytes.NewReader(data)); err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(<-chResult, data) {
+		t.Error("received stream is not equal data (reader at)")
+		return
+	}
+
+	// missing chunks are read from the current offset of io.ReaderAt
+	reader := bytes.NewReader(data)
+	if _, err := io.CopyN(io.Discard, reader, 123); err != nil {
+		t.Error(err)
+		return
+	}
+	node1.fDropSeqs = map[uint64]bool{1: true, 9: true}
+	if err := streamer1.SendStream(ctx, node2.fPubKey, reader); err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(<-chResult, data[123:]) {
+		t.Error("received stream is not equal data (reader at offset)")
+		return
+	}
+
+	// chunks are saved into the temporary file if the reader is not io.ReaderAt
+	node1.fDropSeqs = map[uint64]bool{0: true, 10: true}
+	if err := streamer1.SendStream(ctx, node2.fPubKey, struct{ io.Reader }{bytes.NewReader(data)}); err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(<-chResult, data) {
+		t.Error("received stream is not equal data (reader)")
+		return
+	}
+
+	if err := streamer1.SendStream(ctx, node2.fPubKey, bytes.NewReader(nil)); err != nil {
+		t.Error(err)
+		return
+	}
+	if result := <-chResult; result == nil || len(result) != 0 {
+		t.Error("received stream is not empty")
+		return
+	}
+}
+
+func TestInvalidStream(t *testing.T) {
+	t.Parallel()
+
+	node1, node2 := testNewNodes()
+	streamer1, streamer2 := testNewStreamers(node1, node2, 100*time.Millisecond)
+
+	ctx := context.Background()
+	data := random.NewRandom().GetBytes(3 * tcChunkSize)
+
+	if err := streamer1.SendStream(ctx, node2.fPubKey, bytes.NewReader(data)); err == nil {
+		t.Error("success send stream without handler")
+		return
+	}
+
+	chErr := make(chan error, 1)
+	streamer2.HandleStream(func(_ context.Context, _ asymmetric.IPubKey, pReader io.Reader) {
+		_, err := io.ReadAll(pReader)
+		chErr <- err
+	})
+
+	// all chunks are lost more times than the count of retries
+	node1.fDropSeqs = map[uint64]bool{1: true}
+	node1.fDropAlways = true
+	if err := streamer1.SendStream(ctx, node2.fPubKey, bytes.NewReader(data)); !errors.Is(err, ErrMissingChunks) {
+		t.Error("success send stream with lost chunks")
+		return
+	}
+	if err := <-chErr; !errors.Is(err, ErrStreamTimeout) {
+		t.Error("stream with lost chunks is not closed by the timeout")
+		return
+	}
+
+	node1.fDropSeqs = nil
+	sender := streamer1.(*sStreamer)
+
+	if err := sender.sendChunk(ctx, node2.fPubKey, 1, 0, data); err != nil {
+		t.Error(err)
+		return
+	}
+	invalidHash := random.NewRandom().GetBytes(48)
+	if _, err := sender.fetchDone(ctx, node2.fPubKey, 1, 1, invalidHash); !errors.Is(err, ErrStreamRejected) {
+		t.Error("success done with invalid hash")
+		return
+	}
+	if err := <-chErr; !errors.Is(err, ErrInvalidHash) {
+		t.Error("stream with invalid hash is not closed by the error")
+		return
+	}
+
+	largeSett := NewSettings(&SSettings{
+		FRoute:         tcRoute + 1,
+		FChunkSize:     tcChunkSize,
+		FMaxStreamSize: tcChunkSize,
+		FRecvTimeout:   time.Minute,
+	})
+	largeStreamer1 := NewStreamer(largeSett, node1)
+	NewStreamer(largeSett, node2).HandleStream(func(_ context.Context, _ asymmetric.IPubKey, pReader io.Reader) {
+		_, err := io.ReadAll(pReader)
+		chErr <- err
+	})
+	if err := largeStreamer1.SendStream(ctx, node2.fPubKey, bytes.NewReader(data)); !errors.Is(err, ErrStreamRejected) {
+		t.Error("success send stream over the size limit")
+		return
+	}
+	if err := <-chErr; !errors.Is(err, ErrStreamSizeLimit) {
+		t.Error("stream over the size limit is not closed by the error")
+		return
+	}
+}
+
+func TestStreamLimit(t *testing.T) {
+	t.Parallel()
+
+	node1, node2 := testNewNodes()
+	sett := NewSettings(&SSettings{
+		FRoute:            tcRoute,
+		FChunkSize:        tcChunkSize,
+		FMaxStreamSize:    (1 << 20),
+		FRecvTimeout:      100 * time.Millisecond,
+		FMaxFriendStreams: 1,
+	})
+	sender := NewStreamer(sett, node1).(*sStreamer)
+
+	chRead := make(chan struct{})
+	chErr := make(chan error, 2)
+	handled := atomic.Int64{}
+	NewStreamer(sett, node2).HandleStream(func(_ context.Context, _ asymmetric.IPubKey, pReader io.Reader) {
+		handled.Add(1)
+		<-chRead
+		_, err := io.ReadAll(pReader)
+		chErr <- err
+	})
+
+	ctx := context.Background()
+	data := random.NewRandom().GetBytes(tcChunkSize)
+
+	if err := sender.sendChunk(ctx, node2.fPubKey, 1, 0, data); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := sender.sendChunk(ctx, node2.fPubKey, 2, 0, data); !errors.Is(err, ErrStreamLimit) {
+		t.Error("success start stream over the limit of friend")
+		return
+	}
+
+	// handler does not read the stream => stream is closed by the timeout of write
+	err1 := testutils.TryN(50, 20*time.Millisecond, func() error {
+		_, err := sender.fetchDone(ctx, node2.fPubKey, 1, 2, random.NewRandom().GetBytes(48))
+		if !errors.Is(err, ErrStreamRejected) {
+			return errors.New("stream is not rejected")
+		}
+		return nil
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+	close(chRead)
+	if err := <-chErr; !errors.Is(err, ErrStreamNotRead) {
+		t.Error("stream is not closed by the timeout of write")
+		return
+	}
+
+	// late chunk of the closed stream does not start the stream again
+	err2 := testutils.TryN(50, 20*time.Millisecond, func() error {
+		err := sender.sendChunk(ctx, node2.fPubKey, 1, 0, data)
+		if !errors.Is(err, ErrStreamClosed) {
+			return errors.New("stream is not closed")
+		}
+		return nil
+	})
+	if err2 != nil {
+		t.Error(err2)
+		return
+	}
+	if handled.Load() != 1 {
+		t.Error("handler is called again by the late chunk")
+		return
+	}
+
+	// closed stream is not counted in the limit of friend
+	if err := sender.sendChunk(ctx, node2.fPubKey, 3, 0, data); err != nil {
+		t.Error(err)
+		return
+	}
+	err3 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		if handled.Load() != 2 {
+			return errors.New("new stream is not handled")
+		}
+		return nil
+	})
+	if err3 != nil {
+		t.Error(err3)
+		return
+	}
+}
+
+func TestFrame(t *testing.T) {
+	t.Parallel()
+
+	frame := &sFrame{fType: cFrameChunk, fID: 1, fSeq: 2, fData: []byte("hello")}
+	loadedFrame, err := loadFrame(frame.toBytes())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if loadedFrame.fID != 1 || loadedFrame.fSeq != 2 || string(loadedFrame.fData) != "hello" {
+		t.Error("loaded frame is not equal frame")
+		return
+	}
+
+	if _, err := loadFrame([]byte{cFrameChunk}); !errors.Is(err, ErrInvalidFrame) {
+		t.Error("success load frame with invalid size")
+		return
+	}
+	invalidFrame := &sFrame{fType: 0}
+	if _, err := loadFrame(invalidFrame.toBytes()); !errors.Is(err, ErrInvalidFrame) {
+		t.Error("success load frame with unknown type")
+		return
+	}
+
+	status, missing, err := loadResponse(newResponse(cStatusMissing, []uint64{3, 7}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if status != cStatusMissing || len(missing) != 2 || missing[0] != 3 || missing[1] != 7 {
+		t.Error("loaded response is not equal response")
+		return
+	}
+	if _, _, err := loadResponse([]byte{cStatusMissing, 1}); !errors.Is(err, ErrInvalidResponse) {
+		t.Error("success load response with invalid size")
+		return
+	}
+}
+
+// Node delivers the payloads directly to the handlers of peer.
+type tsNode struct {
+	anonymity.INode
+
+	fPubKey      asymmetric.IPubKey
+	fQBProcessor queue.IQBProblemProcessor
+	fHandlers    map[uint32]anonymity.IHandlerF
+	fPeer        *tsNode
+	fDropSeqs    map[uint64]bool
+	fDropAlways  bool
+}
+
+func testNewNodes() (*tsNode, *tsNode) {
+	node1, node2 := testNewNode(), testNewNode()
+	node1.fPeer, node2.fPeer = node2, node1
+	return node1, node2
+}
+
+func testNewNode() *tsNode {
+	privKey := asymmetric.NewPrivKey()
+	return &tsNode{
+		fPubKey:   privKey.GetPubKey(),
+		fHandlers: make(map[uint32]anonymity.IHandlerF),
+		fQBProcessor: queue.NewQBProblemProcessor(
+			queue.NewSettings(&queue.SSettings{
+				FMessageConstructSettings: layer1.NewConstructSettings(&layer1.SConstructSettings{
+					FSettings: layer1.NewSettings(&layer1.SSettings{}),
+				}),
+				FQueuePoolCap: [2]uint64{16, 16},
+				FQueuePeriod:  time.Second,
+				FConsumersCap: 1,
+			}),
+			client.NewClient(privKey, tcMsgSize),
+		),
+	}
+}
+
+func testNewStreamers(pNode1, pNode2 *tsNode, pTimeout time.Duration) (IStreamer, IStreamer) {
+	sett := NewSettings(&SSettings{
+		FRoute:         tcRoute,
+		FChunkSize:     tcChunkSize,
+		FMaxStreamSize: (1 << 20),
+		FRecvTimeout:   pTimeout,
+		FRetryCount:    2,
+	})
+	return NewStreamer(sett, pNode1), NewStreamer(sett, pNode2)
+}
+
+func (p *tsNode) HandleFunc(pHead uint32, pHandle anonymity.IHandlerF) anonymity.INode {
+	p.fHandlers[pHead] = pHandle
+	return p
+}
+
+func (p *tsNode) GetQBProcessor() queue.IQBProblemProcessor {
+	return p.fQBProcessor
+}
+
+func (p *tsNode) SendPayload(pCtx context.Context, _ asymmetric.IPubKey, pPld payload.IPayload64) error {
+	if frame, err := loadFrame(pPld.GetBody()); err == nil && p.fDropSeqs[frame.fSeq] {
+		if !p.fDropAlways {
+			delete(p.fDropSeqs, frame.fSeq)
+		}
+		return nil
+	}
+	handler, ok := p.fPeer.fHandlers[uint32(pPld.GetHead())]
+	if !ok {
+		return errors.New("unknown route")
+	}
+	_, err := handler(pCtx, p.fPeer, p.fPubKey, pPld.GetBody())
+	return err
+}
+
+func (p *tsNode) FetchPayload(pCtx context.Context, _ asymmetric.IPubKey, pPld payload.IPayload32) ([]byte, error) {
+	handler, ok := p.fPeer.fHandlers[pPld.GetHead()]
+	if !ok {
+		return nil, errors.New("unknown route")
+	}
+	return handler(pCtx, p.fPeer, p.fPubKey, pPld.GetBody())
+}
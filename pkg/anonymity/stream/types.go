@@ -0,0 +1,29 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+)
+
+type (
+	IHandlerF func(context.Context, asymmetric.IPubKey, io.Reader)
+)
+
+type IStreamer interface {
+	GetSettings() ISettings
+	HandleStream(IHandlerF) IStreamer
+
+	SendStream(context.Context, asymmetric.IPubKey, io.Reader) error
+}
+
+type ISettings interface {
+	GetRoute() uint32
+	GetChunkSize() uint64
+	GetMaxStreamSize() uint64
+	GetRecvTimeout() time.Duration
+	GetRetryCount() uint64
+	GetMaxFriendStreams() uint64
+}
@@ -14,12 +14,19 @@ import (
 )
 
 type (
-	IHandlerF func(context.Context, INode, asymmetric.IPubKey, []byte) ([]byte, error)
+	IHandlerF      func(context.Context, INode, asymmetric.IPubKey, []byte) ([]byte, error)
+	ILateResponseF func(context.Context, INode, asymmetric.IPubKey, uint32, []byte)
+	IMiddlewareF   func(uint32, IHandlerF) IHandlerF
+	IAccessF       func(uint32, asymmetric.IPubKey) bool
 )
 
 type INode interface {
 	types.IRunner
 	HandleFunc(uint32, IHandlerF) INode
+	HandleLateResponse(ILateResponseF) INode
+	UseMiddleware(...IMiddlewareF) INode
+	UseRouteMiddleware(uint32, ...IMiddlewareF) INode
+	HandleAccess(IAccessF) INode
 
 	GetLogger() logger.ILogger
 	GetSettings() ISettings
@@ -30,9 +37,23 @@ type INode interface {
 
 	SendPayload(context.Context, asymmetric.IPubKey, payload.IPayload64) error
 	FetchPayload(context.Context, asymmetric.IPubKey, payload.IPayload32) ([]byte, error)
+	FetchPayloadAsync(context.Context, asymmetric.IPubKey, payload.IPayload32) (IFuture, error)
+}
+
+type IFuture interface {
+	GetAction() uint32
+	Done() <-chan struct{}
+	Result() ([]byte, error)
+	Wait(context.Context) ([]byte, error)
+	Cancel()
 }
 
 type ISettings interface {
 	GetServiceName() string
 	GetFetchTimeout() time.Duration
+	GetRetryCount() uint64
+	GetRetryBackoff() time.Duration
+	GetResponseCacheSize() uint64
+	GetMaxFetchesPerFriend() uint64
+	GetDeniedResponse() bool
 }
@@ -0,0 +1,98 @@
+package puzzle
+
+import (
+	"context"
+	"math"
+
+	"github.com/number571/go-peer/pkg/crypto/hashing"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// Memory (KiB) of the Argon2id by default: 46MiB with one pass and one
+	// thread (minimal configuration of the Argon2id recommended by OWASP).
+	// The memory must be large to lower the advantage of GPU/ASIC, but the
+	// verification of each message is also one hash (~50ms), so the
+	// difficulty must be less than for the SHA-384 proof of work.
+	CArgon2DefaultMemory = 47104
+
+	// Minimal memory (KiB) of the Argon2id (8 * threads).
+	CArgon2MinMemory = 8 * cArgon2Threads
+)
+
+const (
+	cArgon2Time    = 1
+	cArgon2Threads = 1
+)
+
+var (
+	_ IPuzzle = &sArgon2Puzzle{}
+)
+
+var (
+	gArgon2Salt = []byte("go-peer/crypto/puzzle/argon2")
+)
+
+type sArgon2Puzzle struct {
+	fDiff   uint8
+	fMemory uint32
+}
+
+// Memory-hard proof of work (Argon2id). The memory requirement
+// makes the advantage of the GPU/ASIC lower than for the SHA-384.
+// Memory is set in KiB (CArgon2DefaultMemory is used if memory = 0).
+func NewArgon2Puzzle(pDiff, pMemory uint64) IPuzzle {
+	if pDiff > math.MaxUint8 {
+		panic("diff > 256")
+	}
+	if pMemory == 0 {
+		pMemory = CArgon2DefaultMemory
+	}
+	if pMemory < CArgon2MinMemory || pMemory > math.MaxUint32 {
+		panic("invalid memory of argon2")
+	}
+	return &sArgon2Puzzle{
+		fDiff:   uint8(pDiff),
+		fMemory: uint32(pMemory),
+	}
+}
+
+func (p *sArgon2Puzzle) ProofBytes(pPackHash []byte, pParallel uint64) uint64 {
+	// background context is never canceled => proof is always found
+	proof, _, _ := p.ProofBytesWithContext(context.Background(), pPackHash, pParallel, nil)
+	return proof
+}
+
+func (p *sArgon2Puzzle) ProofBytesWithContext(
+	pCtx context.Context,
+	pPackHash []byte,
+	pParallel uint64,
+	pProgress IProgressF,
+) (uint64, uint64, error) {
+	return proofBytes(pCtx, p.fDiff, p.newHash, pPackHash, pParallel, pProgress)
+}
+
+func (p *sArgon2Puzzle) VerifyBytes(pPackHash []byte, pNonce uint64) bool {
+	return verifyBytes(p.fDiff, p.newHash, pPackHash, pNonce)
+}
+
+// Returns the actual work of the proof. Each call is one memory-hard hash,
+// so the work should be saved by the caller (see the layer1 message).
+func (p *sArgon2Puzzle) GetWorkBits(pPackHash []byte, pNonce uint64) uint64 {
+	input := newInput(pPackHash)
+	setNonce(input, pNonce)
+	return countLeadingZeros(p.newHash()(input))
+}
+
+func (p *sArgon2Puzzle) newHash() iHashF {
+	return func(pInput []byte) []byte {
+		return argon2.IDKey(
+			pInput,
+			gArgon2Salt,
+			cArgon2Time,
+			p.fMemory,
+			cArgon2Threads,
+			hashing.CHasherSize,
+		)
+	}
+}
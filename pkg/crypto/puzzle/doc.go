@@ -1,2 +1,6 @@
 // Package puzzle uses a Proof-of-Work algorithm.
+//
+// Two puzzles are supported: SHA-384 (NewPoWPuzzle) and memory-hard
+// Argon2id (NewArgon2Puzzle) with the memory cost in KiB. Both puzzles
+// find the nonce with the hash which starts with 'diff' number of zero bits.
 package puzzle
@@ -0,0 +1,18 @@
+package puzzle
+
+const (
+	errPrefix = "pkg/crypto/puzzle = "
+)
+
+type SPuzzleError struct {
+	str string
+}
+
+func (err *SPuzzleError) Error() string {
+	return errPrefix + err.str
+}
+
+var (
+	ErrProofCanceled = &SPuzzleError{"proof is canceled"}
+	ErrProofNotFound = &SPuzzleError{"proof is not found"}
+)
@@ -0,0 +1,119 @@
+package puzzle
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Period of the progress reports while the proof is computed.
+	cProgressPeriod = 100 * time.Millisecond
+
+	// Number of hashes after which the worker adds them to the counter.
+	cCounterStep = (1 << 10)
+)
+
+var (
+	_ IProgress = &sProgress{}
+)
+
+type sProgress struct {
+	fDiff    uint8
+	fHashes  uint64
+	fElapsed time.Duration
+}
+
+type sCounter struct {
+	fHashes uint64 // atomic variable
+}
+
+func (p *sCounter) addHashes(pHashes uint64) {
+	atomic.AddUint64(&p.fHashes, pHashes)
+}
+
+func (p *sCounter) getHashes() uint64 {
+	return atomic.LoadUint64(&p.fHashes)
+}
+
+// Calls the progress function with the period until the stop function is called.
+// The stop function makes the last report (with the final count of hashes).
+func startProgress(pDiff uint8, pCounter *sCounter, pProgress IProgressF) func() {
+	if pProgress == nil {
+		return func() {}
+	}
+
+	var (
+		start  = time.Now()
+		closed = make(chan struct{})
+		done   = make(chan struct{})
+	)
+
+	report := func() {
+		pProgress(&sProgress{
+			fDiff:    pDiff,
+			fHashes:  pCounter.getHashes(),
+			fElapsed: time.Since(start),
+		})
+	}
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(cProgressPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-closed:
+				return
+			case <-ticker.C:
+				report()
+			}
+		}
+	}()
+
+	return func() {
+		close(closed)
+		<-done
+		report()
+	}
+}
+
+// Returns the number of computed hashes (approximately
+// while the workers are running, with the step of counter).
+func (p *sProgress) GetHashes() uint64 {
+	return p.fHashes
+}
+
+func (p *sProgress) GetElapsed() time.Duration {
+	return p.fElapsed
+}
+
+// Returns the number of hashes per second.
+func (p *sProgress) GetHashrate() float64 {
+	seconds := p.fElapsed.Seconds()
+	if seconds == 0 {
+		return 0
+	}
+	return float64(p.fHashes) / seconds
+}
+
+// Returns the average number of hashes which are needed to find the proof (2^diff).
+func (p *sProgress) GetExpectedHashes() float64 {
+	return math.Exp2(float64(p.fDiff))
+}
+
+// Returns the average duration of the proof with the current hashrate.
+// Returns 0 if the hashrate is not measured yet.
+func (p *sProgress) GetExpectedDuration() time.Duration {
+	hashrate := p.GetHashrate()
+	if hashrate == 0 {
+		return 0
+	}
+	seconds := p.GetExpectedHashes() / hashrate
+	if seconds >= math.MaxInt64/float64(time.Second) {
+		return math.MaxInt64
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
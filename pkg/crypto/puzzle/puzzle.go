@@ -1,17 +1,11 @@
 package puzzle
 
 import (
-	"bytes"
+	"context"
+	"crypto/sha512"
 	"math"
-	"math/big"
-	"runtime"
 
 	"github.com/number571/go-peer/pkg/crypto/hashing"
-	"github.com/number571/go-peer/pkg/encoding"
-)
-
-const (
-	cHashSizeInBits = hashing.CHasherSize * 8
 )
 
 var (
@@ -22,6 +16,19 @@ type sPoWPuzzle struct {
 	fDiff uint8
 }
 
+// Creates the puzzle of the type. Panics if the type is unknown.
+// Memory (KiB) is used only by the memory-hard puzzles.
+func NewPuzzle(pType IPuzzleType, pDiff, pMemory uint64) IPuzzle {
+	switch pType {
+	case CPuzzleTypePoW:
+		return NewPoWPuzzle(pDiff)
+	case CPuzzleTypeArgon2:
+		return NewArgon2Puzzle(pDiff, pMemory)
+	default:
+		panic("unknown puzzle type")
+	}
+}
+
 func NewPoWPuzzle(pDiff uint64) IPuzzle {
 	if pDiff > math.MaxUint8 {
 		panic("diff > 256")
@@ -34,69 +41,49 @@ func NewPoWPuzzle(pDiff uint64) IPuzzle {
 // Proof of work by the method of finding the desired hash.
 // Hash must start with 'diff' number of zero bits.
 func (p *sPoWPuzzle) ProofBytes(pPackHash []byte, pParallel uint64) uint64 {
-	var (
-		closed = make(chan struct{})
-		target = big.NewInt(1)
-	)
-
-	maxParallel := uint64(runtime.GOMAXPROCS(0))
-	setParallel := pParallel
-	if pParallel == 0 {
-		setParallel = 1
-	}
-	if pParallel > maxParallel {
-		setParallel = maxParallel
-	}
-
-	chNonce := make(chan uint64, setParallel)
-	packHash := make([]byte, len(pPackHash))
-	copy(packHash, pPackHash)
-
-	target.Lsh(target, cHashSizeInBits-uint(p.fDiff))
-	for i := uint64(0); i < setParallel; i++ {
-		go func(i uint64) {
-			intHash := big.NewInt(1)
-			for nonce := i; nonce < math.MaxUint64; nonce += setParallel {
-				select {
-				case <-closed:
-					return
-				default:
-					bNonce := encoding.Uint64ToBytes(nonce)
-					hash := hashing.NewHasher(bytes.Join(
-						[][]byte{packHash, bNonce[:]},
-						[]byte{},
-					)).ToBytes()
-					intHash.SetBytes(hash)
-					if intHash.Cmp(target) == -1 {
-						chNonce <- nonce
-						return
-					}
-				}
-			}
-		}(i)
-	}
+	// background context is never canceled => proof is always found
+	proof, _, _ := p.ProofBytesWithContext(context.Background(), pPackHash, pParallel, nil)
+	return proof
+}
 
-	result := <-chNonce
-	close(closed)
-	return result
+// Proof of work which stops all workers when the context is done.
+// The progress function (can be = nil) receives the count of hashes
+// and the hashrate periodically and after the end of work. Returns the
+// proof with its actual work, so the work is not computed again.
+func (p *sPoWPuzzle) ProofBytesWithContext(
+	pCtx context.Context,
+	pPackHash []byte,
+	pParallel uint64,
+	pProgress IProgressF,
+) (uint64, uint64, error) {
+	return proofBytes(pCtx, p.fDiff, newPoWHash, pPackHash, pParallel, pProgress)
 }
 
 // Verifies the work of the proof of work function.
 func (p *sPoWPuzzle) VerifyBytes(pPackHash []byte, pNonce uint64) bool {
-	var (
-		intHash = big.NewInt(1)
-		target  = big.NewInt(1)
-	)
+	// state of hash is not reused, so the hash is computed on the stack
+	input := newInput(pPackHash)
+	setNonce(input, pNonce)
+	hash := sha512.Sum384(input)
+	return hasLeadingZeros(hash[:], p.fDiff)
+}
 
-	packHash := make([]byte, len(pPackHash))
-	copy(packHash, pPackHash)
+// Returns the actual work of the proof (number of leading zero bits of the
+// hash). The work can be more than the difficulty of the puzzle.
+func (p *sPoWPuzzle) GetWorkBits(pPackHash []byte, pNonce uint64) uint64 {
+	input := newInput(pPackHash)
+	setNonce(input, pNonce)
+	hash := sha512.Sum384(input)
+	return countLeadingZeros(hash[:])
+}
 
-	target.Lsh(target, cHashSizeInBits-uint(p.fDiff))
-	bNonce := encoding.Uint64ToBytes(pNonce)
-	hash := hashing.NewHasher(bytes.Join(
-		[][]byte{packHash, bNonce[:]},
-		[]byte{},
-	)).ToBytes()
-	intHash.SetBytes(hash)
-	return intHash.Cmp(target) == -1
+// SHA-384 with the reused state and the reused output.
+func newPoWHash() iHashF {
+	hasher := sha512.New384()
+	output := make([]byte, 0, hashing.CHasherSize)
+	return func(pInput []byte) []byte {
+		hasher.Reset()
+		_, _ = hasher.Write(pInput)
+		return hasher.Sum(output[:0])
+	}
 }
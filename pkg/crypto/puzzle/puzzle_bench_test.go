@@ -1,6 +1,7 @@
 package puzzle
 
 import (
+	"fmt"
 	"runtime"
 	"testing"
 	"time"
@@ -151,3 +152,93 @@ func BenchmarkPuzzleParallel(b *testing.B) {
 		})
 	}
 }
+
+/*
+goos: linux
+goarch: amd64
+pkg: github.com/number571/go-peer/pkg/crypto/puzzle
+cpu: Intel(R) Xeon(R) Processor
+BenchmarkPuzzleVerify/legacy         	 1664564	       729.4 ns/op	     336 B/op	       8 allocs/op
+BenchmarkPuzzleVerify/current        	 3675627	       374.0 ns/op	       0 B/op	       0 allocs/op
+PASS
+*/
+
+// go test -bench=BenchmarkPuzzleVerify -benchmem
+func BenchmarkPuzzleVerify(b *testing.B) {
+	hash := testutils.PseudoRandomBytes(1)
+
+	b.Run("legacy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = testLegacyVerifyBytes(hash, uint64(i), 20)
+		}
+	})
+
+	b.Run("current", func(b *testing.B) {
+		puzzle := NewPoWPuzzle(20)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = puzzle.VerifyBytes(hash, uint64(i))
+		}
+	})
+}
+
+/*
+goos: linux
+goarch: amd64
+pkg: github.com/number571/go-peer/pkg/crypto/puzzle
+cpu: Intel(R) Xeon(R) Processor
+BenchmarkPuzzleDiff/worksize=10-bit/legacy         	      10	   1789175 ns/op
+BenchmarkPuzzleDiff/worksize=10-bit/current        	      10	    782752 ns/op
+BenchmarkPuzzleDiff/worksize=12-bit/legacy         	      10	   7406191 ns/op
+BenchmarkPuzzleDiff/worksize=12-bit/current        	      10	   2817855 ns/op
+BenchmarkPuzzleDiff/worksize=14-bit/legacy         	      10	   9330566 ns/op
+BenchmarkPuzzleDiff/worksize=14-bit/current        	      10	   3901818 ns/op
+BenchmarkPuzzleDiff/worksize=16-bit/legacy         	      10	  95948680 ns/op
+BenchmarkPuzzleDiff/worksize=16-bit/current        	      10	  47524202 ns/op
+BenchmarkPuzzleDiff/worksize=18-bit/legacy         	      10	 422965390 ns/op
+BenchmarkPuzzleDiff/worksize=18-bit/current        	      10	 188908783 ns/op
+BenchmarkPuzzleDiff/worksize=20-bit/legacy         	      10	1015994267 ns/op
+BenchmarkPuzzleDiff/worksize=20-bit/current        	      10	 460329405 ns/op
+BenchmarkPuzzleDiff/worksize=22-bit/legacy         	      10	3580970572 ns/op
+BenchmarkPuzzleDiff/worksize=22-bit/current        	      10	1685985696 ns/op
+BenchmarkPuzzleDiff/worksize=24-bit/legacy         	      10	17272840831 ns/op
+BenchmarkPuzzleDiff/worksize=24-bit/current        	      10	8081271449 ns/op
+PASS
+
+legacy = implementation with the math/big and bytes.Join (parallel = 1).
+*/
+
+// go test -bench=BenchmarkPuzzleDiff -benchtime=10x -timeout 99999s
+func BenchmarkPuzzleDiff(b *testing.B) {
+	for diff := uint64(10); diff <= 24; diff += 2 {
+		puzzle := NewPoWPuzzle(diff)
+		functions := []struct {
+			name     string
+			function func([]byte) uint64
+		}{
+			{
+				name:     fmt.Sprintf("worksize=%d-bit/legacy", diff),
+				function: func(h []byte) uint64 { return testLegacyProofBytes(h, diff) },
+			},
+			{
+				name:     fmt.Sprintf("worksize=%d-bit/current", diff),
+				function: func(h []byte) uint64 { return puzzle.ProofBytes(h, 1) },
+			},
+		}
+		for _, f := range functions {
+			f := f
+			b.Run(f.name, func(b *testing.B) {
+				b.StopTimer()
+				randomBytes := make([][]byte, 0, b.N)
+				for i := 0; i < b.N; i++ {
+					randomBytes = append(randomBytes, testutils.PseudoRandomBytes(i))
+				}
+				b.StartTimer()
+				for i := 0; i < b.N; i++ {
+					_ = f.function(randomBytes[i])
+				}
+			})
+		}
+	}
+}
@@ -1,8 +1,14 @@
 package puzzle
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"math/big"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/number571/go-peer/pkg/crypto/hashing"
 	"github.com/number571/go-peer/pkg/encoding"
@@ -63,3 +69,204 @@ func TestMultiPuzzle(t *testing.T) {
 		_ = puzzle.ProofBytes(arr[:], parallel)
 	}
 }
+
+func TestPuzzleContext(t *testing.T) {
+	t.Parallel()
+
+	var (
+		puzzle = NewPoWPuzzle(10)
+		hash   = hashing.NewHasher([]byte("hello, world!")).ToBytes()
+	)
+
+	var lastProgress IProgress
+	proof, work, err := puzzle.ProofBytesWithContext(context.Background(), hash, 2, func(p IProgress) {
+		lastProgress = p
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !puzzle.VerifyBytes(hash, proof) {
+		t.Error("proof is invalid")
+		return
+	}
+	if work != puzzle.GetWorkBits(hash, proof) {
+		t.Error("work of the proof is invalid")
+		return
+	}
+	if lastProgress == nil || lastProgress.GetHashes() == 0 {
+		t.Error("progress is not reported")
+		return
+	}
+	if lastProgress.GetExpectedHashes() != 1<<10 {
+		t.Error("invalid expected hashes")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	chProgress := make(chan IProgress, 64)
+	_, _, err = NewPoWPuzzle(64).ProofBytesWithContext(ctx, hash, 2, func(p IProgress) {
+		select {
+		case chProgress <- p:
+		default:
+		}
+	})
+	if !errors.Is(err, ErrProofCanceled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("proof is not canceled (%v)", err)
+		return
+	}
+
+	// the progress is reported periodically and after the end
+	if len(chProgress) < 2 {
+		t.Error("progress is not reported periodically")
+		return
+	}
+	for len(chProgress) > 1 {
+		<-chProgress
+	}
+	progress := <-chProgress
+	if progress.GetHashrate() == 0 || progress.GetExpectedDuration() <= progress.GetElapsed() {
+		t.Error("invalid hashrate of progress")
+		return
+	}
+}
+
+func TestArgon2Puzzle(t *testing.T) {
+	t.Parallel()
+
+	var (
+		puzzle = NewPuzzle(CPuzzleTypeArgon2, 6, (1 << 10))
+		hash   = hashing.NewHasher([]byte("hello, world!")).ToBytes()
+	)
+
+	proof := puzzle.ProofBytes(hash, 1)
+	if !puzzle.VerifyBytes(hash, proof) {
+		t.Error("proof is invalid")
+		return
+	}
+
+	// verifier must use the same type of puzzle
+	if NewPuzzle(CPuzzleTypePoW, 6, 0).VerifyBytes(hash, proof) {
+		t.Error("argon2 proof is valid for the sha384 puzzle")
+		return
+	}
+
+	hash[3] ^= 8
+	if puzzle.VerifyBytes(hash, proof) {
+		t.Error("proof is correct?")
+		return
+	}
+}
+
+func TestPuzzleType(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("nothing panics")
+			return
+		}
+	}()
+	_ = NewPuzzle(CPuzzleTypeArgon2+1, 10, 0)
+}
+
+func TestArgon2Memory(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("nothing panics")
+			return
+		}
+	}()
+	_ = NewArgon2Puzzle(10, CArgon2MinMemory-1)
+}
+
+func TestPuzzleCompatibility(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 64; i++ {
+		arr := encoding.Uint64ToBytes(uint64(i))
+		hash := hashing.NewHasher(arr[:]).ToBytes()
+		diff := uint64(i % 12)
+
+		// proofs of the previous implementation are valid
+		proof := testLegacyProofBytes(hash, diff)
+		if !NewPoWPuzzle(diff).VerifyBytes(hash, proof) {
+			t.Error("legacy proof is invalid")
+			return
+		}
+		if NewPoWPuzzle(diff).ProofBytes(hash, 1) != proof {
+			t.Error("proof is not equal legacy proof")
+			return
+		}
+		for nonce := uint64(0); nonce < 16; nonce++ {
+			for _, d := range []uint64{0, 1, 7, 8, 9, 255} {
+				if NewPoWPuzzle(d).VerifyBytes(hash, nonce) != testLegacyVerifyBytes(hash, nonce, d) {
+					t.Error("verify is not equal legacy verify")
+					return
+				}
+			}
+		}
+	}
+}
+
+// Implementation of the puzzle with the math/big (before optimization).
+func testLegacyProofBytes(pPackHash []byte, pDiff uint64) uint64 {
+	for nonce := uint64(0); nonce < math.MaxUint64; nonce++ {
+		if testLegacyVerifyBytes(pPackHash, nonce, pDiff) {
+			return nonce
+		}
+	}
+	panic("nonce is not found")
+}
+
+func testLegacyVerifyBytes(pPackHash []byte, pNonce, pDiff uint64) bool {
+	var (
+		intHash = big.NewInt(1)
+		target  = big.NewInt(1)
+	)
+
+	target.Lsh(target, hashing.CHasherSize*8-uint(pDiff))
+	bNonce := encoding.Uint64ToBytes(pNonce)
+	hash := hashing.NewHasher(bytes.Join(
+		[][]byte{pPackHash, bNonce[:]},
+		[]byte{},
+	)).ToBytes()
+	intHash.SetBytes(hash)
+	return intHash.Cmp(target) == -1
+}
+
+func TestPuzzleWorkBits(t *testing.T) {
+	t.Parallel()
+
+	hash := hashing.NewHasher([]byte("hello, world!")).ToBytes()
+
+	proof := NewPoWPuzzle(8).ProofBytes(hash, 1)
+	work := NewPoWPuzzle(8).GetWorkBits(hash, proof)
+	if work < 8 {
+		t.Error("work of proof < difficulty")
+		return
+	}
+	if !NewPoWPuzzle(work).VerifyBytes(hash, proof) || NewPoWPuzzle(work+1).VerifyBytes(hash, proof) {
+		t.Error("work of proof is not equal verification")
+		return
+	}
+
+	argonProof := NewArgon2Puzzle(4, (1<<10)).ProofBytes(hash, 1)
+	if NewArgon2Puzzle(4, (1<<10)).GetWorkBits(hash, argonProof) < 4 {
+		t.Error("work of argon2 proof < difficulty")
+		return
+	}
+
+	if countLeadingZeros([]byte{0, 0x10, 0xFF}) != 11 {
+		t.Error("invalid count of leading zeros")
+		return
+	}
+	if countLeadingZeros([]byte{0, 0}) != 16 {
+		t.Error("invalid count of leading zeros (zero hash)")
+		return
+	}
+}
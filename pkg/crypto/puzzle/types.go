@@ -1,6 +1,32 @@
 package puzzle
 
+import (
+	"context"
+	"time"
+)
+
+type (
+	IPuzzleType uint8
+)
+
+const (
+	CPuzzleTypePoW    IPuzzleType = iota // SHA-384 (default)
+	CPuzzleTypeArgon2                    // Argon2id (memory-hard)
+)
+
+type IProgressF func(IProgress)
+
 type IPuzzle interface {
 	ProofBytes([]byte, uint64) uint64
+	ProofBytesWithContext(context.Context, []byte, uint64, IProgressF) (uint64, uint64, error)
 	VerifyBytes([]byte, uint64) bool
+	GetWorkBits([]byte, uint64) uint64
+}
+
+type IProgress interface {
+	GetHashes() uint64
+	GetElapsed() time.Duration
+	GetHashrate() float64
+	GetExpectedHashes() float64
+	GetExpectedDuration() time.Duration
 }
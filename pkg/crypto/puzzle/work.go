@@ -0,0 +1,168 @@
+package puzzle
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"runtime"
+	"sync"
+
+	"github.com/number571/go-peer/pkg/encoding"
+)
+
+// Returns the hash of the input (packed hash || nonce). The result
+// can be overwritten by the next call (state of hash is reused).
+type iHashF func([]byte) []byte
+
+// Creates the hash function for one worker.
+type iNewHashF func() iHashF
+
+// Found nonce with the work (leading zero bits) of its hash.
+type sProof struct {
+	fNonce uint64
+	fWork  uint64
+}
+
+// Finds the nonce with the hash which starts with 'diff' number of zero bits.
+// Workers are stopped when the nonce is found or when the context is done.
+// Returns the nonce and the actual work of its hash (not less than diff).
+func proofBytes(
+	pCtx context.Context,
+	pDiff uint8,
+	pNewHashF iNewHashF,
+	pPackHash []byte,
+	pParallel uint64,
+	pProgress IProgressF,
+) (uint64, uint64, error) {
+	counter := &sCounter{}
+
+	maxParallel := uint64(runtime.GOMAXPROCS(0))
+	setParallel := pParallel
+
+	if pParallel == 0 {
+		setParallel = 1
+	}
+	if pParallel > maxParallel {
+		setParallel = maxParallel
+	}
+
+	ctx, cancel := context.WithCancel(pCtx)
+	defer cancel()
+
+	chProof := make(chan sProof, setParallel)
+
+	wg := sync.WaitGroup{}
+	wg.Add(int(setParallel))
+
+	stopProgress := startProgress(pDiff, counter, pProgress)
+	for i := uint64(0); i < setParallel; i++ {
+		go func(i uint64) {
+			defer wg.Done()
+
+			hashes := uint64(0)
+			defer func() { counter.addHashes(hashes) }()
+
+			// nonce is written in place of the input
+			input := newInput(pPackHash)
+			hashF := pNewHashF()
+
+			for nonce := i; nonce < math.MaxUint64; nonce += setParallel {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					setNonce(input, nonce)
+					if hash := hashF(input); hasLeadingZeros(hash, pDiff) {
+						chProof <- sProof{fNonce: nonce, fWork: countLeadingZeros(hash)}
+						return
+					}
+					if hashes++; hashes == cCounterStep {
+						counter.addHashes(hashes)
+						hashes = 0
+					}
+				}
+			}
+		}(i)
+	}
+
+	// all workers are stopped before the return
+	chDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(chDone)
+	}()
+
+	var (
+		result sProof
+		err    error
+	)
+
+	select {
+	case <-pCtx.Done():
+		err = errors.Join(ErrProofCanceled, pCtx.Err())
+	case result = <-chProof:
+	case <-chDone:
+		select {
+		case result = <-chProof:
+		default:
+			err = ErrProofNotFound
+		}
+	}
+
+	cancel()
+	<-chDone
+	stopProgress()
+
+	return result.fNonce, result.fWork, err
+}
+
+// Verifies the hash of the packed hash with the nonce.
+func verifyBytes(pDiff uint8, pNewHashF iNewHashF, pPackHash []byte, pNonce uint64) bool {
+	input := newInput(pPackHash)
+	setNonce(input, pNonce)
+	return hasLeadingZeros(pNewHashF()(input), pDiff)
+}
+
+// Returns the input = packed hash || nonce (uint64, big endian).
+func newInput(pPackHash []byte) []byte {
+	input := make([]byte, len(pPackHash)+encoding.CSizeUint64)
+	copy(input, pPackHash)
+	return input
+}
+
+func setNonce(pInput []byte, pNonce uint64) {
+	binary.BigEndian.PutUint64(pInput[len(pInput)-encoding.CSizeUint64:], pNonce)
+}
+
+// Returns the number of zero bits at the start of hash.
+func countLeadingZeros(pHash []byte) uint64 {
+	count := uint64(0)
+	for _, b := range pHash {
+		if b != 0 {
+			return count + uint64(bits.LeadingZeros8(b))
+		}
+		count += 8
+	}
+	return count
+}
+
+// Checks that the hash starts with 'diff' number of zero bits.
+// It is equal to the check: hash (as big endian number) < 2^(size-diff).
+func hasLeadingZeros(pHash []byte, pDiff uint8) bool {
+	fullBytes := int(pDiff / 8)
+	if fullBytes >= len(pHash) {
+		return false
+	}
+	for _, b := range pHash[:fullBytes] {
+		if b != 0 {
+			return false
+		}
+	}
+	restBits := pDiff % 8
+	if restBits == 0 {
+		return true
+	}
+	return pHash[fullBytes]>>(8-restBits) == 0
+}
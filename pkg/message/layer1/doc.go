@@ -14,6 +14,9 @@
 			P - proof of work
 			E - encrypt
 
+	The work of message (leading zero bits of the puzzle hash) is not
+	written into the format, it is derived from P(HM) on loading.
+
 	Scheme: https://github.com/number571/go-peer/blob/master/images/go-peer_layer1_message.jpg
 */
 package layer1
@@ -21,4 +21,5 @@ var (
 	ErrInvalidAuthHash    = &SMessageError{"got invalid auth hash"}
 	ErrInvalidTimestamp   = &SMessageError{"got invalid timestamp"}
 	ErrDecodePayload      = &SMessageError{"decode payload"}
+	ErrMakeProofOfWork    = &SMessageError{"make proof of work"}
 )
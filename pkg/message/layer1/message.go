@@ -2,6 +2,8 @@ package layer1
 
 import (
 	"bytes"
+	"context"
+	"errors"
 
 	"github.com/number571/go-peer/pkg/crypto/hashing"
 	"github.com/number571/go-peer/pkg/crypto/keybuilder"
@@ -34,10 +36,18 @@ type sMessage struct {
 	fEncd    []byte             // E( K, P(HM) || HM || M )
 	fHash    []byte             // HM = H( K, M )
 	fProof   uint64             // P(HM)
+	fWork    uint64             // leading zero bits of H(P(HM)), not in the format
 	fPayload payload.IPayload32 // M
 }
 
 func NewMessage(pSett IConstructSettings, pPld payload.IPayload32) IMessage {
+	// background context is never canceled => message is always created
+	msg, _ := NewMessageWithContext(context.Background(), pSett, pPld)
+	return msg
+}
+
+// Creates the message, but the proof of work is stopped when the context is done.
+func NewMessageWithContext(pCtx context.Context, pSett IConstructSettings, pPld payload.IPayload32) (IMessage, error) {
 	sett := pSett.GetSettings()
 	pldBytes := pPld.ToBytes()
 
@@ -45,7 +55,17 @@ func NewMessage(pSett IConstructSettings, pPld payload.IPayload32) IMessage {
 	key := keyBuilder.Build(sett.GetNetworkKey(), symmetric.CCipherKeySize)
 	hash := hashing.NewHMACHasher(key, pldBytes).ToBytes()
 
-	proof := puzzle.NewPoWPuzzle(sett.GetWorkSizeBits()).ProofBytes(hash, pSett.GetParallel())
+	puzzle := puzzle.NewPuzzle(sett.GetPuzzleType(), pSett.GetWorkSizeBits(), sett.GetPuzzleMemory())
+	// work is returned by the solver, so the puzzle is not hashed again
+	proof, work, err := puzzle.ProofBytesWithContext(
+		pCtx,
+		hash,
+		pSett.GetParallel(),
+		pSett.GetProgress(),
+	)
+	if err != nil {
+		return nil, errors.Join(ErrMakeProofOfWork, err)
+	}
 	proofBytes := encoding.Uint64ToBytes(proof)
 
 	cipher := symmetric.NewCipher(key)
@@ -60,8 +80,9 @@ func NewMessage(pSett IConstructSettings, pPld payload.IPayload32) IMessage {
 		)),
 		fHash:    hash,
 		fProof:   proof,
+		fWork:    work,
 		fPayload: pPld,
-	}
+	}, nil
 }
 
 func LoadMessage(pSett ISettings, pData interface{}) (IMessage, error) {
@@ -84,30 +105,55 @@ func LoadMessage(pSett ISettings, pData interface{}) (IMessage, error) {
 	key := keyBuilder.Build(pSett.GetNetworkKey(), symmetric.CCipherKeySize)
 	dBytes := symmetric.NewCipher(key).DecryptBytes(msgBytes)
 
+	return loadMessage(pSett, key, msgBytes, dBytes)
+}
+
+// Loads the message from the bytes without the encryption by the network
+// key (see ToPlainBytes). The message is encrypted again with the new IV.
+func LoadPlainMessage(pSett ISettings, pPlainBytes []byte) (IMessage, error) {
+	if len(pPlainBytes) < CMessageHeadSize-symmetric.CCipherBlockSize {
+		return nil, ErrInvalidHeaderSize
+	}
+
+	keyBuilder := keybuilder.NewKeyBuilder(0, []byte{}) // the network_key must have good entropy
+	key := keyBuilder.Build(pSett.GetNetworkKey(), symmetric.CCipherKeySize)
+	msgBytes := symmetric.NewCipher(key).EncryptBytes(pPlainBytes)
+
+	return loadMessage(pSett, key, msgBytes, pPlainBytes)
+}
+
+func loadMessage(pSett ISettings, pKey, pMsgBytes, pDecBytes []byte) (IMessage, error) {
+	dBytes := pDecBytes
+
 	proofArr := [encoding.CSizeUint64]byte{}
 	copy(proofArr[:], dBytes[:cProofIndex])
 	proof := encoding.BytesToUint64(proofArr)
 
+	// the cheap check of hash is done before the puzzle (can be memory-hard)
 	hash := dBytes[cProofIndex:cHashIndex]
-	puzzle := puzzle.NewPoWPuzzle(pSett.GetWorkSizeBits())
-	if !puzzle.VerifyBytes(hash, proof) {
-		return nil, ErrInvalidProofOfWork
-	}
-
-	newHash := hashing.NewHMACHasher(key, dBytes[cHashIndex:]).ToBytes()
+	newHash := hashing.NewHMACHasher(pKey, dBytes[cHashIndex:]).ToBytes()
 	if !bytes.Equal(hash, newHash) {
 		return nil, ErrInvalidAuthHash
 	}
 
+	// the work can be more than the minimum of settings, it is
+	// derived by the one hash which verifies the proof of work
+	puzzle := puzzle.NewPuzzle(pSett.GetPuzzleType(), pSett.GetWorkSizeBits(), pSett.GetPuzzleMemory())
+	work := puzzle.GetWorkBits(hash, proof)
+	if work < pSett.GetWorkSizeBits() {
+		return nil, ErrInvalidProofOfWork
+	}
+
 	payload := payload.LoadPayload32(dBytes[cHashIndex:])
 	if payload == nil {
 		return nil, ErrDecodePayload
 	}
 
 	return &sMessage{
-		fEncd:    msgBytes,
+		fEncd:    pMsgBytes,
 		fHash:    hash,
 		fProof:   proof,
+		fWork:    work,
 		fPayload: payload,
 	}, nil
 }
@@ -116,6 +162,17 @@ func (p *sMessage) GetProof() uint64 {
 	return p.fProof
 }
 
+// Returns the actual work of the proof. It is not less than
+// the work of settings, but it can be more (see construct settings).
+// The work is not written into the message format, it is derived from
+// the leading zero bits of the puzzle hash: by the solver on creation and
+// by the same hash which verifies the proof on loading. So the value is
+// cached in the message (recompute by the puzzle costs one more hash,
+// ~50ms and 46MiB for Argon2).
+func (p *sMessage) GetWorkSizeBits() uint64 {
+	return p.fWork
+}
+
 func (p *sMessage) GetHash() []byte {
 	return p.fHash
 }
@@ -128,6 +185,20 @@ func (p *sMessage) ToBytes() []byte {
 	return p.fEncd
 }
 
+// Returns the bytes without the encryption by the network key.
+// The result must be protected by another encryption (link).
+func (p *sMessage) ToPlainBytes() []byte {
+	proofBytes := encoding.Uint64ToBytes(p.fProof)
+	return bytes.Join(
+		[][]byte{
+			proofBytes[:],
+			p.fHash,
+			p.fPayload.ToBytes(),
+		},
+		[]byte{},
+	)
+}
+
 func (p *sMessage) ToString() string {
 	return encoding.HexEncode(p.ToBytes())
 }
@@ -2,6 +2,8 @@ package layer1
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/number571/go-peer/pkg/crypto/hashing"
@@ -53,7 +55,7 @@ func TestError(t *testing.T) {
 func TestSettings(t *testing.T) {
 	t.Parallel()
 
-	for i := 0; i < 1; i++ {
+	for i := 0; i < 4; i++ {
 		testSettings(t, i)
 	}
 }
@@ -65,9 +67,21 @@ func testSettings(t *testing.T, n int) {
 			return
 		}
 	}()
-	switch n { // nolint: gocritic
+	switch n {
 	case 0:
 		_ = NewConstructSettings(&SConstructSettings{})
+	case 1:
+		_ = NewSettings(&SSettings{FPuzzleType: puzzle.CPuzzleTypeArgon2 + 1})
+	case 2:
+		_ = NewConstructSettings(&SConstructSettings{
+			FSettings:     NewSettings(&SSettings{FWorkSizeBits: 10}),
+			FWorkSizeBits: 5,
+		})
+	case 3:
+		_ = NewSettings(&SSettings{
+			FPuzzleType:   puzzle.CPuzzleTypeArgon2,
+			FPuzzleMemory: puzzle.CArgon2MinMemory - 1,
+		})
 	}
 }
 
@@ -221,6 +235,173 @@ func TestMessage(t *testing.T) {
 	}
 }
 
+func TestMessageWithContext(t *testing.T) {
+	t.Parallel()
+
+	pld := payload.NewPayload32(tcHead, []byte(tcBody))
+	progressCount := 0
+	sett := NewConstructSettings(&SConstructSettings{
+		FSettings: NewSettings(&SSettings{
+			FWorkSizeBits: tcWorkSize,
+			FNetworkKey:   tcNetworkKey,
+		}),
+		FProgress: func(_ puzzle.IProgress) { progressCount++ },
+	})
+
+	msg, err := NewMessageWithContext(context.Background(), sett, pld)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := LoadMessage(sett.GetSettings(), msg.ToBytes()); err != nil {
+		t.Error(err)
+		return
+	}
+	if progressCount == 0 {
+		t.Error("progress is not reported")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	hardSett := NewConstructSettings(&SConstructSettings{
+		FSettings: NewSettings(&SSettings{
+			FWorkSizeBits: 64,
+		}),
+	})
+	if _, err := NewMessageWithContext(ctx, hardSett, pld); !errors.Is(err, ErrMakeProofOfWork) {
+		t.Error("success create message with canceled context")
+		return
+	}
+}
+
+func TestArgon2Message(t *testing.T) {
+	t.Parallel()
+
+	pld := payload.NewPayload32(tcHead, []byte(tcBody))
+	sett := NewConstructSettings(&SConstructSettings{
+		FSettings: NewSettings(&SSettings{
+			FWorkSizeBits: 4,
+			FNetworkKey:   tcNetworkKey,
+			FPuzzleType:   puzzle.CPuzzleTypeArgon2,
+			FPuzzleMemory: (1 << 10),
+		}),
+	})
+
+	msg := NewMessage(sett, pld)
+	if _, err := LoadMessage(sett.GetSettings(), msg.ToBytes()); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !puzzle.NewArgon2Puzzle(4, (1<<10)).VerifyBytes(msg.GetHash(), msg.GetProof()) {
+		t.Error("message is not proved by the argon2 puzzle")
+		return
+	}
+
+	// the hash is checked before the puzzle
+	otherSett := NewSettings(&SSettings{
+		FWorkSizeBits: 64,
+		FNetworkKey:   tcNetworkKey + "_other",
+		FPuzzleType:   puzzle.CPuzzleTypeArgon2,
+	})
+	if _, err := LoadMessage(otherSett, msg.ToBytes()); !errors.Is(err, ErrInvalidAuthHash) {
+		t.Error("puzzle is checked before the hash")
+		return
+	}
+}
+
+func TestMessageWorkSize(t *testing.T) {
+	t.Parallel()
+
+	pld := payload.NewPayload32(tcHead, []byte(tcBody))
+	sett := NewConstructSettings(&SConstructSettings{
+		FSettings: NewSettings(&SSettings{
+			FWorkSizeBits: tcWorkSize,
+			FNetworkKey:   tcNetworkKey,
+		}),
+		FWorkSizeBits: tcWorkSize + 4,
+	})
+
+	if sett.GetWorkSizeBits() != tcWorkSize+4 {
+		t.Error("invalid work size of construct settings")
+		return
+	}
+
+	msg := NewMessage(sett, pld)
+	if msg.GetWorkSizeBits() < tcWorkSize+4 {
+		t.Error("work of message < work of construct settings")
+		return
+	}
+
+	msgL, err := LoadMessage(sett.GetSettings(), msg.ToBytes())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if msgL.GetWorkSizeBits() != msg.GetWorkSizeBits() {
+		t.Error("work of loaded message != work of message")
+		return
+	}
+
+	// the message with the work less than minimum is rejected
+	strictSett := NewSettings(&SSettings{
+		FWorkSizeBits: msg.GetWorkSizeBits() + 1,
+		FNetworkKey:   tcNetworkKey,
+	})
+	if _, err := LoadMessage(strictSett, msg.ToBytes()); !errors.Is(err, ErrInvalidProofOfWork) {
+		t.Error("success load message with work < minimum")
+		return
+	}
+
+	defaultSett := NewConstructSettings(&SConstructSettings{
+		FSettings: sett.GetSettings(),
+	})
+	if defaultSett.GetWorkSizeBits() != tcWorkSize {
+		t.Error("default work size != work size of settings")
+		return
+	}
+}
+
+func TestPlainMessage(t *testing.T) {
+	t.Parallel()
+
+	pld := payload.NewPayload32(tcHead, []byte(tcBody))
+	sett := NewConstructSettings(&SConstructSettings{
+		FSettings: NewSettings(&SSettings{
+			FWorkSizeBits: tcWorkSize,
+			FNetworkKey:   tcNetworkKey,
+		}),
+	})
+
+	msg := NewMessage(sett, pld)
+	msgPlain, err := LoadPlainMessage(sett.GetSettings(), msg.ToPlainBytes())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !bytes.Equal(msg.GetHash(), msgPlain.GetHash()) || msg.GetProof() != msgPlain.GetProof() {
+		t.Error("plain message not equal message")
+		return
+	}
+	if bytes.Equal(msg.ToBytes(), msgPlain.ToBytes()) {
+		t.Error("plain message is not encrypted with new iv")
+		return
+	}
+
+	if _, err := LoadMessage(sett.GetSettings(), msgPlain.ToBytes()); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err := LoadPlainMessage(sett.GetSettings(), []byte{1}); !errors.Is(err, ErrInvalidHeaderSize) {
+		t.Error("success load plain message with invalid size")
+		return
+	}
+}
+
 func tNewInvalidMessage1(pSett IConstructSettings, pPld payload.IPayload32) IMessage {
 	sett := pSett.GetSettings()
 
@@ -1,5 +1,11 @@
 package layer1
 
+import (
+	"math"
+
+	"github.com/number571/go-peer/pkg/crypto/puzzle"
+)
+
 var (
 	_ IConstructSettings = &sConstructSettings{}
 	_ ISettings          = &sSettings{}
@@ -7,20 +13,26 @@ var (
 
 type SConstructSettings sConstructSettings
 type sConstructSettings struct {
-	FSettings ISettings
-	FParallel uint64
+	FSettings     ISettings
+	FParallel     uint64
+	FProgress     puzzle.IProgressF
+	FWorkSizeBits uint64
 }
 
 type SSettings sSettings
 type sSettings struct {
 	FWorkSizeBits uint64
 	FNetworkKey   string
+	FPuzzleType   puzzle.IPuzzleType
+	FPuzzleMemory uint64
 }
 
 func NewConstructSettings(pSett *SConstructSettings) IConstructSettings {
 	return (&sConstructSettings{
-		FSettings: pSett.FSettings,
-		FParallel: pSett.FParallel,
+		FSettings:     pSett.FSettings,
+		FParallel:     pSett.FParallel,
+		FProgress:     pSett.FProgress,
+		FWorkSizeBits: pSett.FWorkSizeBits,
 	}).mustNotNull()
 }
 
@@ -28,6 +40,8 @@ func NewSettings(pSett *SSettings) ISettings {
 	return (&sSettings{
 		FWorkSizeBits: pSett.FWorkSizeBits,
 		FNetworkKey:   pSett.FNetworkKey,
+		FPuzzleType:   pSett.FPuzzleType,
+		FPuzzleMemory: pSett.FPuzzleMemory,
 	}).mustNotNull()
 }
 
@@ -35,6 +49,11 @@ func (p *sConstructSettings) mustNotNull() IConstructSettings {
 	if p.FSettings == nil {
 		panic(`p.FSettings == nil`)
 	}
+	// p.FProgress can be = nil (progress of proof is not reported)
+	// p.FWorkSizeBits can be = 0 (work of settings)
+	if p.FWorkSizeBits != 0 && p.FWorkSizeBits < p.FSettings.GetWorkSizeBits() {
+		panic(`p.FWorkSizeBits != 0 && p.FWorkSizeBits < p.FSettings.GetWorkSizeBits()`)
+	}
 	return p
 }
 
@@ -46,10 +65,29 @@ func (p *sConstructSettings) GetParallel() uint64 {
 	return p.FParallel
 }
 
+func (p *sConstructSettings) GetProgress() puzzle.IProgressF {
+	return p.FProgress
+}
+
+// Sender can do more work than the minimum of the network.
+// The minimum (work of settings) is returned by default.
+func (p *sConstructSettings) GetWorkSizeBits() uint64 {
+	return max(p.FWorkSizeBits, p.FSettings.GetWorkSizeBits())
+}
+
 func (p *sSettings) mustNotNull() ISettings {
+	// p.FPuzzleType can be = 0 (SHA-384 proof of work)
+	if p.FPuzzleType > puzzle.CPuzzleTypeArgon2 {
+		panic(`p.FPuzzleType > puzzle.CPuzzleTypeArgon2`)
+	}
+	// p.FPuzzleMemory can be = 0 (puzzle.CArgon2DefaultMemory)
+	if p.FPuzzleMemory != 0 && (p.FPuzzleMemory < puzzle.CArgon2MinMemory || p.FPuzzleMemory > math.MaxUint32) {
+		panic(`p.FPuzzleMemory != 0 && (p.FPuzzleMemory < puzzle.CArgon2MinMemory || p.FPuzzleMemory > math.MaxUint32)`)
+	}
 	return p
 }
 
+// Minimum work of the messages accepted by the network.
 func (p *sSettings) GetWorkSizeBits() uint64 {
 	return p.FWorkSizeBits
 }
@@ -57,3 +95,23 @@ func (p *sSettings) GetWorkSizeBits() uint64 {
 func (p *sSettings) GetNetworkKey() string {
 	return p.FNetworkKey
 }
+
+// All participants of the network must use the same type of puzzle.
+// Loading of each message verifies the puzzle after the auth hash. For the
+// argon2 puzzle it is one memory-hard hash (GetPuzzleMemory, ~46MiB and ~50ms
+// by default), so the count of verifications must be limited by the reader
+// (see FVerifyLimit of the network conn).
+func (p *sSettings) GetPuzzleType() puzzle.IPuzzleType {
+	return p.FPuzzleType
+}
+
+// Memory (KiB) of the memory-hard puzzle. It is = 0 for the SHA-384 proof of work.
+func (p *sSettings) GetPuzzleMemory() uint64 {
+	if p.FPuzzleType != puzzle.CPuzzleTypeArgon2 {
+		return 0
+	}
+	if p.FPuzzleMemory == 0 {
+		return puzzle.CArgon2DefaultMemory
+	}
+	return p.FPuzzleMemory
+}
@@ -1,6 +1,7 @@
 package layer1
 
 import (
+	"github.com/number571/go-peer/pkg/crypto/puzzle"
 	"github.com/number571/go-peer/pkg/payload"
 	"github.com/number571/go-peer/pkg/types"
 )
@@ -10,6 +11,8 @@ type IMessage interface {
 
 	GetHash() []byte
 	GetProof() uint64
+	GetWorkSizeBits() uint64
+	ToPlainBytes() []byte
 
 	// payload = head(32bit) || body(Nbit)
 	GetPayload() payload.IPayload32
@@ -18,9 +21,13 @@ type IMessage interface {
 type IConstructSettings interface {
 	GetSettings() ISettings
 	GetParallel() uint64
+	GetProgress() puzzle.IProgressF
+	GetWorkSizeBits() uint64
 }
 
 type ISettings interface {
 	GetWorkSizeBits() uint64
 	GetNetworkKey() string
+	GetPuzzleType() puzzle.IPuzzleType
+	GetPuzzleMemory() uint64
 }
@@ -0,0 +1,265 @@
+package addrbook
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/number571/go-peer/pkg/encoding"
+	"github.com/number571/go-peer/pkg/storage/database"
+)
+
+const (
+	cMaxAddressSize = 255
+)
+
+var (
+	gAddrBookKey = []byte("__pkg/network/addrbook__")
+)
+
+var (
+	_ IAddrBook = &sAddrBook{}
+	_ IAddrInfo = &sAddrInfo{}
+)
+
+type sAddrBook struct {
+	fMutex     sync.RWMutex
+	fSettings  ISettings
+	fKVDB      database.IKVDatabase
+	fAddresses map[string]*sAddrInfo
+}
+
+type sAddrInfo struct {
+	FAddress  string    `json:"address"`
+	FAddedAt  time.Time `json:"added_at"`
+	FLastSeen time.Time `json:"last_seen"`
+	FFailures uint64    `json:"failures"`
+}
+
+// Creates the address book and loads the saved addresses from the database.
+func NewAddrBook(pSett ISettings, pKVDB database.IKVDatabase) (IAddrBook, error) {
+	addrBook := &sAddrBook{
+		fSettings:  pSett,
+		fKVDB:      pKVDB,
+		fAddresses: make(map[string]*sAddrInfo, pSett.GetCapacity()),
+	}
+
+	data, err := pKVDB.Get(gAddrBookKey)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return addrBook, nil
+		}
+		return nil, errors.Join(ErrLoadAddrBook, err)
+	}
+
+	var addresses []*sAddrInfo
+	if err := encoding.DeserializeJSON(data, &addresses); err != nil {
+		return nil, errors.Join(ErrDecodeAddrBook, err)
+	}
+
+	for _, info := range addresses {
+		if uint64(len(addrBook.fAddresses)) >= pSett.GetCapacity() {
+			break
+		}
+		addrBook.fAddresses[info.FAddress] = info
+	}
+
+	return addrBook, nil
+}
+
+func (p *sAddrBook) GetSettings() ISettings {
+	return p.fSettings
+}
+
+// Returns the addresses ordered from the most reliable to the least reliable.
+func (p *sAddrBook) GetAddresses() []IAddrInfo {
+	p.fMutex.RLock()
+	defer p.fMutex.RUnlock()
+
+	infos := p.sortedAddresses()
+	result := make([]IAddrInfo, 0, len(infos))
+	for _, info := range infos {
+		infoCopy := *info
+		result = append(result, &infoCopy)
+	}
+	return result
+}
+
+func (p *sAddrBook) GetAddress(pAddress string) (IAddrInfo, bool) {
+	p.fMutex.RLock()
+	defer p.fMutex.RUnlock()
+
+	info, ok := p.fAddresses[pAddress]
+	if !ok {
+		return nil, false
+	}
+
+	infoCopy := *info
+	return &infoCopy, true
+}
+
+// Adds the new address. Already existing address is not changed.
+func (p *sAddrBook) AddAddress(pAddress string) error {
+	return p.AddAddresses([]string{pAddress})
+}
+
+// Adds the addresses and saves the book once. Adding is stopped by the
+// first error, but the addresses added before the error are saved.
+func (p *sAddrBook) AddAddresses(pAddresses []string) error {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	var (
+		added  = 0
+		addErr error
+	)
+	for _, addr := range pAddresses {
+		if _, ok := p.fAddresses[addr]; ok {
+			continue
+		}
+		if _, err := p.newAddress(addr); err != nil {
+			addErr = err
+			break
+		}
+		added++
+	}
+
+	if added != 0 {
+		if err := p.save(); err != nil {
+			return err
+		}
+	}
+	return addErr
+}
+
+func (p *sAddrBook) DelAddress(pAddress string) error {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	if _, ok := p.fAddresses[pAddress]; !ok {
+		return ErrAddressIsNotExist
+	}
+
+	delete(p.fAddresses, pAddress)
+	return p.save()
+}
+
+// Updates the time of last seen and resets the failures.
+// The address is added if it is not exist.
+func (p *sAddrBook) SetSuccess(pAddress string) error {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	info, ok := p.fAddresses[pAddress]
+	if !ok {
+		var err error
+		info, err = p.newAddress(pAddress)
+		if err != nil {
+			return err
+		}
+	}
+
+	info.FLastSeen = time.Now()
+	info.FFailures = 0
+
+	return p.save()
+}
+
+// Increments the failures of the address.
+// The address is deleted when the failures reach the limit.
+func (p *sAddrBook) SetFailure(pAddress string) error {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	info, ok := p.fAddresses[pAddress]
+	if !ok {
+		return ErrAddressIsNotExist
+	}
+
+	info.FFailures++
+	if info.FFailures >= p.fSettings.GetMaxFailures() {
+		delete(p.fAddresses, pAddress)
+	}
+
+	return p.save()
+}
+
+func (p *sAddrBook) newAddress(pAddress string) (*sAddrInfo, error) {
+	if pAddress == "" || len(pAddress) > cMaxAddressSize {
+		return nil, ErrInvalidAddress
+	}
+
+	if uint64(len(p.fAddresses)) >= p.fSettings.GetCapacity() {
+		if !p.evictAddress() {
+			return nil, ErrAddrBookIsFull
+		}
+	}
+
+	info := &sAddrInfo{
+		FAddress: pAddress,
+		FAddedAt: time.Now(),
+	}
+
+	p.fAddresses[pAddress] = info
+	return info, nil
+}
+
+// Deletes the least reliable address if it has failures or was never seen.
+func (p *sAddrBook) evictAddress() bool {
+	infos := p.sortedAddresses()
+	if len(infos) == 0 {
+		return false
+	}
+
+	worst := infos[len(infos)-1]
+	if worst.FFailures == 0 && !worst.FLastSeen.IsZero() {
+		return false
+	}
+
+	delete(p.fAddresses, worst.FAddress)
+	return true
+}
+
+// Ordered by failures (asc) and then by last seen time (desc).
+func (p *sAddrBook) sortedAddresses() []*sAddrInfo {
+	infos := make([]*sAddrInfo, 0, len(p.fAddresses))
+	for _, info := range p.fAddresses {
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].FFailures != infos[j].FFailures {
+			return infos[i].FFailures < infos[j].FFailures
+		}
+		if !infos[i].FLastSeen.Equal(infos[j].FLastSeen) {
+			return infos[i].FLastSeen.After(infos[j].FLastSeen)
+		}
+		return infos[i].FAddress < infos[j].FAddress
+	})
+
+	return infos
+}
+
+func (p *sAddrBook) save() error {
+	if err := p.fKVDB.Set(gAddrBookKey, encoding.SerializeJSON(p.sortedAddresses())); err != nil {
+		return errors.Join(ErrSaveAddrBook, err)
+	}
+	return nil
+}
+
+func (p *sAddrInfo) GetAddress() string {
+	return p.FAddress
+}
+
+func (p *sAddrInfo) GetAddedAt() time.Time {
+	return p.FAddedAt
+}
+
+func (p *sAddrInfo) GetLastSeen() time.Time {
+	return p.FLastSeen
+}
+
+func (p *sAddrInfo) GetFailures() uint64 {
+	return p.FFailures
+}
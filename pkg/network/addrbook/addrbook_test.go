@@ -0,0 +1,211 @@
+package addrbook
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/number571/go-peer/pkg/storage/database"
+)
+
+const (
+	tcPathDBTemplate = "database_test_%d.db"
+)
+
+func TestError(t *testing.T) {
+	t.Parallel()
+
+	str := "value"
+	err := &SAddrBookError{str}
+	if err.Error() != errPrefix+str {
+		t.Error("incorrect err.Error()")
+		return
+	}
+}
+
+func TestSettings(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 2; i++ {
+		testSettings(t, i)
+	}
+}
+
+func testSettings(t *testing.T, n int) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("nothing panics")
+			return
+		}
+	}()
+	switch n {
+	case 0:
+		_ = NewSettings(&SSettings{
+			FMaxFailures: 3,
+		})
+	case 1:
+		_ = NewSettings(&SSettings{
+			FCapacity: 3,
+		})
+	}
+}
+
+func TestAddrBook(t *testing.T) {
+	t.Parallel()
+
+	dbPath := fmt.Sprintf(tcPathDBTemplate, 1)
+	defer os.RemoveAll(dbPath)
+
+	kvDB, err := database.NewKVDatabase(dbPath)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer kvDB.Close()
+
+	sett := NewSettings(&SSettings{FCapacity: 2, FMaxFailures: 2})
+	addrBook, err := NewAddrBook(sett, kvDB)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if addrBook.GetSettings().GetCapacity() != 2 {
+		t.Error("got invalid settings")
+		return
+	}
+
+	if err := addrBook.AddAddress(""); !errors.Is(err, ErrInvalidAddress) {
+		t.Error("success add empty address")
+		return
+	}
+
+	if err := addrBook.AddAddress("addr1"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := addrBook.AddAddress("addr1"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := addrBook.SetSuccess("addr2"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// addr1 was never seen, so it is evicted by the new address
+	if err := addrBook.AddAddress("addr3"); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := addrBook.GetAddress("addr1"); ok {
+		t.Error("address was not evicted")
+		return
+	}
+
+	if err := addrBook.SetSuccess("addr3"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := addrBook.AddAddress("addr4"); !errors.Is(err, ErrAddrBookIsFull) {
+		t.Error("success add address into full book")
+		return
+	}
+
+	if err := addrBook.SetFailure("addr3"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	addresses := addrBook.GetAddresses()
+	if len(addresses) != 2 || addresses[0].GetAddress() != "addr2" {
+		t.Error("got invalid order of addresses")
+		return
+	}
+	if addresses[1].GetFailures() != 1 || addresses[1].GetAddedAt().IsZero() || addresses[1].GetLastSeen().IsZero() {
+		t.Error("got invalid address info")
+		return
+	}
+
+	// reload from database
+	addrBook2, err := NewAddrBook(sett, kvDB)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if info, ok := addrBook2.GetAddress("addr3"); !ok || info.GetFailures() != 1 {
+		t.Error("address book is not persisted")
+		return
+	}
+
+	// max failures = 2
+	if err := addrBook2.SetFailure("addr3"); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := addrBook2.GetAddress("addr3"); ok {
+		t.Error("address was not deleted by failures")
+		return
+	}
+
+	if err := addrBook2.SetFailure("addr3"); !errors.Is(err, ErrAddressIsNotExist) {
+		t.Error("success set failure to not exist address")
+		return
+	}
+	if err := addrBook2.DelAddress("addr2"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := addrBook2.DelAddress("addr2"); !errors.Is(err, ErrAddressIsNotExist) {
+		t.Error("success delete not exist address")
+		return
+	}
+
+	// addresses before the invalid address are added
+	if err := addrBook2.AddAddresses([]string{"addr5", "", "addr6"}); !errors.Is(err, ErrInvalidAddress) {
+		t.Error("success add invalid addresses")
+		return
+	}
+	addrBook3, err := NewAddrBook(sett, kvDB)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	addresses = addrBook3.GetAddresses()
+	if len(addresses) != 1 || addresses[0].GetAddress() != "addr5" {
+		t.Error("added addresses are not persisted")
+		return
+	}
+}
+
+func TestInvalidAddrBook(t *testing.T) {
+	t.Parallel()
+
+	dbPath := fmt.Sprintf(tcPathDBTemplate, 2)
+	defer os.RemoveAll(dbPath)
+
+	kvDB, err := database.NewKVDatabase(dbPath)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer kvDB.Close()
+
+	if err := kvDB.Set(gAddrBookKey, []byte("invalid")); err != nil {
+		t.Error(err)
+		return
+	}
+
+	sett := NewSettings(&SSettings{FCapacity: 2, FMaxFailures: 2})
+	if _, err := NewAddrBook(sett, kvDB); err == nil {
+		t.Error("success load invalid address book")
+		return
+	}
+
+	kvDB.Close()
+	if _, err := NewAddrBook(sett, kvDB); err == nil {
+		t.Error("success load address book from closed database")
+		return
+	}
+}
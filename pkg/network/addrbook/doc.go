@@ -0,0 +1,5 @@
+// Package addrbook allows you to store the known addresses of the network nodes.
+//
+// Each address has the time of last successful connection and the count of failures.
+// The book is persisted into the key-value database, so it survives restarts.
+package addrbook
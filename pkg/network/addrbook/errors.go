@@ -0,0 +1,22 @@
+package addrbook
+
+const (
+	errPrefix = "pkg/network/addrbook = "
+)
+
+type SAddrBookError struct {
+	str string
+}
+
+func (err *SAddrBookError) Error() string {
+	return errPrefix + err.str
+}
+
+var (
+	ErrLoadAddrBook      = &SAddrBookError{"load address book"}
+	ErrSaveAddrBook      = &SAddrBookError{"save address book"}
+	ErrDecodeAddrBook    = &SAddrBookError{"decode address book"}
+	ErrAddressIsNotExist = &SAddrBookError{"address is not exist"}
+	ErrInvalidAddress    = &SAddrBookError{"invalid address"}
+	ErrAddrBookIsFull    = &SAddrBookError{"address book is full"}
+)
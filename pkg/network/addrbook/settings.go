@@ -0,0 +1,36 @@
+package addrbook
+
+var (
+	_ ISettings = &sSettings{}
+)
+
+type SSettings sSettings
+type sSettings struct {
+	FCapacity    uint64
+	FMaxFailures uint64
+}
+
+func NewSettings(pSett *SSettings) ISettings {
+	return (&sSettings{
+		FCapacity:    pSett.FCapacity,
+		FMaxFailures: pSett.FMaxFailures,
+	}).mustNotNull()
+}
+
+func (p *sSettings) mustNotNull() ISettings {
+	if p.FCapacity == 0 {
+		panic(`p.FCapacity == 0`)
+	}
+	if p.FMaxFailures == 0 {
+		panic(`p.FMaxFailures == 0`)
+	}
+	return p
+}
+
+func (p *sSettings) GetCapacity() uint64 {
+	return p.FCapacity
+}
+
+func (p *sSettings) GetMaxFailures() uint64 {
+	return p.FMaxFailures
+}
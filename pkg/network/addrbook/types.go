@@ -0,0 +1,31 @@
+package addrbook
+
+import (
+	"time"
+)
+
+type IAddrBook interface {
+	GetSettings() ISettings
+
+	GetAddresses() []IAddrInfo
+	GetAddress(string) (IAddrInfo, bool)
+
+	AddAddress(string) error
+	AddAddresses([]string) error
+	DelAddress(string) error
+
+	SetSuccess(string) error
+	SetFailure(string) error
+}
+
+type IAddrInfo interface {
+	GetAddress() string
+	GetAddedAt() time.Time
+	GetLastSeen() time.Time
+	GetFailures() uint64
+}
+
+type ISettings interface {
+	GetCapacity() uint64
+	GetMaxFailures() uint64
+}
@@ -0,0 +1,173 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/number571/go-peer/pkg/message/layer1"
+	"github.com/number571/go-peer/pkg/network/conn"
+)
+
+const (
+	cPenaltyInvalidSize       = 10
+	cPenaltyInvalidProof      = 10
+	cPenaltyInvalidAuth       = 10
+	cPenaltyInvalidHandshake  = 10
+	cPenaltyInvalidNetworkKey = 10
+	cPenaltyUntrustedPeer     = 5
+	cPenaltyUnknownRoute      = 5
+)
+
+type sScore struct {
+	fPenalty uint64
+	fUpdated time.Time
+}
+
+// Returns the hosts with the time of ban expiration.
+func (p *sNode) GetBanList() map[string]time.Time {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	now := time.Now()
+	mapping := make(map[string]time.Time, len(p.fBans))
+	for host, until := range p.fBans {
+		if !now.Before(until) {
+			delete(p.fBans, host)
+			continue
+		}
+		mapping[host] = until
+	}
+
+	return mapping
+}
+
+// Deletes the host (or address) from the ban list and resets its score.
+func (p *sNode) DelBan(pHost string) error {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	host := getHost(pHost)
+	if _, ok := p.fBans[host]; !ok {
+		return ErrBanIsNotExist
+	}
+
+	delete(p.fBans, host)
+	delete(p.fScores, host)
+	return nil
+}
+
+// Checks the host of address in the ban list. Expired ban is deleted.
+func (p *sNode) isBanned(pAddress string) bool {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	host := getHost(pAddress)
+	until, ok := p.fBans[host]
+	if !ok {
+		return false
+	}
+	if !time.Now().Before(until) {
+		delete(p.fBans, host)
+		return false
+	}
+	return true
+}
+
+// Lowers the score of the peer if the reason is the protocol violation.
+// The host is banned when the penalty reaches the threshold.
+func (p *sNode) penalizePeer(pAddress string, pReason error) {
+	threshold := p.fSettings.GetBanThreshold()
+	if threshold == 0 {
+		return // ban is disabled
+	}
+
+	penalty := getPenalty(pReason)
+	if penalty == 0 {
+		return
+	}
+
+	host := getHost(pAddress)
+	if !p.addPenalty(host, penalty, threshold) {
+		return
+	}
+
+	p.emitEvent(CEventPeerBanned, pAddress, pReason)
+}
+
+func (p *sNode) addPenalty(pHost string, pPenalty, pThreshold uint64) bool {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	now := time.Now()
+	banDuration := p.fSettings.GetBanDuration()
+
+	// the scores are forgotten after the ban duration without violations
+	for host, score := range p.fScores {
+		if now.Sub(score.fUpdated) >= banDuration {
+			delete(p.fScores, host)
+		}
+	}
+
+	score, ok := p.fScores[pHost]
+	if !ok {
+		score = &sScore{}
+		p.fScores[pHost] = score
+	}
+
+	score.fPenalty += pPenalty
+	score.fUpdated = now
+
+	if score.fPenalty < pThreshold {
+		return false
+	}
+
+	delete(p.fScores, pHost)
+	p.fBans[pHost] = now.Add(banDuration)
+	return true
+}
+
+func getPenalty(pReason error) uint64 {
+	switch {
+	case errors.Is(pReason, conn.ErrInvalidMsgSize), errors.Is(pReason, conn.ErrInvalidControlSize),
+		errors.Is(pReason, conn.ErrInvalidCompression):
+		return cPenaltyInvalidSize
+	case errors.Is(pReason, layer1.ErrInvalidProofOfWork):
+		return cPenaltyInvalidProof
+	case errors.Is(pReason, layer1.ErrInvalidAuthHash):
+		return cPenaltyInvalidAuth
+	case errors.Is(pReason, conn.ErrInvalidPreamble), errors.Is(pReason, conn.ErrInvalidPeerPubKey),
+		errors.Is(pReason, conn.ErrInvalidPeerSign), errors.Is(pReason, conn.ErrDecapsulateKey),
+		errors.Is(pReason, conn.ErrInvalidFrameSize):
+		return cPenaltyInvalidHandshake
+	case errors.Is(pReason, conn.ErrInvalidNetworkProof):
+		return cPenaltyInvalidNetworkKey
+	case errors.Is(pReason, conn.ErrUntrustedPeerPubKey):
+		return cPenaltyUntrustedPeer
+	case errors.Is(pReason, ErrUnknownRoute):
+		return cPenaltyUnknownRoute
+	default:
+		return 0
+	}
+}
+
+// Inbound addresses of the transports without hosts (pipe, unix) are unique
+// for each connection, so they are not the identity of peer and are not scored
+// (the ban of them never matches). Outbound addresses are dialed by the node.
+func isScoredAddress(pAddress string, pInbound bool) bool {
+	if !pInbound {
+		return true
+	}
+	_, _, err := net.SplitHostPort(pAddress)
+	return err == nil
+}
+
+// Address without the port is the host. Addresses which
+// are not in the host:port format are used fully (pipe, unix).
+func getHost(pAddress string) string {
+	host, _, err := net.SplitHostPort(pAddress)
+	if err != nil {
+		return pAddress
+	}
+	return host
+}
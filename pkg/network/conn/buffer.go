@@ -0,0 +1,96 @@
+package conn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/number571/go-peer/pkg/encoding"
+)
+
+const (
+	// Initial capacity of the frame buffers (head + small message).
+	cInitFrameSize = (4 << 10)
+)
+
+var (
+	// Buffers of the written frames are shared by all connections.
+	gFramePool = sync.Pool{
+		New: func() any {
+			buffer := make([]byte, 0, cInitFrameSize)
+			return &buffer
+		},
+	}
+)
+
+// Reads the socket with the context. The read deadline is
+// updated by the read timeout after each part of the bytes.
+type sSocketReader struct {
+	fConn *sConn
+	fCtx  context.Context
+	fErr  error // error of deadline is not lost by the io.ReadFull
+}
+
+func (p *sSocketReader) Read(pBuffer []byte) (int, error) {
+	select {
+	case <-p.fCtx.Done():
+		return 0, p.fCtx.Err()
+	default:
+	}
+
+	n, err := p.fConn.fSocket.Read(pBuffer)
+	p.fConn.fCounters.addBytesIn(n)
+	if err != nil {
+		return n, errors.Join(ErrReadFromSocket, err)
+	}
+
+	deadline := time.Now().Add(p.fConn.fSettings.GetReadTimeout())
+	if err := p.fConn.fSocket.SetReadDeadline(deadline); err != nil {
+		p.fErr = errors.Join(ErrSetReadDeadline, err)
+		return n, p.fErr
+	}
+
+	return n, nil
+}
+
+// Fills the buffer fully. The first part of the bytes is waited with the init timeout.
+func (p *sConn) readFull(pCtx context.Context, pBuffer []byte, pInitTimeout time.Duration) error {
+	if err := p.fSocket.SetReadDeadline(time.Now().Add(pInitTimeout)); err != nil {
+		return errors.Join(ErrSetReadDeadline, err)
+	}
+
+	reader := sSocketReader{fConn: p, fCtx: pCtx}
+	if _, err := io.ReadFull(&reader, pBuffer); err != nil {
+		return err
+	}
+
+	return reader.fErr
+}
+
+// Returns the read buffer of the connection with the length. The buffer
+// is reused by the next reads, so the bytes must be copied to be saved.
+func (p *sConn) getReadBuffer(pSize uint32) []byte {
+	if uint32(cap(p.fReadBuffer)) < pSize {
+		p.fReadBuffer = make([]byte, pSize)
+	}
+	return p.fReadBuffer[:pSize]
+}
+
+// Returns the buffer from the pool with the reserved place of the head.
+func getFrameBuffer() *[]byte {
+	buffer := gFramePool.Get().(*[]byte)
+	*buffer = (*buffer)[:encoding.CSizeUint32]
+	return buffer
+}
+
+func putFrameBuffer(pBuffer *[]byte) {
+	gFramePool.Put(pBuffer)
+}
+
+// Writes the length of the frame with the flags into the reserved place of the head.
+func setFrameHead(pFrame []byte, pFlags uint32) {
+	head := encoding.Uint32ToBytes(uint32(len(pFrame)-encoding.CSizeUint32) | pFlags)
+	copy(pFrame[:encoding.CSizeUint32], head[:])
+}
@@ -0,0 +1,86 @@
+package conn
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"sync"
+)
+
+/*
+	COMPRESSION (message frames)
+
+	L(M) || M
+	where
+		M = E(K, C(P)) if the second high bit of L is set
+		C - flate compression
+		P - bytes of message without the encryption by the network key
+
+	The compression is used only if both sides have the compression
+	feature (see preamble.go) and only if the compressed bytes are
+	smaller than the original bytes. The link handshake is required,
+	because P is not encrypted. The size of the decompressed bytes
+	is limited by the FLimitMessageSizeBytes (decompression bomb).
+*/
+
+const (
+	// Second high bit of the length = compressed message frame.
+	cCompressFlag = uint32(1 << 30)
+)
+
+var (
+	// States of flate are large, so they are reused.
+	gWriterPool = sync.Pool{
+		New: func() any {
+			writer, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+			return writer
+		},
+	}
+	gReaderPool = sync.Pool{
+		New: func() any {
+			return flate.NewReader(bytes.NewReader(nil))
+		},
+	}
+)
+
+// Returns the compressed bytes and true if the compression reduces the size.
+func compressBytes(pBytes []byte) ([]byte, bool) {
+	buffer := bytes.NewBuffer(make([]byte, 0, len(pBytes)))
+
+	writer := gWriterPool.Get().(*flate.Writer)
+	defer gWriterPool.Put(writer)
+
+	writer.Reset(buffer)
+	if _, err := writer.Write(pBytes); err != nil {
+		return pBytes, false
+	}
+	if err := writer.Close(); err != nil {
+		return pBytes, false
+	}
+
+	if buffer.Len() >= len(pBytes) {
+		return pBytes, false
+	}
+	return buffer.Bytes(), true
+}
+
+// Decompresses the bytes, but no more than the limit of bytes.
+func decompressBytes(pBytes []byte, pLimit uint64) ([]byte, error) {
+	reader := gReaderPool.Get().(io.ReadCloser)
+	defer gReaderPool.Put(reader)
+
+	if err := reader.(flate.Resetter).Reset(bytes.NewReader(pBytes), nil); err != nil {
+		return nil, errors.Join(ErrInvalidCompression, err)
+	}
+
+	result, err := io.ReadAll(io.LimitReader(reader, int64(pLimit)+1))
+	if err != nil {
+		return nil, errors.Join(ErrInvalidCompression, err)
+	}
+	if uint64(len(result)) > pLimit {
+		return nil, ErrInvalidCompression
+	}
+
+	return result, nil
+}
@@ -8,9 +8,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
 	"github.com/number571/go-peer/pkg/encoding"
 	"github.com/number571/go-peer/pkg/message/layer1"
-	"github.com/number571/go-peer/pkg/payload/joiner"
+	"github.com/number571/go-peer/pkg/network/ratelimit"
+	"github.com/number571/go-peer/pkg/network/transport"
 )
 
 var (
@@ -18,25 +20,133 @@ var (
 )
 
 type sConn struct {
-	fMutex    sync.RWMutex
-	fSocket   net.Conn
-	fSettings ISettings
+	fMutex      sync.RWMutex
+	fReadMutex  sync.Mutex
+	fSocket     net.Conn
+	fSettings   ISettings
+	fPeerPubKey asymmetric.IPubKey
+	fSendCipher *sLinkCipher
+	fRecvCipher *sLinkCipher
+	fCounters   sCounters
+	fInbound    bool
+	fReadBuffer []byte
+	fVerifier   ratelimit.ILimiter
+
+	fFeatures     IFeatures
+	fPeerLimit    uint64
+	fPreambleHash []byte
+
+	fControlMutex  sync.Mutex
+	fControlHandle IControlF
+
+	fDone          chan struct{}
+	fCloseOnce     sync.Once
+	fKeepAliveOnce sync.Once
+	fPongCh        chan uint64
+	fPingMutex     sync.Mutex
+	fPinger        sPinger
+	fDead          int32 // atomic variable
 }
 
+// Connects to the address over TCP transport.
+// Use ConnectWith to choose another transport.
 func Connect(pCtx context.Context, pSett ISettings, pAddr string) (IConn, error) {
-	dialer := &net.Dialer{Timeout: pSett.GetDialTimeout()}
-	conn, err := dialer.DialContext(pCtx, "tcp", pAddr)
+	return ConnectWith(pCtx, pSett, transport.NewTCPTransport(), pAddr)
+}
+
+func ConnectWith(pCtx context.Context, pSett ISettings, pDialer transport.IDialer, pAddr string) (IConn, error) {
+	dialCtx, cancel := context.WithTimeout(pCtx, pSett.GetDialTimeout())
+	defer cancel()
+
+	conn, err := pDialer.Dial(dialCtx, pAddr)
 	if err != nil {
 		return nil, errors.Join(ErrCreateConnection, err)
 	}
-	return LoadConn(pSett, conn), nil
+	return InitConn(pCtx, pSett, conn)
+}
+
+// Establishes the link on the side which has opened the socket.
+// The socket is closed if the link cannot be established.
+func InitConn(pCtx context.Context, pSett ISettings, pSocket net.Conn) (IConn, error) {
+	return establishConn(pCtx, pSett, pSocket, true)
+}
+
+// Establishes the link on the side which has accepted the socket.
+// The socket is closed if the link cannot be established.
+// Steps run before the peer proves anything (preamble, network key proof),
+// so the caller must limit the count of concurrent accepts and their deadline.
+func AcceptConn(pCtx context.Context, pSett ISettings, pSocket net.Conn) (IConn, error) {
+	return establishConn(pCtx, pSett, pSocket, false)
 }
 
+// Wraps the socket without any establishment of the link.
 func LoadConn(pSett ISettings, pConn net.Conn) IConn {
-	return &sConn{
+	conn := &sConn{
 		fSocket:   pConn,
 		fSettings: pSett,
+		fFeatures: cDefaultFeatures,
+		fDone:     make(chan struct{}),
+		fPongCh:   make(chan uint64, 1),
+	}
+	if sett := pSett.GetVerifyLimit(); sett != nil {
+		conn.fVerifier = ratelimit.NewLimiter(sett)
+	}
+	return conn
+}
+
+func establishConn(pCtx context.Context, pSett ISettings, pSocket net.Conn, pIsInitiator bool) (IConn, error) {
+	conn := LoadConn(pSett, pSocket).(*sConn)
+	conn.fInbound = !pIsInitiator
+
+	withPreamble := pSett.GetProtocolPreamble()
+	withProof := pSett.GetNetworkKeyProof()
+	withHandshake := pSett.GetLinkPrivKey() != nil
+	if !withPreamble && !withProof && !withHandshake {
+		return conn.withKeepAlive(), nil
+	}
+
+	err := withSocketContext(pCtx, conn, func() error {
+		// incompatible peers are rejected before any other checks
+		if withPreamble {
+			if err := conn.exchangePreamble(pCtx, pIsInitiator); err != nil {
+				return err
+			}
+		}
+		// cheap check of the network is done first
+		if withProof {
+			if err := conn.proveNetworkKey(pCtx, pIsInitiator); err != nil {
+				return err
+			}
+		}
+		if !withHandshake {
+			return nil
+		}
+		if pIsInitiator {
+			return conn.handshakeInitiator(pCtx)
+		}
+		return conn.handshakeResponder(pCtx)
+	})
+	if err != nil {
+		_ = pSocket.Close()
+		return nil, err
+	}
+
+	// reset deadline after the context interruption
+	if err := pSocket.SetDeadline(time.Time{}); err != nil {
+		_ = pSocket.Close()
+		return nil, errors.Join(ErrSetReadDeadline, err)
 	}
+
+	return conn.withKeepAlive(), nil
+}
+
+// Starts the pings of the established connection if the interval
+// is set and the peer supports the pings.
+func (p *sConn) withKeepAlive() *sConn {
+	if p.isPinger() {
+		p.startKeepAlive()
+	}
+	return p
 }
 
 func (p *sConn) GetSettings() ISettings {
@@ -47,7 +157,18 @@ func (p *sConn) GetSocket() net.Conn {
 	return p.fSocket
 }
 
+// Returns nil if the link handshake is disabled.
+func (p *sConn) GetPeerPubKey() asymmetric.IPubKey {
+	return p.fPeerPubKey
+}
+
+// Returns true if the connection was accepted by the AcceptConn.
+func (p *sConn) IsInbound() bool {
+	return p.fInbound
+}
+
 func (p *sConn) Close() error {
+	p.fCloseOnce.Do(func() { close(p.fDone) })
 	return p.fSocket.Close()
 }
 
@@ -55,38 +176,135 @@ func (p *sConn) WriteMessage(pCtx context.Context, pMsg layer1.IMessage) error {
 	p.fMutex.Lock()
 	defer p.fMutex.Unlock()
 
-	bytesJoiner := joiner.NewBytesJoiner32([][]byte{pMsg.ToBytes()})
-	if err := p.sendBytes(pCtx, bytesJoiner); err != nil {
+	msgBytes := pMsg.ToBytes()
+	if p.fPeerLimit != 0 && uint64(len(msgBytes)) > p.fPeerLimit+layer1.CMessageHeadSize {
+		return ErrPeerLimitSize
+	}
+
+	frame := getFrameBuffer()
+	defer putFrameBuffer(frame)
+
+	headFlags := uint32(0)
+	if p.fFeatures.Has(CFeatureCompression) {
+		// bytes of message are encrypted by the network key => they are
+		// compressed without this encryption (link encryption is used)
+		compBytes, ok := compressBytes(pMsg.ToPlainBytes())
+		if ok && len(compBytes) < len(msgBytes) {
+			msgBytes = compBytes
+			headFlags = cCompressFlag
+		}
+	}
+
+	if p.fSendCipher != nil {
+		*frame = p.fSendCipher.appendEncrypted(*frame, msgBytes)
+	} else {
+		*frame = append(*frame, msgBytes...)
+	}
+
+	setFrameHead(*frame, headFlags)
+	if err := p.sendBytes(pCtx, *frame); err != nil {
 		return errors.Join(ErrSendPayloadBytes, err)
 	}
 
+	p.fCounters.incMessagesOut()
 	return nil
 }
 
+// Reads the frames until the message is received. Header of the message frame (or
+// the error of reading) is signaled to the channel. Control frames are passed to
+// the control handler without the signal.
 func (p *sConn) ReadMessage(pCtx context.Context, pChRead chan<- struct{}) (layer1.IMessage, error) {
-	// large wait read deadline => the connection has not sent anything yet
-	msgSize, err := p.recvHeadBytes(pCtx, pChRead, p.fSettings.GetWaitReadTimeout())
-	if err != nil {
-		return nil, errors.Join(ErrReadHeaderBytes, err)
+	// link cipher has state (counter), so the frames must be read sequentially
+	p.fReadMutex.Lock()
+	defer p.fReadMutex.Unlock()
+
+	for {
+		// large wait read deadline => the connection has not sent anything yet
+		frameSize, headFlags, err := p.recvHeadBytes(pCtx, pChRead, p.fSettings.GetWaitReadTimeout())
+		if err != nil {
+			return nil, p.withDeadError(errors.Join(ErrReadHeaderBytes, err))
+		}
+
+		dataBytes, err := p.recvDataBytes(pCtx, frameSize, p.fSettings.GetReadTimeout())
+		if err != nil {
+			return nil, p.withDeadError(errors.Join(ErrReadBodyBytes, err))
+		}
+
+		if p.fRecvCipher != nil {
+			dataBytes, err = p.fRecvCipher.decryptBytes(dataBytes)
+			if err != nil {
+				p.fCounters.incInvalid()
+				return nil, errors.Join(ErrDecryptFrame, err)
+			}
+		}
+
+		if headFlags&cControlFlag != 0 {
+			if !p.handleReserved(dataBytes) {
+				p.handleControl(bytes.Clone(dataBytes))
+			}
+			continue
+		}
+
+		// cheap check of the rate is done before the puzzle (can be memory-hard)
+		if err := p.waitVerify(pCtx, uint64(len(dataBytes))); err != nil {
+			return nil, err
+		}
+
+		// try unpack message from bytes
+		msg, err := p.loadMessage(dataBytes, headFlags&cCompressFlag != 0)
+		if err != nil {
+			p.fCounters.incInvalid()
+			return nil, errors.Join(ErrInvalidMessageBytes, err)
+		}
+
+		p.fCounters.incMessagesIn()
+		return msg, nil
+	}
+}
+
+// Loads the message from the read buffer. The message saves the copy of bytes.
+func (p *sConn) loadMessage(pBytes []byte, pIsCompressed bool) (layer1.IMessage, error) {
+	msgSett := p.fSettings.GetMessageSettings()
+	if !pIsCompressed {
+		return layer1.LoadMessage(msgSett, bytes.Clone(pBytes))
 	}
 
-	dataBytes, err := p.recvDataBytes(pCtx, msgSize, p.fSettings.GetReadTimeout())
+	msgLimit := p.fSettings.GetLimitMessageSizeBytes() + layer1.CMessageHeadSize
+	plainBytes, err := decompressBytes(pBytes, msgLimit)
 	if err != nil {
-		return nil, errors.Join(ErrReadBodyBytes, err)
+		return nil, err
 	}
 
-	// try unpack message from bytes
-	msg, err := layer1.LoadMessage(p.fSettings.GetMessageSettings(), dataBytes)
-	if err != nil {
-		return nil, errors.Join(ErrInvalidMessageBytes, err)
+	return layer1.LoadPlainMessage(msgSett, plainBytes)
+}
+
+// Message over the limit of verification is delayed or the reading is stopped.
+func (p *sConn) waitVerify(pCtx context.Context, pSize uint64) error {
+	if p.fVerifier == nil {
+		return nil
+	}
+	if p.fVerifier.GetSettings().GetDelayOnLimit() {
+		if err := p.fVerifier.Wait(pCtx, pSize); err != nil {
+			return errors.Join(ErrVerifyLimit, err)
+		}
+		return nil
+	}
+	if !p.fVerifier.Allow(pSize) {
+		return ErrVerifyLimit
 	}
+	return nil
+}
 
-	return msg, nil
+// The connection closed by the keep-alive has the reason of closing.
+func (p *sConn) withDeadError(pErr error) error {
+	if p.isDead() {
+		return errors.Join(ErrPeerIsDead, pErr)
+	}
+	return pErr
 }
 
 func (p *sConn) sendBytes(pCtx context.Context, pBytes []byte) error {
-	bytesPtr := uint64(len(pBytes))
-	for bytesPtr != 0 {
+	for len(pBytes) != 0 {
 		select {
 		case <-pCtx.Done():
 			return pCtx.Err()
@@ -95,13 +313,14 @@ func (p *sConn) sendBytes(pCtx context.Context, pBytes []byte) error {
 				return errors.Join(ErrSetWriteDeadline, err)
 			}
 
-			n, err := p.fSocket.Write(pBytes[:bytesPtr])
+			n, err := p.fSocket.Write(pBytes)
+			p.fCounters.addBytesOut(n)
 			if err != nil {
 				return errors.Join(ErrWriteToSocket, err)
 			}
 
-			bytesPtr -= uint64(n)
-			pBytes = pBytes[:bytesPtr]
+			// the rest of bytes is written by the next iteration
+			pBytes = pBytes[n:]
 		}
 	}
 	return nil
@@ -111,18 +330,22 @@ func (p *sConn) recvHeadBytes(
 	pCtx context.Context,
 	pChRead chan<- struct{},
 	pInitTimeout time.Duration,
-) (uint32, error) {
-	defer func() { pChRead <- struct{}{} }()
+) (rSize uint32, rFlags uint32, rErr error) {
+	// control frames do not start the reading of message,
+	// so the idle connection is not closed by the read timeout
+	defer func() {
+		if rErr != nil || rFlags&cControlFlag == 0 {
+			pChRead <- struct{}{}
+		}
+	}()
 
-	var (
-		headBytes []byte
-		err       error
-	)
+	// the head is not read into the read buffer, because
+	// the reading can continue after the context is done
+	headBytes := [encoding.CSizeUint32]byte{}
 
-	chErr := make(chan error)
+	chErr := make(chan error, 1)
 	go func() {
-		headBytes, err = p.recvDataBytes(pCtx, encoding.CSizeUint32, pInitTimeout)
-		if err != nil {
+		if err := p.readFull(pCtx, headBytes[:], pInitTimeout); err != nil {
 			chErr <- errors.Join(ErrReadHeaderBlock, err)
 			return
 		}
@@ -131,63 +354,65 @@ func (p *sConn) recvHeadBytes(
 
 	select {
 	case <-pCtx.Done():
-		return 0, pCtx.Err()
+		return 0, 0, pCtx.Err()
 	case err := <-chErr:
 		if err != nil {
-			return 0, err
+			return 0, 0, err
 		}
 	}
 
-	msgSizeBytes := [encoding.CSizeUint32]byte{}
-	copy(msgSizeBytes[:], headBytes)
+	frameOverhead := uint32(0)
+	if p.fRecvCipher != nil {
+		frameOverhead = cLinkTagSize
+	}
+
+	gotHead := encoding.BytesToUint32(headBytes)
+	gotMsgSize := gotHead & cSizeMask
+	gotFlags := gotHead &^ cSizeMask
+
+	if gotFlags&cControlFlag != 0 {
+		switch {
+		case gotFlags&cCompressFlag != 0:
+			fallthrough
+		case gotMsgSize <= frameOverhead:
+			fallthrough
+		case gotMsgSize > CMaxControlSize+frameOverhead:
+			return 0, 0, ErrInvalidControlSize
+		}
+		return gotMsgSize, gotFlags, nil
+	}
 
-	gotMsgSize := encoding.BytesToUint32(msgSizeBytes)
-	fullMsgSize := p.fSettings.GetLimitMessageSizeBytes() + layer1.CMessageHeadSize
+	fullMsgSize := p.fSettings.GetLimitMessageSizeBytes() + layer1.CMessageHeadSize + uint64(frameOverhead)
+
+	// compressed bytes can be smaller than the head of message
+	if gotFlags&cCompressFlag != 0 {
+		switch {
+		case !p.fFeatures.Has(CFeatureCompression):
+			return 0, 0, ErrInvalidCompression
+		case gotMsgSize <= frameOverhead:
+			fallthrough
+		case uint64(gotMsgSize) > fullMsgSize:
+			return 0, 0, ErrInvalidMsgSize
+		}
+		return gotMsgSize, gotFlags, nil
+	}
 
 	switch {
-	case gotMsgSize < layer1.CMessageHeadSize:
+	case gotMsgSize < layer1.CMessageHeadSize+frameOverhead:
 		fallthrough
 	case uint64(gotMsgSize) > fullMsgSize:
-		return 0, ErrInvalidMsgSize
+		return 0, 0, ErrInvalidMsgSize
 	}
 
-	return gotMsgSize, nil
+	return gotMsgSize, gotFlags, nil
 }
 
+// Reads the bytes into the read buffer of connection.
+// The result is valid only until the next read.
 func (p *sConn) recvDataBytes(pCtx context.Context, pMustLen uint32, pInitTimeout time.Duration) ([]byte, error) {
-	dataRaw := make([]byte, 0, pMustLen)
-
-	if err := p.fSocket.SetReadDeadline(time.Now().Add(pInitTimeout)); err != nil {
-		return nil, errors.Join(ErrSetReadDeadline, err)
+	buffer := p.getReadBuffer(pMustLen)
+	if err := p.readFull(pCtx, buffer, pInitTimeout); err != nil {
+		return nil, err
 	}
-
-	mustLen := pMustLen
-	for mustLen != 0 {
-		select {
-		case <-pCtx.Done():
-			return nil, pCtx.Err()
-		default:
-			buffer := make([]byte, mustLen)
-			n, err := p.fSocket.Read(buffer)
-			if err != nil {
-				return nil, errors.Join(ErrReadFromSocket, err)
-			}
-
-			dataRaw = bytes.Join(
-				[][]byte{
-					dataRaw,
-					buffer[:n],
-				},
-				[]byte{},
-			)
-
-			mustLen -= uint32(n)
-
-			if err := p.fSocket.SetReadDeadline(time.Now().Add(p.fSettings.GetReadTimeout())); err != nil {
-				return nil, errors.Join(ErrSetReadDeadline, err)
-			}
-		}
-	}
-
-	return dataRaw, nil
+	return buffer, nil
 }
@@ -0,0 +1,115 @@
+package conn
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/number571/go-peer/pkg/encoding"
+	"github.com/number571/go-peer/pkg/message/layer1"
+	"github.com/number571/go-peer/pkg/payload"
+)
+
+/*
+goos: linux
+goarch: amd64
+pkg: github.com/number571/go-peer/pkg/network/conn
+cpu: Intel(R) Xeon(R) Processor
+BenchmarkConnWriteMessage 	  244731	      4176 ns/op	     244 B/op	       5 allocs/op
+BenchmarkConnReadMessage  	   18645	     59887 ns/op	   23160 B/op	      48 allocs/op
+PASS
+
+Size of message = FLimitMessageSizeBytes (8KiB). Most of the allocations
+of reading are done by the layer1.LoadMessage (network key, hash, proof).
+*/
+
+// go test -bench=BenchmarkConn -benchmem
+func BenchmarkConnWriteMessage(b *testing.B) {
+	sett := testNewBenchSettings()
+	msg := testNewBenchMessage(sett)
+
+	socket1, socket2 := net.Pipe()
+	defer socket1.Close()
+	defer socket2.Close()
+
+	go func() { _, _ = io.Copy(io.Discard, socket2) }()
+
+	conn := LoadConn(sett, socket1)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := conn.WriteMessage(ctx, msg); err != nil {
+			b.Error(err)
+			return
+		}
+	}
+}
+
+func BenchmarkConnReadMessage(b *testing.B) {
+	sett := testNewBenchSettings()
+	msg := testNewBenchMessage(sett)
+
+	socket1, socket2 := net.Pipe()
+	defer socket1.Close()
+	defer socket2.Close()
+
+	msgBytes := msg.ToBytes()
+	head := encoding.Uint32ToBytes(uint32(len(msgBytes)))
+	frame := bytes.Join([][]byte{head[:], msgBytes}, []byte{})
+
+	go func() {
+		for {
+			if _, err := socket1.Write(frame); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn := LoadConn(sett, socket2)
+	ctx := context.Background()
+
+	readCh := make(chan struct{}, 1)
+	go func() {
+		for range readCh {
+		}
+	}()
+	defer close(readCh)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := conn.ReadMessage(ctx, readCh); err != nil {
+			b.Error(err)
+			return
+		}
+	}
+}
+
+func testNewBenchSettings() ISettings {
+	return NewSettings(&SSettings{
+		FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+			FWorkSizeBits: tcWorkSize,
+		}),
+		FLimitMessageSizeBytes: tcMsgSize,
+		FWaitReadTimeout:       time.Hour,
+		FDialTimeout:           time.Minute,
+		FReadTimeout:           time.Minute,
+		FWriteTimeout:          time.Minute,
+	})
+}
+
+// Size of message = limit of the message size (maximum).
+func testNewBenchMessage(pSett ISettings) layer1.IMessage {
+	msgSett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: pSett.GetMessageSettings(),
+	})
+	body := make([]byte, pSett.GetLimitMessageSizeBytes())
+	return layer1.NewMessage(msgSett, payload.NewPayload32(tcHead, body))
+}
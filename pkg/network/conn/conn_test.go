@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"math"
 	"net"
 	"strings"
@@ -11,9 +13,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+	"github.com/number571/go-peer/pkg/crypto/puzzle"
 	"github.com/number571/go-peer/pkg/crypto/random"
 	"github.com/number571/go-peer/pkg/encoding"
 	"github.com/number571/go-peer/pkg/message/layer1"
+	"github.com/number571/go-peer/pkg/network/ratelimit"
+	"github.com/number571/go-peer/pkg/network/transport"
 	"github.com/number571/go-peer/pkg/payload"
 	testutils "github.com/number571/go-peer/test/utils"
 )
@@ -76,7 +82,7 @@ func TestError(t *testing.T) {
 func TestSettings(t *testing.T) {
 	t.Parallel()
 
-	for i := 0; i < 6; i++ {
+	for i := 0; i < 10; i++ {
 		testSettings(t, i)
 	}
 }
@@ -137,6 +143,48 @@ func testSettings(t *testing.T, n int) {
 			FReadTimeout:           time.Minute,
 			FWriteTimeout:          time.Minute,
 		})
+	case 6:
+		_ = NewSettings(&SSettings{
+			FMessageSettings:       layer1.NewSettings(&layer1.SSettings{}),
+			FLimitMessageSizeBytes: tcMsgSize,
+			FWaitReadTimeout:       time.Hour,
+			FDialTimeout:           time.Minute,
+			FReadTimeout:           time.Minute,
+			FWriteTimeout:          time.Minute,
+			FLinkPubKeys:           asymmetric.NewMapPubKeys(),
+		})
+	case 7:
+		_ = NewSettings(&SSettings{
+			FMessageSettings:       layer1.NewSettings(&layer1.SSettings{}),
+			FLimitMessageSizeBytes: tcMsgSize,
+			FWaitReadTimeout:       time.Hour,
+			FDialTimeout:           time.Minute,
+			FReadTimeout:           time.Minute,
+			FWriteTimeout:          time.Minute,
+			FPingInterval:          time.Second,
+		})
+	case 8:
+		_ = NewSettings(&SSettings{
+			FMessageSettings:       layer1.NewSettings(&layer1.SSettings{}),
+			FLimitMessageSizeBytes: tcMsgSize,
+			FWaitReadTimeout:       time.Hour,
+			FDialTimeout:           time.Minute,
+			FReadTimeout:           time.Minute,
+			FWriteTimeout:          time.Minute,
+			FLinkPrivKey:           asymmetric.NewPrivKey(),
+			FCompression:           true,
+		})
+	case 9:
+		_ = NewSettings(&SSettings{
+			FMessageSettings:       layer1.NewSettings(&layer1.SSettings{}),
+			FLimitMessageSizeBytes: tcMsgSize,
+			FWaitReadTimeout:       time.Hour,
+			FDialTimeout:           time.Minute,
+			FReadTimeout:           time.Minute,
+			FWriteTimeout:          time.Minute,
+			FProtocolPreamble:      true,
+			FCompression:           true,
+		})
 	}
 }
 
@@ -315,7 +363,7 @@ func TestRecvDataBytes(t *testing.T) {
 	rawConn.readDlError = true
 	go func() {
 		ctx := context.Background()
-		if _, err := conn.recvHeadBytes(ctx, ch, 5*time.Second); err == nil {
+		if _, _, err := conn.recvHeadBytes(ctx, ch, 5*time.Second); err == nil {
 			t.Error("success recv data bytes with invalid conn 2")
 			return
 		}
@@ -379,7 +427,7 @@ func TestRecvHeadBytes(t *testing.T) {
 	rawConn.headSize = 1
 	go func() {
 		ctx := context.Background()
-		if _, err := conn.recvHeadBytes(ctx, ch, 5*time.Second); err == nil {
+		if _, _, err := conn.recvHeadBytes(ctx, ch, 5*time.Second); err == nil {
 			t.Error("success recv head bytes with invalid conn 1")
 			return
 		}
@@ -390,7 +438,7 @@ func TestRecvHeadBytes(t *testing.T) {
 	rawConn.headSize = math.MaxUint32
 	go func() {
 		ctx := context.Background()
-		if _, err := conn.recvHeadBytes(ctx, ch, 5*time.Second); err == nil {
+		if _, _, err := conn.recvHeadBytes(ctx, ch, 5*time.Second); err == nil {
 			t.Error("success recv head bytes with invalid conn 2")
 			return
 		}
@@ -462,6 +510,731 @@ func testConn(t *testing.T, pAddr, pNetworkKey string) {
 	}
 }
 
+func TestLinkHandshake(t *testing.T) {
+	t.Parallel()
+
+	var (
+		privKey1 = asymmetric.NewPrivKey()
+		privKey2 = asymmetric.NewPrivKey()
+		privKey3 = asymmetric.NewPrivKey()
+	)
+
+	// any authenticated peer is accepted by the service
+	sett1 := testNewLinkSettings(privKey1, nil)
+	sett2 := testNewLinkSettings(privKey2, asymmetric.NewMapPubKeys(privKey1.GetPubKey()))
+	sett3 := testNewLinkSettings(privKey3, asymmetric.NewMapPubKeys(privKey2.GetPubKey()))
+
+	conn1, conn2, err := testPipeLink(sett1, sett2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn1.Close()
+	defer conn2.Close()
+
+	if !bytes.Equal(conn1.GetPeerPubKey().ToBytes(), privKey2.GetPubKey().ToBytes()) {
+		t.Error("got invalid peer public key (initiator side)")
+		return
+	}
+	if !bytes.Equal(conn2.GetPeerPubKey().ToBytes(), privKey1.GetPubKey().ToBytes()) {
+		t.Error("got invalid peer public key (responder side)")
+		return
+	}
+
+	if conn1.IsInbound() || !conn2.IsInbound() {
+		t.Error("got invalid direction of connections")
+		return
+	}
+
+	ctx := context.Background()
+	msgSett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: sett1.GetMessageSettings(),
+	})
+
+	for i := 0; i < 3; i++ {
+		msg := layer1.NewMessage(msgSett, payload.NewPayload32(tcHead, []byte(tcBody)))
+		go func() { _ = conn1.WriteMessage(ctx, msg) }()
+
+		readCh := make(chan struct{})
+		go func() { <-readCh }()
+
+		msgRecv, err := conn2.ReadMessage(ctx, readCh)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !bytes.Equal(msgRecv.GetPayload().GetBody(), []byte(tcBody)) {
+			t.Error("load payload not equal new payload")
+			return
+		}
+	}
+
+	// untrusted key of the responder
+	if _, _, err := testPipeLink(sett3, sett1); !errors.Is(err, ErrUntrustedPeerPubKey) {
+		t.Error("success handshake with untrusted responder")
+		return
+	}
+
+	// untrusted key of the initiator
+	if _, _, err := testPipeLink(sett1, sett3); !errors.Is(err, ErrUntrustedPeerPubKey) {
+		t.Error("success handshake with untrusted initiator")
+		return
+	}
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	socket1, socket2 := net.Pipe()
+	defer socket2.Close()
+	if _, err := InitConn(cancelCtx, sett1, socket1); err == nil {
+		t.Error("success handshake with canceled context")
+		return
+	}
+}
+
+func TestNetworkKeyProof(t *testing.T) {
+	t.Parallel()
+
+	sett1 := testNewProofSettings("network_key_1", tcWorkSize)
+	sett2 := testNewProofSettings("network_key_2", tcWorkSize)
+	sett3 := testNewProofSettings("network_key_1", tcWorkSize+1)
+
+	conn1, conn2, err := testPipeLink(sett1, sett1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conn1.Close()
+	conn2.Close()
+
+	if _, _, err := testPipeLink(sett1, sett2); !errors.Is(err, ErrInvalidNetworkProof) {
+		t.Error("success proof with different network keys")
+		return
+	}
+
+	if _, _, err := testPipeLink(sett3, sett1); !errors.Is(err, ErrInvalidNetworkProof) {
+		t.Error("success proof with different work size bits")
+		return
+	}
+
+	sett4 := testNewPuzzleProofSettings("network_key_1", tcWorkSize, (1 << 10))
+	sett5 := testNewPuzzleProofSettings("network_key_1", tcWorkSize, (2 << 10))
+
+	if _, _, err := testPipeLink(sett4, sett1); !errors.Is(err, ErrInvalidNetworkProof) {
+		t.Error("success proof with different types of puzzle")
+		return
+	}
+
+	if _, _, err := testPipeLink(sett4, sett5); !errors.Is(err, ErrInvalidNetworkProof) {
+		t.Error("success proof with different memory of puzzle")
+		return
+	}
+}
+
+func TestConnStats(t *testing.T) {
+	t.Parallel()
+
+	sett := testNewProofSettings("network_key", tcWorkSize)
+	conn1, conn2, err := testPipeLink(sett, sett)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn1.Close()
+	defer conn2.Close()
+
+	if conn1.GetStats().GetBytesOut() == 0 || conn2.GetStats().GetBytesIn() == 0 {
+		t.Error("bytes of proof are not counted")
+		return
+	}
+
+	ctx := context.Background()
+	msgSett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: sett.GetMessageSettings(),
+	})
+	msg := layer1.NewMessage(msgSett, payload.NewPayload32(tcHead, []byte(tcBody)))
+	invalidBytes := random.NewRandom().GetBytes(layer1.CMessageHeadSize + 16)
+
+	go func() {
+		_ = conn1.WriteMessage(ctx, msg)
+		_ = conn1.(*sConn).sendFrame(ctx, invalidBytes)
+	}()
+
+	for i := 0; i < 2; i++ {
+		readCh := make(chan struct{})
+		go func() { <-readCh }()
+		_, _ = conn2.ReadMessage(ctx, readCh)
+	}
+
+	// the writer updates the counters after the reader has got the bytes
+	err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		if conn1.GetStats().GetBytesOut() != conn2.GetStats().GetBytesIn() {
+			return errors.New("got different count of bytes")
+		}
+		return nil
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+
+	stats1 := conn1.GetStats()
+	stats2 := conn2.GetStats()
+
+	if stats1.GetMessagesOut() != 1 || stats2.GetMessagesIn() != 1 {
+		t.Error("got invalid count of messages")
+		return
+	}
+	if stats2.GetInvalidMessages() != 1 {
+		t.Error("got invalid count of invalid messages")
+		return
+	}
+	if stats2.GetLastActivity().IsZero() || time.Since(stats2.GetLastActivity()) > time.Minute {
+		t.Error("got invalid last activity")
+		return
+	}
+}
+
+func TestControlFrame(t *testing.T) {
+	t.Parallel()
+
+	privKey := asymmetric.NewPrivKey()
+	sett := testNewLinkSettings(privKey, nil)
+
+	conn1, conn2, err := testPipeLink(sett, sett)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn1.Close()
+	defer conn2.Close()
+
+	ctx := context.Background()
+	if err := conn1.WriteControl(ctx, nil); !errors.Is(err, ErrInvalidControlSize) {
+		t.Error("success write empty control frame")
+		return
+	}
+	if err := conn1.WriteControl(ctx, make([]byte, CMaxControlSize+1)); !errors.Is(err, ErrInvalidControlSize) {
+		t.Error("success write large control frame")
+		return
+	}
+
+	chControl := make(chan []byte, 2)
+	conn2.SetControlHandler(func(pData []byte) { chControl <- pData })
+
+	msgSett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: sett.GetMessageSettings(),
+	})
+	msg := layer1.NewMessage(msgSett, payload.NewPayload32(tcHead, []byte(tcBody)))
+
+	go func() {
+		_ = conn1.WriteControl(ctx, []byte("control-1"))
+		_ = conn1.WriteControl(ctx, []byte("control-2"))
+		_ = conn1.WriteMessage(ctx, msg)
+	}()
+
+	// each header of frame is signaled
+	readCh := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			<-readCh
+		}
+	}()
+
+	msgRecv, err := conn2.ReadMessage(ctx, readCh)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(msgRecv.GetPayload().GetBody(), []byte(tcBody)) {
+		t.Error("load payload not equal new payload")
+		return
+	}
+
+	for i := 1; i <= 2; i++ {
+		if got := <-chControl; string(got) != fmt.Sprintf("control-%d", i) {
+			t.Error("got invalid control frame")
+			return
+		}
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	t.Parallel()
+
+	sett := testNewPingSettings(20 * time.Millisecond)
+
+	conn1, conn2, err := testPipeLink(sett, sett)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn1.Close()
+	defer conn2.Close()
+
+	ctx := context.Background()
+	for _, c := range []IConn{conn1, conn2} {
+		go testReadLoop(ctx, c)
+	}
+
+	err1 := testutils.TryN(50, 20*time.Millisecond, func() error {
+		if conn1.GetStats().GetRTT() == 0 || conn2.GetStats().GetRTT() == 0 {
+			return errors.New("rtt is not measured")
+		}
+		return nil
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+
+	// peer does not read the pings = dead
+	conn3, conn4, err := testPipeLink(sett, testNewProofSettings("network_key", tcWorkSize))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn3.Close()
+	defer conn4.Close()
+
+	readCh := make(chan struct{}, 16)
+	if _, err := conn3.ReadMessage(ctx, readCh); !errors.Is(err, ErrPeerIsDead) {
+		t.Errorf("dead peer is not detected (%v)", err)
+		return
+	}
+}
+
+func TestPongCoalesced(t *testing.T) {
+	t.Parallel()
+
+	socket1, socket2 := net.Pipe()
+	defer socket2.Close()
+
+	conn := LoadConn(testNewProofSettings("network_key", tcWorkSize), socket1).(*sConn)
+	defer conn.Close()
+
+	// peer sends the pings without reading the pongs
+	for i := uint64(1); i <= 100; i++ {
+		if !conn.handleReserved(joinPing(cControlPing, i)) {
+			t.Error("ping is not reserved")
+			return
+		}
+	}
+
+	pongs := make([]uint64, 0, 2)
+	frame := make([]byte, encoding.CSizeUint32+cPingSize)
+	for {
+		_ = socket2.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		if _, err := io.ReadFull(socket2, frame); err != nil {
+			break
+		}
+		nonce := [encoding.CSizeUint64]byte{}
+		copy(nonce[:], frame[encoding.CSizeUint32+1:])
+		pongs = append(pongs, encoding.BytesToUint64(nonce))
+	}
+
+	// one pong is written, other pings are coalesced into the last one
+	if len(pongs) == 0 || len(pongs) > 2 {
+		t.Errorf("invalid count of pongs (%d)", len(pongs))
+		return
+	}
+	if pongs[len(pongs)-1] != 100 {
+		t.Error("last ping is not answered")
+		return
+	}
+}
+
+func TestVerifyLimit(t *testing.T) {
+	t.Parallel()
+
+	// verification of the memory-hard puzzle is limited by default
+	puzzleLimit := testNewPuzzleProofSettings("network_key", 1, (1 << 10)).GetVerifyLimit()
+	if puzzleLimit == nil || !puzzleLimit.GetDelayOnLimit() {
+		t.Error("verification of the argon2 puzzle is not limited")
+		return
+	}
+	if testNewProofSettings("network_key", tcWorkSize).GetVerifyLimit() != nil {
+		t.Error("verification of the proof of work is limited")
+		return
+	}
+
+	sett := NewSettings(&SSettings{
+		FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+			FWorkSizeBits: tcWorkSize,
+		}),
+		FLimitMessageSizeBytes: tcMsgSize,
+		FWaitReadTimeout:       time.Hour,
+		FDialTimeout:           time.Minute,
+		FReadTimeout:           time.Second,
+		FWriteTimeout:          time.Second,
+		FVerifyLimit: ratelimit.NewSettings(&ratelimit.SSettings{
+			FMessagesPerSec: 1,
+		}),
+	})
+
+	socket1, socket2 := net.Pipe()
+	conn1, conn2 := LoadConn(sett, socket1), LoadConn(sett, socket2)
+	defer conn1.Close()
+	defer conn2.Close()
+
+	ctx := context.Background()
+	msgSett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: sett.GetMessageSettings(),
+	})
+	go func() {
+		for i := 0; i < 2; i++ {
+			msg := layer1.NewMessage(msgSett, payload.NewPayload32(tcHead, []byte(tcBody)))
+			_ = conn1.WriteMessage(ctx, msg)
+		}
+	}()
+
+	readCh := make(chan struct{}, 2)
+	if _, err := conn2.ReadMessage(ctx, readCh); err != nil {
+		t.Error(err)
+		return
+	}
+	// message over the limit is not verified
+	if _, err := conn2.ReadMessage(ctx, readCh); !errors.Is(err, ErrVerifyLimit) {
+		t.Error("success read message over the limit of verification")
+		return
+	}
+}
+
+func TestProtocolPreamble(t *testing.T) {
+	t.Parallel()
+
+	privKey := asymmetric.NewPrivKey()
+	sett1 := testNewPreambleSettings(privKey, tcMsgSize, false)
+	sett2 := testNewPreambleSettings(privKey, tcMsgSize/2, false)
+
+	conn1, conn2, err := testPipeLink(sett1, sett2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn1.Close()
+	defer conn2.Close()
+
+	for _, c := range []IConn{conn1, conn2} {
+		if !c.GetFeatures().Has(CFeaturePing | CFeatureGossip) {
+			t.Error("features are not negotiated")
+			return
+		}
+	}
+
+	msgSett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: sett1.GetMessageSettings(),
+	})
+	msg := layer1.NewMessage(msgSett, payload.NewPayload32(tcHead, make([]byte, tcMsgSize/2+1)))
+
+	ctx := context.Background()
+	if err := conn1.WriteMessage(ctx, msg); !errors.Is(err, ErrPeerLimitSize) {
+		t.Error("success write message larger than peer limit")
+		return
+	}
+
+	// peer with another version of protocol
+	socket1, socket2 := net.Pipe()
+	defer socket1.Close()
+
+	go func() {
+		preamble := (&sPreamble{
+			fVersion:  CProtocolVersion + 1,
+			fFeatures: CFeaturePing,
+			fLimit:    tcMsgSize,
+		}).toBytes()
+		head := encoding.Uint32ToBytes(uint32(len(preamble)))
+		_, _ = socket1.Write(bytes.Join([][]byte{head[:], preamble}, []byte{}))
+		_, _ = socket1.Read(make([]byte, encoding.CSizeUint32+cPreambleSize))
+	}()
+
+	_, err = AcceptConn(ctx, sett1, socket2)
+	if !errors.Is(err, ErrIncompatibleVersion) {
+		t.Errorf("success accept incompatible peer (%v)", err)
+		return
+	}
+	if !strings.Contains(err.Error(), fmt.Sprintf("peer version = %d", CProtocolVersion+1)) {
+		t.Error("error does not describe the versions")
+		return
+	}
+
+	// peer with the first version of preamble (without the puzzle)
+	socket3, socket4 := net.Pipe()
+	defer socket3.Close()
+
+	go func() {
+		preamble := (&sPreamble{
+			fVersion:  1,
+			fFeatures: CFeaturePing,
+			fLimit:    tcMsgSize,
+		}).toBytes()
+		preamble = preamble[:len(preamble)-1-encoding.CSizeUint64]
+		head := encoding.Uint32ToBytes(uint32(len(preamble)))
+		_, _ = socket3.Write(bytes.Join([][]byte{head[:], preamble}, []byte{}))
+		_, _ = socket3.Read(make([]byte, encoding.CSizeUint32+cPreambleSize))
+	}()
+
+	if _, err := AcceptConn(ctx, sett1, socket4); !errors.Is(err, ErrIncompatibleVersion) {
+		t.Errorf("success accept peer with the first version (%v)", err)
+		return
+	}
+
+	// peer with another type of puzzle
+	argonSett := NewSettings(&SSettings{
+		FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+			FWorkSizeBits: tcWorkSize,
+			FPuzzleType:   puzzle.CPuzzleTypeArgon2,
+			FPuzzleMemory: (1 << 10),
+		}),
+		FLimitMessageSizeBytes: tcMsgSize,
+		FWaitReadTimeout:       time.Hour,
+		FDialTimeout:           time.Minute,
+		FReadTimeout:           time.Second,
+		FWriteTimeout:          time.Second,
+		FProtocolPreamble:      true,
+	})
+	if _, _, err := testPipeLink(argonSett, sett1); !errors.Is(err, ErrIncompatiblePuzzle) {
+		t.Errorf("success link with incompatible puzzle (%v)", err)
+		return
+	}
+
+	// peer with another memory of puzzle
+	argonSett2 := NewSettings(&SSettings{
+		FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+			FWorkSizeBits: tcWorkSize,
+			FPuzzleType:   puzzle.CPuzzleTypeArgon2,
+			FPuzzleMemory: (2 << 10),
+		}),
+		FLimitMessageSizeBytes: tcMsgSize,
+		FWaitReadTimeout:       time.Hour,
+		FDialTimeout:           time.Minute,
+		FReadTimeout:           time.Second,
+		FWriteTimeout:          time.Second,
+		FProtocolPreamble:      true,
+	})
+	if _, _, err := testPipeLink(argonSett, argonSett2); !errors.Is(err, ErrIncompatiblePuzzle) {
+		t.Errorf("success link with incompatible memory of puzzle (%v)", err)
+		return
+	}
+
+	if _, err := loadPreamble([]byte("invalid preamble")); !errors.Is(err, ErrInvalidPreamble) {
+		t.Error("success load invalid preamble")
+		return
+	}
+}
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+
+	privKey := asymmetric.NewPrivKey()
+	sett := testNewPreambleSettings(privKey, tcMsgSize, true)
+
+	// compression is not used if one of the sides does not support it
+	conn1, conn2, err := testPipeLink(sett, testNewPreambleSettings(privKey, tcMsgSize, false))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conn1.Close()
+	conn2.Close()
+
+	if conn1.GetFeatures().Has(CFeatureCompression) || conn2.GetFeatures().Has(CFeatureCompression) {
+		t.Error("compression is used without the support of peer")
+		return
+	}
+
+	conn3, conn4, err := testPipeLink(sett, sett)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn3.Close()
+	defer conn4.Close()
+
+	if !conn3.GetFeatures().Has(CFeatureCompression) {
+		t.Error("compression is not negotiated")
+		return
+	}
+
+	msgSett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: sett.GetMessageSettings(),
+	})
+	body := []byte(strings.Repeat(tcBody, tcMsgSize/(2*len(tcBody))))
+	msg := layer1.NewMessage(msgSett, payload.NewPayload32(tcHead, body))
+
+	ctx := context.Background()
+	bytesBefore := conn3.GetStats().GetBytesOut()
+	go func() { _ = conn3.WriteMessage(ctx, msg) }()
+
+	readCh := make(chan struct{}, 1)
+	msgRecv, err := conn4.ReadMessage(ctx, readCh)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(msgRecv.GetPayload().GetBody(), body) {
+		t.Error("load payload not equal new payload")
+		return
+	}
+
+	err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		sent := conn3.GetStats().GetBytesOut() - bytesBefore
+		if sent == 0 || sent >= uint64(len(msg.ToBytes())) {
+			return errors.New("message is not compressed")
+		}
+		return nil
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+
+	// decompression bomb
+	bomb, ok := compressBytes(make([]byte, 4*tcMsgSize))
+	if !ok {
+		t.Error("zero bytes are not compressed")
+		return
+	}
+	if _, err := decompressBytes(bomb, tcMsgSize); !errors.Is(err, ErrInvalidCompression) {
+		t.Error("success decompress bytes larger than limit")
+		return
+	}
+}
+
+func testReadLoop(pCtx context.Context, pConn IConn) {
+	readCh := make(chan struct{}, 16)
+	go func() {
+		for range readCh {
+		}
+	}()
+	for {
+		if _, err := pConn.ReadMessage(pCtx, readCh); err != nil {
+			return
+		}
+	}
+}
+
+func testNewPingSettings(pInterval time.Duration) ISettings {
+	return NewSettings(&SSettings{
+		FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+			FWorkSizeBits: tcWorkSize,
+			FNetworkKey:   "network_key",
+		}),
+		FLimitMessageSizeBytes: tcMsgSize,
+		FWaitReadTimeout:       time.Hour,
+		FDialTimeout:           time.Minute,
+		FReadTimeout:           time.Second,
+		FWriteTimeout:          time.Second,
+		FNetworkKeyProof:       true,
+		FPingInterval:          pInterval,
+		FPingTimeout:           5 * pInterval,
+	})
+}
+
+func testNewPreambleSettings(pPrivKey asymmetric.IPrivKey, pLimit uint64, pCompression bool) ISettings {
+	return NewSettings(&SSettings{
+		FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+			FWorkSizeBits: tcWorkSize,
+		}),
+		FLimitMessageSizeBytes: pLimit,
+		FWaitReadTimeout:       time.Hour,
+		FDialTimeout:           time.Minute,
+		FReadTimeout:           time.Second,
+		FWriteTimeout:          time.Second,
+		FProtocolPreamble:      true,
+		FCompression:           pCompression,
+		FLinkPrivKey:           pPrivKey,
+	})
+}
+
+func testNewProofSettings(pNetworkKey string, pWorkSize uint64) ISettings {
+	return NewSettings(&SSettings{
+		FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+			FWorkSizeBits: pWorkSize,
+			FNetworkKey:   pNetworkKey,
+		}),
+		FLimitMessageSizeBytes: tcMsgSize,
+		FWaitReadTimeout:       time.Hour,
+		FDialTimeout:           time.Minute,
+		FReadTimeout:           time.Second,
+		FWriteTimeout:          time.Second,
+		FNetworkKeyProof:       true,
+	})
+}
+
+func testNewPuzzleProofSettings(pNetworkKey string, pWorkSize, pMemory uint64) ISettings {
+	return NewSettings(&SSettings{
+		FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+			FWorkSizeBits: pWorkSize,
+			FNetworkKey:   pNetworkKey,
+			FPuzzleType:   puzzle.CPuzzleTypeArgon2,
+			FPuzzleMemory: pMemory,
+		}),
+		FLimitMessageSizeBytes: tcMsgSize,
+		FWaitReadTimeout:       time.Hour,
+		FDialTimeout:           time.Minute,
+		FReadTimeout:           time.Second,
+		FWriteTimeout:          time.Second,
+		FNetworkKeyProof:       true,
+	})
+}
+
+func testPipeLink(pInitSett, pAcceptSett ISettings) (IConn, IConn, error) {
+	ctx := context.Background()
+	pipeTransport := transport.NewPipeTransport()
+
+	listener, err := pipeTransport.Listen(ctx, "service")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer listener.Close()
+
+	type sResult struct {
+		fConn IConn
+		fErr  error
+	}
+
+	chResult := make(chan sResult, 1)
+	go func() {
+		socket, err := listener.Accept()
+		if err != nil {
+			chResult <- sResult{fErr: err}
+			return
+		}
+		conn, err := AcceptConn(ctx, pAcceptSett, socket)
+		chResult <- sResult{fConn: conn, fErr: err}
+	}()
+
+	conn1, err1 := ConnectWith(ctx, pInitSett, pipeTransport, "service")
+	result := <-chResult
+	if err := errors.Join(err1, result.fErr); err != nil {
+		if conn1 != nil {
+			conn1.Close()
+		}
+		if result.fConn != nil {
+			result.fConn.Close()
+		}
+		return nil, nil, err
+	}
+
+	return conn1, result.fConn, nil
+}
+
+func testNewLinkSettings(pPrivKey asymmetric.IPrivKey, pPubKeys asymmetric.IMapPubKeys) ISettings {
+	return NewSettings(&SSettings{
+		FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+			FWorkSizeBits: tcWorkSize,
+		}),
+		FLimitMessageSizeBytes: tcMsgSize,
+		FWaitReadTimeout:       time.Hour,
+		FDialTimeout:           time.Minute,
+		FReadTimeout:           time.Second,
+		FWriteTimeout:          time.Second,
+		FLinkPrivKey:           pPrivKey,
+		FLinkPubKeys:           pPubKeys,
+	})
+}
+
 func testNewService(t *testing.T, pAddr, pNetworkKey string) net.Listener {
 	listener, err := net.Listen("tcp", pAddr)
 	if err != nil {
@@ -0,0 +1,64 @@
+package conn
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	// High bit of the length = control frame.
+	cControlFlag = uint32(1 << 31)
+	cSizeMask    = cCompressFlag - 1
+)
+
+const (
+	CMaxControlSize = (1 << 10)
+)
+
+// Saves the function which receives the control frames. Control frames
+// without the handler are ignored. Types of the control frames (first byte)
+// which are >= 0xF0 are reserved by the conn (keep-alive).
+func (p *sConn) SetControlHandler(pHandle IControlF) {
+	p.fControlMutex.Lock()
+	defer p.fControlMutex.Unlock()
+
+	p.fControlHandle = pHandle
+}
+
+// Sends the control frame. Control frames are not the messages of layer1,
+// so they are not checked by the proof of work and by the network key.
+func (p *sConn) WriteControl(pCtx context.Context, pData []byte) error {
+	if len(pData) == 0 || len(pData) > CMaxControlSize {
+		return ErrInvalidControlSize
+	}
+
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	frame := getFrameBuffer()
+	defer putFrameBuffer(frame)
+
+	if p.fSendCipher != nil {
+		*frame = p.fSendCipher.appendEncrypted(*frame, pData)
+	} else {
+		*frame = append(*frame, pData...)
+	}
+
+	setFrameHead(*frame, cControlFlag)
+	if err := p.sendBytes(pCtx, *frame); err != nil {
+		return errors.Join(ErrSendControlBytes, err)
+	}
+
+	return nil
+}
+
+func (p *sConn) handleControl(pData []byte) {
+	p.fControlMutex.Lock()
+	handle := p.fControlHandle
+	p.fControlMutex.Unlock()
+
+	if handle == nil {
+		return
+	}
+	handle(pData)
+}
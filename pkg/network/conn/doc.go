@@ -5,7 +5,27 @@
 
 	L(M) || M
 	where
-		L - length (uint64)
+		L - length with the flags (uint32)
 		M - message bytes
+
+	Length of M is L & 0x3FFFFFFF, two high bits of L are the flags:
+		bit 31 - control frame
+		bit 30 - compressed message
+
+	If the link handshake is enabled (FLinkPrivKey != nil) then
+	M = E(K, message bytes), where E - AES-GCM cipher and K - session
+	key of the link direction (see handshake.go).
+
+	If the bit 31 of L is set then M is the control frame (not the
+	message of layer1, see control.go). Control frames are also used by
+	the keep-alive (see keepalive.go). The control frame can not be
+	compressed and it does not start the read timeout of message.
+
+	If the preamble is enabled (FProtocolPreamble = true) then the version
+	of protocol, the features and the limit of message size are exchanged
+	before the other steps of establishment (see preamble.go).
+
+	If the bit 30 of L is set then M is the compressed message (see
+	compress.go). The compression is negotiated by the preamble.
 */
 package conn
@@ -24,4 +24,22 @@ var (
 	ErrCreateConnection    = &SConnError{"create connection"}
 	ErrSetReadDeadline     = &SConnError{"set read deadline"}
 	ErrSetWriteDeadline    = &SConnError{"set write deadline"}
+	ErrHandshake           = &SConnError{"handshake"}
+	ErrInvalidFrameSize    = &SConnError{"invalid frame size"}
+	ErrInvalidPeerPubKey   = &SConnError{"invalid peer public key"}
+	ErrInvalidPeerSign     = &SConnError{"invalid peer signature"}
+	ErrUntrustedPeerPubKey = &SConnError{"untrusted peer public key"}
+	ErrDecapsulateKey      = &SConnError{"decapsulate key"}
+	ErrEncapsulateKey      = &SConnError{"encapsulate key"}
+	ErrDecryptFrame        = &SConnError{"decrypt frame"}
+	ErrInvalidNetworkProof = &SConnError{"invalid network key proof"}
+	ErrInvalidControlSize  = &SConnError{"invalid control size"}
+	ErrSendControlBytes    = &SConnError{"send control bytes"}
+	ErrPeerIsDead          = &SConnError{"peer is dead"}
+	ErrInvalidPreamble     = &SConnError{"invalid preamble"}
+	ErrIncompatibleVersion = &SConnError{"incompatible protocol version"}
+	ErrIncompatiblePuzzle  = &SConnError{"incompatible puzzle type"}
+	ErrPeerLimitSize       = &SConnError{"message size exceeds peer limit"}
+	ErrInvalidCompression  = &SConnError{"invalid compression"}
+	ErrVerifyLimit         = &SConnError{"limit of verified messages"}
 )
@@ -0,0 +1,280 @@
+package conn
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"time"
+
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+	"github.com/number571/go-peer/pkg/crypto/hashing"
+	"github.com/number571/go-peer/pkg/crypto/symmetric"
+	"github.com/number571/go-peer/pkg/encoding"
+	"github.com/number571/go-peer/pkg/payload/joiner"
+)
+
+/*
+	LINK HANDSHAKE (initiator = I, responder = R)
+
+	1. I -> R: EK
+	2. R -> I: C || PK(R) || S(R, T1)
+	3. I -> R: E(K_IR, PK(I) || S(I, T2))
+	where
+		EK     - ephemeral KEM public key of the initiator
+		C      - ciphertext of the encapsulated shared secret SS to EK
+		PK(X)  - static public key of the X side
+		S(X,T) - signature of the X side
+		T1     - H(L || PH || EK || C || PK(R))
+		T2     - H(T1 || PK(I))
+		K_IR   - HMAC(HMAC(SS, T1), "I->R"), initiator -> responder key
+		K_RI   - HMAC(HMAC(SS, T1), "R->I"), responder -> initiator key
+		L      - label of the protocol
+		PH     - hash of the preambles (empty if the preamble is disabled)
+
+	The ephemeral KEM key provides forward secrecy of the link.
+	The identity of the initiator is hidden under the session key.
+*/
+
+const (
+	cLinkNonceSize = 12
+	cLinkTagSize   = 16
+)
+
+const (
+	cHandshakeResponseSize = asymmetric.CKEMCiphertextSize + asymmetric.CPubKeySize + asymmetric.CDSASignSize
+	cHandshakeFinishSize   = asymmetric.CPubKeySize + asymmetric.CDSASignSize + cLinkTagSize
+)
+
+var (
+	gHandshakeLabel = []byte("go-peer/network/conn/handshake")
+	gKeyLabelIR     = []byte("I->R")
+	gKeyLabelRI     = []byte("R->I")
+)
+
+type sLinkCipher struct {
+	fAEAD    cipher.AEAD
+	fCounter uint64
+	fNonce   [cLinkNonceSize]byte
+}
+
+func newLinkCipher(pKey []byte) *sLinkCipher {
+	block, err := aes.NewCipher(pKey)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &sLinkCipher{fAEAD: aead}
+}
+
+// Nonce = 0x00000000 || counter(uint64), keys are unique for each link and direction.
+func (p *sLinkCipher) nextNonce() []byte {
+	counter := encoding.Uint64ToBytes(p.fCounter)
+	copy(p.fNonce[cLinkNonceSize-encoding.CSizeUint64:], counter[:])
+	p.fCounter++
+	return p.fNonce[:]
+}
+
+func (p *sLinkCipher) encryptBytes(pMsg []byte) []byte {
+	return p.appendEncrypted(nil, pMsg)
+}
+
+// Appends the encrypted bytes to the destination (frame buffer).
+func (p *sLinkCipher) appendEncrypted(pDst, pMsg []byte) []byte {
+	return p.fAEAD.Seal(pDst, p.nextNonce(), pMsg, nil)
+}
+
+// Decrypts the bytes in place, so the input bytes are overwritten.
+func (p *sLinkCipher) decryptBytes(pMsg []byte) ([]byte, error) {
+	return p.fAEAD.Open(pMsg[:0], p.nextNonce(), pMsg, nil)
+}
+
+func (p *sConn) handshakeInitiator(pCtx context.Context) error {
+	privKey := p.fSettings.GetLinkPrivKey()
+	ephPrivKey := asymmetric.NewKEMPrivKey()
+	ephPubKeyBytes := ephPrivKey.GetPubKey().ToBytes()
+
+	if err := p.sendFrame(pCtx, ephPubKeyBytes); err != nil {
+		return err
+	}
+
+	response, err := p.recvFrame(pCtx, cHandshakeResponseSize)
+	if err != nil {
+		return err
+	}
+
+	var (
+		ciphertext = response[:asymmetric.CKEMCiphertextSize]
+		pubKeyEnd  = asymmetric.CKEMCiphertextSize + asymmetric.CPubKeySize
+		pubKey     = response[asymmetric.CKEMCiphertextSize:pubKeyEnd]
+		sign       = response[pubKeyEnd:]
+	)
+
+	transcript := joinTranscript(gHandshakeLabel, p.fPreambleHash, ephPubKeyBytes, ciphertext, pubKey)
+	peerPubKey, err := p.verifyPeer(pubKey, transcript, sign)
+	if err != nil {
+		return err
+	}
+
+	secret, err := ephPrivKey.Decapsulate(ciphertext)
+	if err != nil {
+		return errors.Join(ErrDecapsulateKey, err)
+	}
+
+	p.fSendCipher, p.fRecvCipher = deriveLinkCiphers(secret, transcript, true)
+	p.fPeerPubKey = peerPubKey
+
+	myPubKey := privKey.GetPubKey().ToBytes()
+	finTranscript := joinTranscript(transcript, myPubKey)
+	finish := bytes.Join(
+		[][]byte{myPubKey, privKey.GetDSAPrivKey().SignBytes(finTranscript)},
+		[]byte{},
+	)
+
+	return p.sendFrame(pCtx, p.fSendCipher.encryptBytes(finish))
+}
+
+func (p *sConn) handshakeResponder(pCtx context.Context) error {
+	privKey := p.fSettings.GetLinkPrivKey()
+
+	ephPubKeyBytes, err := p.recvFrame(pCtx, asymmetric.CKEMPubKeySize)
+	if err != nil {
+		return err
+	}
+
+	ephPubKey := asymmetric.LoadKEMPubKey(ephPubKeyBytes)
+	if ephPubKey == nil {
+		return ErrInvalidPeerPubKey
+	}
+
+	ciphertext, secret, err := ephPubKey.Encapsulate()
+	if err != nil {
+		return errors.Join(ErrEncapsulateKey, err)
+	}
+
+	myPubKey := privKey.GetPubKey().ToBytes()
+	transcript := joinTranscript(gHandshakeLabel, p.fPreambleHash, ephPubKeyBytes, ciphertext, myPubKey)
+	response := bytes.Join(
+		[][]byte{ciphertext, myPubKey, privKey.GetDSAPrivKey().SignBytes(transcript)},
+		[]byte{},
+	)
+
+	if err := p.sendFrame(pCtx, response); err != nil {
+		return err
+	}
+
+	sendCipher, recvCipher := deriveLinkCiphers(secret, transcript, false)
+
+	encFinish, err := p.recvFrame(pCtx, cHandshakeFinishSize)
+	if err != nil {
+		return err
+	}
+
+	finish, err := recvCipher.decryptBytes(encFinish)
+	if err != nil {
+		return errors.Join(ErrDecryptFrame, err)
+	}
+
+	var (
+		pubKey = finish[:asymmetric.CPubKeySize]
+		sign   = finish[asymmetric.CPubKeySize:]
+	)
+
+	finTranscript := joinTranscript(transcript, pubKey)
+	peerPubKey, err := p.verifyPeer(pubKey, finTranscript, sign)
+	if err != nil {
+		return err
+	}
+
+	p.fSendCipher, p.fRecvCipher = sendCipher, recvCipher
+	p.fPeerPubKey = peerPubKey
+	return nil
+}
+
+// Loads the static public key of the peer, checks the signature of transcript
+// and checks the key in the list of trusted keys (if the list is set).
+func (p *sConn) verifyPeer(pPubKey, pTranscript, pSign []byte) (asymmetric.IPubKey, error) {
+	kemPubKey := asymmetric.LoadKEMPubKey(pPubKey[:asymmetric.CKEMPubKeySize])
+	dsaPubKey := asymmetric.LoadDSAPubKey(pPubKey[asymmetric.CKEMPubKeySize:])
+	if kemPubKey == nil || dsaPubKey == nil {
+		return nil, ErrInvalidPeerPubKey
+	}
+
+	if !dsaPubKey.VerifyBytes(pTranscript, pSign) {
+		return nil, ErrInvalidPeerSign
+	}
+
+	pubKey := asymmetric.NewPubKey(kemPubKey, dsaPubKey)
+	mapPubKeys := p.fSettings.GetLinkPubKeys()
+	if mapPubKeys != nil && mapPubKeys.GetPubKey(pubKey.GetHasher().ToBytes()) == nil {
+		return nil, ErrUntrustedPeerPubKey
+	}
+
+	return pubKey, nil
+}
+
+func (p *sConn) sendFrame(pCtx context.Context, pBytes []byte) error {
+	return p.sendBytes(pCtx, joiner.NewBytesJoiner32([][]byte{pBytes}))
+}
+
+// Handshake frames have fixed sizes, so the size of frame is checked strictly.
+func (p *sConn) recvFrame(pCtx context.Context, pMustSize uint32) ([]byte, error) {
+	return p.recvSizedFrame(pCtx, pMustSize, pMustSize)
+}
+
+// Size of frame can depend on the version of protocol (preamble).
+func (p *sConn) recvSizedFrame(pCtx context.Context, pMinSize, pMaxSize uint32) ([]byte, error) {
+	headBytes, err := p.recvDataBytes(pCtx, encoding.CSizeUint32, p.fSettings.GetReadTimeout())
+	if err != nil {
+		return nil, err
+	}
+
+	sizeBytes := [encoding.CSizeUint32]byte{}
+	copy(sizeBytes[:], headBytes)
+
+	frameSize := encoding.BytesToUint32(sizeBytes)
+	if frameSize < pMinSize || frameSize > pMaxSize {
+		return nil, ErrInvalidFrameSize
+	}
+
+	frameBytes, err := p.recvDataBytes(pCtx, frameSize, p.fSettings.GetReadTimeout())
+	if err != nil {
+		return nil, err
+	}
+
+	// read buffer is reused, but the frames of handshake are saved
+	return bytes.Clone(frameBytes), nil
+}
+
+func deriveLinkCiphers(pSecret, pTranscript []byte, pIsInitiator bool) (*sLinkCipher, *sLinkCipher) {
+	baseKey := hashing.NewHMACHasher(pSecret, pTranscript).ToBytes()
+
+	keyIR := hashing.NewHMACHasher(baseKey, gKeyLabelIR).ToBytes()[:symmetric.CCipherKeySize]
+	keyRI := hashing.NewHMACHasher(baseKey, gKeyLabelRI).ToBytes()[:symmetric.CCipherKeySize]
+
+	if pIsInitiator {
+		return newLinkCipher(keyIR), newLinkCipher(keyRI)
+	}
+	return newLinkCipher(keyRI), newLinkCipher(keyIR)
+}
+
+func joinTranscript(pParts ...[]byte) []byte {
+	return hashing.NewHasher(bytes.Join(pParts, []byte{})).ToBytes()
+}
+
+// Interrupts blocking operations of the socket when the context is done.
+func withSocketContext(pCtx context.Context, pConn *sConn, pF func() error) error {
+	stop := context.AfterFunc(pCtx, func() {
+		_ = pConn.fSocket.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if err := pF(); err != nil {
+		return errors.Join(ErrHandshake, err)
+	}
+	return nil
+}
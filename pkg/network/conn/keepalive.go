@@ -0,0 +1,173 @@
+package conn
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/number571/go-peer/pkg/crypto/random"
+	"github.com/number571/go-peer/pkg/encoding"
+)
+
+/*
+	KEEP-ALIVE (control frames)
+
+	1. A -> B: PING || N
+	2. B -> A: PONG || N
+	where
+		N - random nonce of the ping (uint64)
+
+	RTT = time of pong - time of ping. The peer is dead if
+	the pong is not received in the ping timeout.
+*/
+
+const (
+	// Control types >= cControlReserved are processed by the conn.
+	cControlReserved = byte(0xF0)
+	cControlPing     = byte(0xFE)
+	cControlPong     = byte(0xFF)
+)
+
+const (
+	cPingSize = 1 + encoding.CSizeUint64
+)
+
+type sPinger struct {
+	fNonce   uint64
+	fSentAt  time.Time
+	fPending bool
+}
+
+// Starts the keep-alive of the connection once. It is started by the pings
+// of the local side or by the first ping of the peer (to send the pongs).
+func (p *sConn) startKeepAlive() {
+	p.fKeepAliveOnce.Do(func() { go p.keepAlive() })
+}
+
+// Sends the pings with the interval and closes the connection
+// if the pong is not received in the timeout. Pongs of the peer's
+// pings are sent by the same goroutine to not block the reading.
+func (p *sConn) keepAlive() {
+	var tickerCh <-chan time.Time
+	if p.isPinger() {
+		ticker := time.NewTicker(p.fSettings.GetPingInterval())
+		defer ticker.Stop()
+		tickerCh = ticker.C
+	}
+
+	for {
+		select {
+		case <-p.fDone:
+			return
+		case nonce := <-p.fPongCh:
+			p.sendPong(nonce)
+		case <-tickerCh:
+			if p.isPongExpired() {
+				p.closeAsDead()
+				return
+			}
+			if err := p.sendPing(); err != nil {
+				p.closeAsDead()
+				return
+			}
+		}
+	}
+}
+
+func (p *sConn) isPinger() bool {
+	return p.fSettings.GetPingInterval() != 0 && p.fFeatures.Has(CFeaturePing)
+}
+
+func (p *sConn) sendPing() error {
+	p.fPingMutex.Lock()
+	if p.fPinger.fPending {
+		p.fPingMutex.Unlock()
+		return nil // waiting for the pong
+	}
+	nonce := random.NewRandom().GetUint64()
+	p.fPinger = sPinger{
+		fNonce:   nonce,
+		fSentAt:  time.Now(),
+		fPending: true,
+	}
+	p.fPingMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.fSettings.GetPingTimeout())
+	defer cancel()
+
+	return p.WriteControl(ctx, joinPing(cControlPing, nonce))
+}
+
+func (p *sConn) sendPong(pNonce uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.fSettings.GetWriteTimeout())
+	defer cancel()
+
+	_ = p.WriteControl(ctx, joinPing(cControlPong, pNonce))
+}
+
+// Pings of the peer are coalesced into one pending pong (the last nonce).
+func (p *sConn) pushPong(pNonce uint64) {
+	select {
+	case <-p.fPongCh:
+	default:
+	}
+	// reading is the only producer, so the slot is free
+	p.fPongCh <- pNonce
+	p.startKeepAlive()
+}
+
+func (p *sConn) isPongExpired() bool {
+	p.fPingMutex.Lock()
+	defer p.fPingMutex.Unlock()
+
+	if !p.fPinger.fPending {
+		return false
+	}
+	return time.Since(p.fPinger.fSentAt) > p.fSettings.GetPingTimeout()
+}
+
+func (p *sConn) closeAsDead() {
+	atomic.StoreInt32(&p.fDead, 1)
+	_ = p.Close()
+}
+
+func (p *sConn) isDead() bool {
+	return atomic.LoadInt32(&p.fDead) == 1
+}
+
+// Processes the control frames reserved by the conn.
+// Returns false if the control frame is not reserved.
+func (p *sConn) handleReserved(pData []byte) bool {
+	if pData[0] < cControlReserved {
+		return false
+	}
+	if len(pData) != cPingSize {
+		return true // invalid reserved frame is ignored
+	}
+
+	nonceBytes := [encoding.CSizeUint64]byte{}
+	copy(nonceBytes[:], pData[1:])
+	nonce := encoding.BytesToUint64(nonceBytes)
+
+	switch pData[0] {
+	case cControlPing:
+		p.pushPong(nonce)
+	case cControlPong:
+		p.fPingMutex.Lock()
+		defer p.fPingMutex.Unlock()
+
+		if !p.fPinger.fPending || p.fPinger.fNonce != nonce {
+			return true
+		}
+		p.fPinger.fPending = false
+		atomic.StoreInt64(&p.fCounters.fRTT, int64(time.Since(p.fPinger.fSentAt)))
+	}
+
+	return true
+}
+
+func joinPing(pType byte, pNonce uint64) []byte {
+	nonceBytes := encoding.Uint64ToBytes(pNonce)
+	return bytes.Join([][]byte{{pType}, nonceBytes[:]}, []byte{})
+}
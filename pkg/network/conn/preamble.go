@@ -0,0 +1,205 @@
+package conn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/number571/go-peer/pkg/crypto/puzzle"
+	"github.com/number571/go-peer/pkg/encoding"
+)
+
+/*
+	PREAMBLE (initiator = I, responder = R)
+
+	1. I -> R: P(I)
+	2. R -> I: P(R)
+	where
+		P(X) - MAGIC || VERSION || FEATURES || LIMIT || PUZZLE || MEMORY of the X side
+		LIMIT - limit of the message size (FLimitMessageSizeBytes)
+		PUZZLE - type of the proof of work puzzle (layer1 settings)
+		MEMORY - memory of the puzzle (= 0 for the SHA-384 proof of work)
+
+	Both sides use the common features (FEATURES(I) & FEATURES(R)) and
+	the minimal limit of the message size. Peers with other version or
+	with other puzzle (type or memory) are rejected. The size of preamble
+	depends on the version (v1 has not the PUZZLE and the MEMORY), so the version is checked before
+	the size to describe the incompatibility of peers. If the link handshake is enabled, then the hash of both
+	preambles is added into the transcript T1 (protection against the
+	downgrade of features).
+*/
+
+const (
+	// Version of the wire format (frames, control frames, handshake).
+	// v2: the type and the memory of puzzle are added into the preamble.
+	CProtocolVersion = 2
+)
+
+const (
+	CFeaturePing IFeatures = 1 << iota
+	CFeatureGossip
+	CFeatureCompression
+)
+
+const (
+	// Features which are used without the preamble.
+	cDefaultFeatures = CFeaturePing | CFeatureGossip
+)
+
+const (
+	cPreambleHeadSize = 4 + encoding.CSizeUint32 // MAGIC || VERSION
+	cPreambleSize     = cPreambleHeadSize + encoding.CSizeUint32 + encoding.CSizeUint64 + 1 + encoding.CSizeUint64
+)
+
+var (
+	gPreambleMagic = []byte("GPWF") // go-peer wire format
+)
+
+type sPreamble struct {
+	fVersion  uint32
+	fFeatures IFeatures
+	fLimit    uint64
+	fPuzzle   puzzle.IPuzzleType
+	fMemory   uint64
+}
+
+// Returns the features supported by this side of connection.
+func getLocalFeatures(pSett ISettings) IFeatures {
+	if pSett.GetCompression() {
+		return cDefaultFeatures | CFeatureCompression
+	}
+	return cDefaultFeatures
+}
+
+func (p *sConn) exchangePreamble(pCtx context.Context, pIsInitiator bool) error {
+	myPreamble := (&sPreamble{
+		fVersion:  CProtocolVersion,
+		fFeatures: getLocalFeatures(p.fSettings),
+		fLimit:    p.fSettings.GetLimitMessageSizeBytes(),
+		fPuzzle:   p.fSettings.GetMessageSettings().GetPuzzleType(),
+		fMemory:   p.fSettings.GetMessageSettings().GetPuzzleMemory(),
+	}).toBytes()
+
+	var (
+		peerPreamble []byte
+		err          error
+	)
+
+	if pIsInitiator {
+		if err := p.sendFrame(pCtx, myPreamble); err != nil {
+			return err
+		}
+		if peerPreamble, err = p.recvSizedFrame(pCtx, cPreambleHeadSize, cPreambleSize); err != nil {
+			return err
+		}
+	} else {
+		if peerPreamble, err = p.recvSizedFrame(pCtx, cPreambleHeadSize, cPreambleSize); err != nil {
+			return err
+		}
+		if err := p.sendFrame(pCtx, myPreamble); err != nil {
+			return err
+		}
+	}
+
+	peer, err := loadPreamble(peerPreamble)
+	if err != nil {
+		return err
+	}
+
+	if peer.fVersion != CProtocolVersion {
+		return errors.Join(
+			ErrIncompatibleVersion,
+			&SConnError{fmt.Sprintf("local version = %d, peer version = %d", CProtocolVersion, peer.fVersion)},
+		)
+	}
+	msgSett := p.fSettings.GetMessageSettings()
+	if myPuzzle := msgSett.GetPuzzleType(); peer.fPuzzle != myPuzzle {
+		return errors.Join(
+			ErrIncompatiblePuzzle,
+			&SConnError{fmt.Sprintf("local puzzle = %d, peer puzzle = %d", myPuzzle, peer.fPuzzle)},
+		)
+	}
+	if myMemory := msgSett.GetPuzzleMemory(); peer.fMemory != myMemory {
+		return errors.Join(
+			ErrIncompatiblePuzzle,
+			&SConnError{fmt.Sprintf("local memory = %d, peer memory = %d", myMemory, peer.fMemory)},
+		)
+	}
+	if peer.fLimit == 0 {
+		return ErrInvalidPreamble
+	}
+
+	p.fFeatures = getLocalFeatures(p.fSettings) & peer.fFeatures
+	p.fPeerLimit = min(p.fSettings.GetLimitMessageSizeBytes(), peer.fLimit)
+
+	if pIsInitiator {
+		p.fPreambleHash = joinTranscript(myPreamble, peerPreamble)
+	} else {
+		p.fPreambleHash = joinTranscript(peerPreamble, myPreamble)
+	}
+
+	return nil
+}
+
+// Preamble of the other version contains only the version.
+func loadPreamble(pBytes []byte) (*sPreamble, error) {
+	magicSize := len(gPreambleMagic)
+	if len(pBytes) < cPreambleHeadSize || !bytes.Equal(pBytes[:magicSize], gPreambleMagic) {
+		return nil, ErrInvalidPreamble
+	}
+
+	versionBytes := [encoding.CSizeUint32]byte{}
+	n := copy(versionBytes[:], pBytes[magicSize:])
+
+	version := encoding.BytesToUint32(versionBytes)
+	if version != CProtocolVersion {
+		return &sPreamble{fVersion: version}, nil
+	}
+	if len(pBytes) != cPreambleSize {
+		return nil, ErrInvalidPreamble
+	}
+
+	var (
+		featuresBytes = [encoding.CSizeUint32]byte{}
+		limitBytes    = [encoding.CSizeUint64]byte{}
+		memoryBytes   = [encoding.CSizeUint64]byte{}
+	)
+
+	n += copy(featuresBytes[:], pBytes[magicSize+n:])
+	n += copy(limitBytes[:], pBytes[magicSize+n:])
+	puzzleType := puzzle.IPuzzleType(pBytes[magicSize+n])
+	copy(memoryBytes[:], pBytes[magicSize+n+1:])
+
+	return &sPreamble{
+		fVersion:  version,
+		fFeatures: IFeatures(encoding.BytesToUint32(featuresBytes)),
+		fLimit:    encoding.BytesToUint64(limitBytes),
+		fPuzzle:   puzzleType,
+		fMemory:   encoding.BytesToUint64(memoryBytes),
+	}, nil
+}
+
+func (p *sPreamble) toBytes() []byte {
+	var (
+		versionBytes  = encoding.Uint32ToBytes(p.fVersion)
+		featuresBytes = encoding.Uint32ToBytes(uint32(p.fFeatures))
+		limitBytes    = encoding.Uint64ToBytes(p.fLimit)
+		memoryBytes   = encoding.Uint64ToBytes(p.fMemory)
+	)
+	return bytes.Join(
+		[][]byte{gPreambleMagic, versionBytes[:], featuresBytes[:], limitBytes[:], {byte(p.fPuzzle)}, memoryBytes[:]},
+		[]byte{},
+	)
+}
+
+// Returns the common features of both sides. Without the
+// preamble only the default features are used (ping, gossip).
+func (p *sConn) GetFeatures() IFeatures {
+	return p.fFeatures
+}
+
+// Returns true if the feature is used by both sides.
+func (p IFeatures) Has(pFeature IFeatures) bool {
+	return p&pFeature == pFeature
+}
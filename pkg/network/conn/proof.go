@@ -0,0 +1,113 @@
+package conn
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+
+	"github.com/number571/go-peer/pkg/crypto/hashing"
+	"github.com/number571/go-peer/pkg/crypto/keybuilder"
+	"github.com/number571/go-peer/pkg/crypto/random"
+	"github.com/number571/go-peer/pkg/crypto/symmetric"
+	"github.com/number571/go-peer/pkg/encoding"
+)
+
+/*
+	NETWORK KEY PROOF (initiator = I, responder = R)
+
+	1. I -> R: NI
+	2. R -> I: NR
+	3. I -> R: HMAC(K, "I" || NI || NR || W || PT || PM)
+	4. R -> I: HMAC(K, "R" || NI || NR || W || PT || PM)
+	where
+		NX - random challenge of the X side
+		K  - key built from the network key (as in the layer1 messages)
+		W  - work size bits of the layer1 messages
+		PT - type of the puzzle of the layer1 messages
+		PM - memory of the puzzle (= 0 for the SHA-384 proof of work)
+
+	The initiator proves the knowledge first, so the responder
+	does not give any proofs to the strangers.
+*/
+
+const (
+	cProofChallengeSize = 32
+	cProofSize          = hashing.CHasherSize
+)
+
+var (
+	gProofLabelI = []byte("I")
+	gProofLabelR = []byte("R")
+)
+
+func (p *sConn) proveNetworkKey(pCtx context.Context, pIsInitiator bool) error {
+	myChallenge := random.NewRandom().GetBytes(cProofChallengeSize)
+
+	var (
+		challengeI []byte
+		challengeR []byte
+	)
+
+	if pIsInitiator {
+		if err := p.sendFrame(pCtx, myChallenge); err != nil {
+			return err
+		}
+		peerChallenge, err := p.recvFrame(pCtx, cProofChallengeSize)
+		if err != nil {
+			return err
+		}
+		challengeI, challengeR = myChallenge, peerChallenge
+	} else {
+		peerChallenge, err := p.recvFrame(pCtx, cProofChallengeSize)
+		if err != nil {
+			return err
+		}
+		if err := p.sendFrame(pCtx, myChallenge); err != nil {
+			return err
+		}
+		challengeI, challengeR = peerChallenge, myChallenge
+	}
+
+	var (
+		proofI = p.newNetworkProof(gProofLabelI, challengeI, challengeR)
+		proofR = p.newNetworkProof(gProofLabelR, challengeI, challengeR)
+	)
+
+	if pIsInitiator {
+		if err := p.sendFrame(pCtx, proofI); err != nil {
+			return err
+		}
+		return p.recvNetworkProof(pCtx, proofR)
+	}
+
+	if err := p.recvNetworkProof(pCtx, proofI); err != nil {
+		return err
+	}
+	return p.sendFrame(pCtx, proofR)
+}
+
+func (p *sConn) recvNetworkProof(pCtx context.Context, pMustProof []byte) error {
+	gotProof, err := p.recvFrame(pCtx, cProofSize)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(gotProof, pMustProof) {
+		return ErrInvalidNetworkProof
+	}
+	return nil
+}
+
+func (p *sConn) newNetworkProof(pLabel, pChallengeI, pChallengeR []byte) []byte {
+	msgSett := p.fSettings.GetMessageSettings()
+
+	keyBuilder := keybuilder.NewKeyBuilder(0, []byte{}) // the network_key must have good entropy
+	key := keyBuilder.Build(msgSett.GetNetworkKey(), symmetric.CCipherKeySize)
+	workSize := encoding.Uint64ToBytes(msgSett.GetWorkSizeBits())
+	puzzleMemory := encoding.Uint64ToBytes(msgSett.GetPuzzleMemory())
+	puzzleType := []byte{byte(msgSett.GetPuzzleType())}
+
+	return hashing.NewHMACHasher(key, bytes.Join(
+		[][]byte{pLabel, pChallengeI, pChallengeR, workSize[:], puzzleType, puzzleMemory[:]},
+		[]byte{},
+	)).ToBytes()
+}
@@ -3,7 +3,15 @@ package conn
 import (
 	"time"
 
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+	"github.com/number571/go-peer/pkg/crypto/puzzle"
 	"github.com/number571/go-peer/pkg/message/layer1"
+	"github.com/number571/go-peer/pkg/network/ratelimit"
+)
+
+const (
+	// verification of the argon2 puzzle is one memory-hard hash (~46MiB, ~50ms)
+	cDefaultArgon2VerifyPerSec = 16
 )
 
 var (
@@ -18,6 +26,14 @@ type sSettings struct {
 	FReadTimeout           time.Duration
 	FWriteTimeout          time.Duration
 	FMessageSettings       layer1.ISettings
+	FNetworkKeyProof       bool
+	FProtocolPreamble      bool
+	FCompression           bool
+	FLinkPrivKey           asymmetric.IPrivKey
+	FLinkPubKeys           asymmetric.IMapPubKeys
+	FPingInterval          time.Duration
+	FPingTimeout           time.Duration
+	FVerifyLimit           ratelimit.ISettings
 }
 
 func NewSettings(pSett *SSettings) ISettings {
@@ -28,6 +44,14 @@ func NewSettings(pSett *SSettings) ISettings {
 		FDialTimeout:           pSett.FDialTimeout,
 		FReadTimeout:           pSett.FReadTimeout,
 		FWriteTimeout:          pSett.FWriteTimeout,
+		FNetworkKeyProof:       pSett.FNetworkKeyProof,
+		FProtocolPreamble:      pSett.FProtocolPreamble,
+		FCompression:           pSett.FCompression,
+		FLinkPrivKey:           pSett.FLinkPrivKey,
+		FLinkPubKeys:           pSett.FLinkPubKeys,
+		FPingInterval:          pSett.FPingInterval,
+		FPingTimeout:           pSett.FPingTimeout,
+		FVerifyLimit:           pSett.FVerifyLimit,
 	}).mustNotNull()
 }
 
@@ -50,6 +74,30 @@ func (p *sSettings) mustNotNull() ISettings {
 	if p.FWriteTimeout == 0 {
 		panic(`p.FWriteTimeout == 0`)
 	}
+	// p.FLinkPrivKey can be = nil (handshake is disabled)
+	// p.FLinkPubKeys can be = nil (any authenticated peer is accepted)
+	if p.FLinkPubKeys != nil && p.FLinkPrivKey == nil {
+		panic(`p.FLinkPubKeys != nil && p.FLinkPrivKey == nil`)
+	}
+	// p.FCompression can be = true only with the preamble (negotiation)
+	if p.FCompression && !p.FProtocolPreamble {
+		panic(`p.FCompression && !p.FProtocolPreamble`)
+	}
+	// p.FCompression can be = true only with the link encryption
+	if p.FCompression && p.FLinkPrivKey == nil {
+		panic(`p.FCompression && p.FLinkPrivKey == nil`)
+	}
+	// p.FPingInterval can be = 0 (keep-alive is disabled)
+	if p.FPingInterval != 0 && p.FPingTimeout == 0 {
+		panic(`p.FPingInterval != 0 && p.FPingTimeout == 0`)
+	}
+	// p.FVerifyLimit can be = nil (verification is unlimited, except argon2 puzzle)
+	if p.FVerifyLimit == nil && p.FMessageSettings.GetPuzzleType() == puzzle.CPuzzleTypeArgon2 {
+		p.FVerifyLimit = ratelimit.NewSettings(&ratelimit.SSettings{
+			FMessagesPerSec: cDefaultArgon2VerifyPerSec,
+			FDelayOnLimit:   true,
+		})
+	}
 	return p
 }
 
@@ -76,3 +124,38 @@ func (p *sSettings) GetReadTimeout() time.Duration {
 func (p *sSettings) GetWriteTimeout() time.Duration {
 	return p.FWriteTimeout
 }
+
+func (p *sSettings) GetNetworkKeyProof() bool {
+	return p.FNetworkKeyProof
+}
+
+func (p *sSettings) GetProtocolPreamble() bool {
+	return p.FProtocolPreamble
+}
+
+func (p *sSettings) GetCompression() bool {
+	return p.FCompression
+}
+
+func (p *sSettings) GetLinkPrivKey() asymmetric.IPrivKey {
+	return p.FLinkPrivKey
+}
+
+func (p *sSettings) GetLinkPubKeys() asymmetric.IMapPubKeys {
+	return p.FLinkPubKeys
+}
+
+func (p *sSettings) GetPingInterval() time.Duration {
+	return p.FPingInterval
+}
+
+func (p *sSettings) GetPingTimeout() time.Duration {
+	return p.FPingTimeout
+}
+
+// Limit of the messages verified (proof of work) by the connection. The message over
+// the limit is delayed before the verification or the connection is closed. It is
+// set by default for the argon2 puzzle, because each verification is expensive.
+func (p *sSettings) GetVerifyLimit() ratelimit.ISettings {
+	return p.FVerifyLimit
+}
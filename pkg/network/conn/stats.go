@@ -0,0 +1,110 @@
+package conn
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+var (
+	_ IStats = &sStats{}
+)
+
+type sCounters struct {
+	fBytesIn      uint64 // atomic variable
+	fBytesOut     uint64 // atomic variable
+	fMessagesIn   uint64 // atomic variable
+	fMessagesOut  uint64 // atomic variable
+	fInvalid      uint64 // atomic variable
+	fLastActivity int64  // atomic variable (unix nano)
+	fRTT          int64  // atomic variable (duration)
+}
+
+type sStats struct {
+	fBytesIn      uint64
+	fBytesOut     uint64
+	fMessagesIn   uint64
+	fMessagesOut  uint64
+	fInvalid      uint64
+	fLastActivity time.Time
+	fRTT          time.Duration
+}
+
+// Returns the snapshot of the traffic counters.
+// Bytes are counted on the socket level (with headers and handshake).
+func (p *sConn) GetStats() IStats {
+	stats := &sStats{
+		fBytesIn:     atomic.LoadUint64(&p.fCounters.fBytesIn),
+		fBytesOut:    atomic.LoadUint64(&p.fCounters.fBytesOut),
+		fMessagesIn:  atomic.LoadUint64(&p.fCounters.fMessagesIn),
+		fMessagesOut: atomic.LoadUint64(&p.fCounters.fMessagesOut),
+		fInvalid:     atomic.LoadUint64(&p.fCounters.fInvalid),
+		fRTT:         time.Duration(atomic.LoadInt64(&p.fCounters.fRTT)),
+	}
+	if lastActivity := atomic.LoadInt64(&p.fCounters.fLastActivity); lastActivity != 0 {
+		stats.fLastActivity = time.Unix(0, lastActivity)
+	}
+	return stats
+}
+
+func (p *sCounters) addBytesIn(pSize int) {
+	if pSize <= 0 {
+		return
+	}
+	atomic.AddUint64(&p.fBytesIn, uint64(pSize))
+	p.setActivity()
+}
+
+func (p *sCounters) addBytesOut(pSize int) {
+	if pSize <= 0 {
+		return
+	}
+	atomic.AddUint64(&p.fBytesOut, uint64(pSize))
+	p.setActivity()
+}
+
+func (p *sCounters) incMessagesIn() {
+	atomic.AddUint64(&p.fMessagesIn, 1)
+}
+
+func (p *sCounters) incMessagesOut() {
+	atomic.AddUint64(&p.fMessagesOut, 1)
+}
+
+func (p *sCounters) incInvalid() {
+	atomic.AddUint64(&p.fInvalid, 1)
+}
+
+func (p *sCounters) setActivity() {
+	atomic.StoreInt64(&p.fLastActivity, time.Now().UnixNano())
+}
+
+func (p *sStats) GetBytesIn() uint64 {
+	return p.fBytesIn
+}
+
+func (p *sStats) GetBytesOut() uint64 {
+	return p.fBytesOut
+}
+
+func (p *sStats) GetMessagesIn() uint64 {
+	return p.fMessagesIn
+}
+
+func (p *sStats) GetMessagesOut() uint64 {
+	return p.fMessagesOut
+}
+
+// Messages which failed the decryption or the loading of layer1.
+func (p *sStats) GetInvalidMessages() uint64 {
+	return p.fInvalid
+}
+
+// Returns zero time if nothing was sent or received.
+func (p *sStats) GetLastActivity() time.Time {
+	return p.fLastActivity
+}
+
+// Returns the round trip time of the last ping (zero if the keep-alive is disabled).
+func (p *sStats) GetRTT() time.Duration {
+	return p.fRTT
+}
@@ -6,17 +6,42 @@ import (
 	"net"
 	"time"
 
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
 	"github.com/number571/go-peer/pkg/message/layer1"
+	"github.com/number571/go-peer/pkg/network/ratelimit"
 )
 
+type (
+	IFeatures uint32
+)
+
+type IControlF func([]byte)
+
 type IConn interface {
 	io.Closer
 
 	GetSettings() ISettings
 	GetSocket() net.Conn
+	GetPeerPubKey() asymmetric.IPubKey
+	GetStats() IStats
+	GetFeatures() IFeatures
+	IsInbound() bool
 
 	WriteMessage(context.Context, layer1.IMessage) error
 	ReadMessage(context.Context, chan<- struct{}) (layer1.IMessage, error)
+
+	WriteControl(context.Context, []byte) error
+	SetControlHandler(IControlF)
+}
+
+type IStats interface {
+	GetBytesIn() uint64
+	GetBytesOut() uint64
+	GetMessagesIn() uint64
+	GetMessagesOut() uint64
+	GetInvalidMessages() uint64
+	GetLastActivity() time.Time
+	GetRTT() time.Duration
 }
 
 type ISettings interface {
@@ -26,4 +51,12 @@ type ISettings interface {
 	GetReadTimeout() time.Duration
 	GetWriteTimeout() time.Duration
 	GetWaitReadTimeout() time.Duration
+	GetNetworkKeyProof() bool
+	GetProtocolPreamble() bool
+	GetCompression() bool
+	GetLinkPrivKey() asymmetric.IPrivKey
+	GetLinkPubKeys() asymmetric.IMapPubKeys
+	GetPingInterval() time.Duration
+	GetPingTimeout() time.Duration
+	GetVerifyLimit() ratelimit.ISettings
 }
@@ -15,17 +15,32 @@ var (
 )
 
 type sConnKeeper struct {
+	fMutex    sync.Mutex
 	fState    state.IState
 	fNode     network.INode
 	fSettings ISettings
+	fWakeup   chan struct{}
+	fHealth   map[string]*sAddrHealth
+	fAdded    map[string]struct{}
+	fDeleted  map[string]struct{}
 }
 
+// Creates the keeper of connections. If the address book is set then
+// the handler of peer exchange is registered in the network node.
 func NewConnKeeper(pSett ISettings, pNode network.INode) IConnKeeper {
-	return &sConnKeeper{
+	connKeeper := &sConnKeeper{
 		fState:    state.NewBoolState(),
 		fNode:     pNode,
 		fSettings: pSett,
+		fWakeup:   make(chan struct{}, 1),
+		fHealth:   make(map[string]*sAddrHealth, 16),
+		fAdded:    make(map[string]struct{}, 16),
+		fDeleted:  make(map[string]struct{}, 16),
 	}
+	if pSett.GetAddrBook() != nil {
+		pNode.HandleFunc(pSett.GetPeerExchangeHead(), connKeeper.handlePeerExchange)
+	}
+	return connKeeper
 }
 
 func (p *sConnKeeper) GetNetworkNode() network.INode {
@@ -44,9 +59,16 @@ func (p *sConnKeeper) Run(pCtx context.Context) error {
 
 	for {
 		p.tryConnectToAll(pCtx)
+		if p.fSettings.GetAddrBook() != nil {
+			p.tryConnectFromBook(pCtx)
+			p.sharePeers(pCtx)
+		}
+		p.pruneHealth()
 		select {
 		case <-pCtx.Done():
 			return pCtx.Err()
+		case <-p.fWakeup:
+			// address is added
 		case <-time.After(p.fSettings.GetDuration()):
 			// next iter
 		}
@@ -57,7 +79,7 @@ func (p *sConnKeeper) tryConnectToAll(pCtx context.Context) {
 	chConnected := make(chan struct{})
 
 	go func() {
-		connList := p.fSettings.GetConnections()
+		connList := p.GetAddresses()
 		mapConns := p.fNode.GetConnections()
 
 		wg := sync.WaitGroup{}
@@ -69,7 +91,7 @@ func (p *sConnKeeper) tryConnectToAll(pCtx context.Context) {
 				if _, ok := mapConns[addr]; ok {
 					return
 				}
-				_ = p.fNode.AddConnection(pCtx, addr)
+				p.connectTo(pCtx, addr)
 			}(addr)
 		}
 
@@ -82,3 +104,77 @@ func (p *sConnKeeper) tryConnectToAll(pCtx context.Context) {
 	case <-chConnected:
 	}
 }
+
+// Maintains the target number of connections with the addresses from the book.
+func (p *sConnKeeper) tryConnectFromBook(pCtx context.Context) {
+	addrBook := p.fSettings.GetAddrBook()
+	mapConns := p.fNode.GetConnections()
+
+	staticConns := make(map[string]struct{}, 16)
+	for _, addr := range p.GetAddresses() {
+		staticConns[addr] = struct{}{}
+	}
+
+	connected := uint64(0)
+	candidates := make([]string, 0, addrBook.GetSettings().GetCapacity())
+	for _, info := range addrBook.GetAddresses() {
+		addr := info.GetAddress()
+		if _, ok := mapConns[addr]; ok {
+			connected++
+			continue
+		}
+		if _, ok := staticConns[addr]; ok {
+			continue
+		}
+		// addresses in the backoff do not take the places of others
+		if !p.isReadyToConnect(addr) {
+			continue
+		}
+		candidates = append(candidates, addr)
+	}
+
+	target := p.fSettings.GetTargetConnects()
+	if connected >= target {
+		return
+	}
+
+	need := target - connected
+	if uint64(len(candidates)) > need {
+		candidates = candidates[:need]
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(candidates))
+	for _, addr := range candidates {
+		go func(addr string) {
+			defer wg.Done()
+			p.connectTo(pCtx, addr)
+		}(addr)
+	}
+	wg.Wait()
+}
+
+// Connects to the address if the backoff is expired and saves
+// the result into the health of address and into the book (if exists).
+func (p *sConnKeeper) connectTo(pCtx context.Context, pAddress string) {
+	if !p.isReadyToConnect(pAddress) {
+		return
+	}
+
+	err := p.fNode.AddConnection(pCtx, pAddress)
+	addrBook := p.fSettings.GetAddrBook()
+
+	switch {
+	case err == nil:
+		p.setSuccess(pAddress)
+		if addrBook != nil {
+			_ = addrBook.SetSuccess(pAddress)
+		}
+	case errors.Is(err, network.ErrAddConnections):
+		// the address is unavailable (other errors = local limits)
+		p.setFailure(pAddress)
+		if addrBook != nil {
+			_ = addrBook.SetFailure(pAddress)
+		}
+	}
+}
@@ -4,17 +4,28 @@ package connkeeper
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/number571/go-peer/pkg/encoding"
 	"github.com/number571/go-peer/pkg/message/layer1"
 	"github.com/number571/go-peer/pkg/network"
+	"github.com/number571/go-peer/pkg/network/addrbook"
 	"github.com/number571/go-peer/pkg/network/conn"
+	"github.com/number571/go-peer/pkg/network/transport"
+	"github.com/number571/go-peer/pkg/payload"
 	"github.com/number571/go-peer/pkg/storage/cache"
+	"github.com/number571/go-peer/pkg/storage/database"
 	testutils "github.com/number571/go-peer/test/utils"
 )
 
+const (
+	tcPeerExchangeHead = 0x50455853
+)
+
 func TestError(t *testing.T) {
 	t.Parallel()
 
@@ -29,7 +40,7 @@ func TestError(t *testing.T) {
 func TestSettings(t *testing.T) {
 	t.Parallel()
 
-	for i := 0; i < 2; i++ {
+	for i := 0; i < 5; i++ {
 		testSettings(t, i)
 	}
 }
@@ -50,6 +61,25 @@ func testSettings(t *testing.T, n int) {
 		_ = NewSettings(&SSettings{
 			FConnections: func() []string { return []string{testutils.TgAddrs[7]} },
 		})
+	case 2:
+		_ = NewSettings(&SSettings{
+			FConnections: func() []string { return []string{testutils.TgAddrs[7]} },
+			FDuration:    500 * time.Millisecond,
+			FAddrBook:    testNewAddrBook(t),
+		})
+	case 3:
+		_ = NewSettings(&SSettings{
+			FConnections: func() []string { return []string{testutils.TgAddrs[7]} },
+			FDuration:    500 * time.Millisecond,
+			FMaxBackoff:  100 * time.Millisecond,
+		})
+	case 4:
+		_ = NewSettings(&SSettings{
+			FConnections:    func() []string { return []string{testutils.TgAddrs[7]} },
+			FDuration:       500 * time.Millisecond,
+			FAddrBook:       testNewAddrBook(t),
+			FTargetConnects: 4,
+		})
 	}
 }
 
@@ -120,6 +150,436 @@ func TestConnKeeper(t *testing.T) {
 	}
 }
 
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	sett := NewSettings(&SSettings{
+		FConnections: func() []string { return nil },
+		FDuration:    100 * time.Millisecond,
+		FMaxBackoff:  time.Second,
+	})
+
+	if sett.GetMaxBackoff() != time.Second {
+		t.Error("got invalid max backoff")
+		return
+	}
+
+	testBackoffs := []time.Duration{
+		100 * time.Millisecond,
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, backoff := range testBackoffs {
+		if getBackoff(sett, uint64(i)) != backoff {
+			t.Errorf("got invalid backoff (%d)", i)
+			return
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		jitter := withJitter(time.Second)
+		if jitter < time.Second/2 || jitter > time.Second {
+			t.Errorf("got invalid jitter (%s)", jitter)
+			return
+		}
+	}
+
+	defaultSett := NewSettings(&SSettings{
+		FConnections: func() []string { return nil },
+		FDuration:    100 * time.Millisecond,
+	})
+	if defaultSett.GetMaxBackoff() != defaultSett.GetDuration() {
+		t.Error("got invalid default max backoff")
+		return
+	}
+}
+
+func TestConnKeeperHealth(t *testing.T) {
+	t.Parallel()
+
+	pipeTransport := transport.NewPipeTransport()
+
+	connKeeper := NewConnKeeper(
+		NewSettings(&SSettings{
+			FConnections: func() []string { return []string{"node-dead"} },
+			FDuration:    20 * time.Millisecond,
+			FMaxBackoff:  time.Hour,
+		}),
+		newTestPipeNode(pipeTransport, ""),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = connKeeper.Run(ctx) }()
+
+	err1 := testutils.TryN(50, 20*time.Millisecond, func() error {
+		health, ok := connKeeper.GetHealth()["node-dead"]
+		if !ok || health.GetConsecutiveFailures() < 2 {
+			return errors.New("address is not failed")
+		}
+		return nil
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+
+	// the backoff grows exponentially, so there are a few new attempts
+	failures := connKeeper.GetHealth()["node-dead"].GetFailures()
+	sleepStart := time.Now()
+	time.Sleep(200 * time.Millisecond)
+	health := connKeeper.GetHealth()["node-dead"]
+	if health.GetFailures()-failures > 3 {
+		t.Errorf("dead address is not in backoff (%d)", health.GetFailures())
+		return
+	}
+	// the next attempt can be passed, but not yet done by the tick of keeper
+	if health.GetSuccesses() != 0 || health.GetNextAttempt().Before(sleepStart) {
+		t.Error("got invalid health of address")
+		return
+	}
+
+	nodeB := newTestPipeNode(pipeTransport, "node-b")
+	go func() { _ = nodeB.Run(ctx) }()
+
+	connKeeper.AddAddress("node-b")
+
+	err2 := testutils.TryN(50, 20*time.Millisecond, func() error {
+		health, ok := connKeeper.GetHealth()["node-b"]
+		if !ok || health.GetSuccesses() != 1 || health.GetConsecutiveFailures() != 0 {
+			return errors.New("address is not connected")
+		}
+		return nil
+	})
+	if err2 != nil {
+		t.Error(err2)
+		return
+	}
+
+	connKeeper.DelAddress("node-dead")
+
+	addrs := connKeeper.GetAddresses()
+	if len(addrs) != 1 || addrs[0] != "node-b" {
+		t.Error("got invalid addresses")
+		return
+	}
+	if _, ok := connKeeper.GetHealth()["node-dead"]; ok {
+		t.Error("health of deleted address is exist")
+		return
+	}
+
+	connKeeper.AddAddress("node-dead")
+	if len(connKeeper.GetAddresses()) != 2 {
+		t.Error("address is not added")
+		return
+	}
+}
+
+func TestConnectFromBookBackoff(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pipeTransport := transport.NewPipeTransport()
+
+	nodeB := newTestPipeNode(pipeTransport, "node-b")
+	go func() { _ = nodeB.Run(ctx) }()
+
+	addrBook := testNewAddrBook(t)
+	for _, addr := range []string{"node-a", "node-b"} {
+		if err := addrBook.AddAddress(addr); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	connKeeper := NewConnKeeper(
+		NewSettings(&SSettings{
+			FConnections:      func() []string { return nil },
+			FDuration:         time.Hour,
+			FAddrBook:         addrBook,
+			FPeerExchangeHead: tcPeerExchangeHead,
+			FTargetConnects:   1,
+		}),
+		newTestPipeNode(pipeTransport, ""),
+	).(*sConnKeeper)
+
+	// the first address of book is in the backoff
+	connKeeper.setFailure("node-a")
+
+	err1 := testutils.TryN(50, 20*time.Millisecond, func() error {
+		connKeeper.tryConnectFromBook(ctx)
+		if _, ok := connKeeper.GetNetworkNode().GetConnections()["node-b"]; !ok {
+			return errors.New("address is not connected")
+		}
+		return nil
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+}
+
+func newTestPipeNode(pTransport transport.ITransport, pAddr string) network.INode {
+	return network.NewNode(
+		network.NewSettings(&network.SSettings{
+			FAddress:      pAddr,
+			FTransport:    pTransport,
+			FMaxConnects:  16,
+			FReadTimeout:  time.Minute,
+			FWriteTimeout: time.Minute,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+					FWorkSizeBits: 1,
+				}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           time.Minute,
+				FWriteTimeout:          time.Minute,
+			}),
+		}),
+		cache.NewLRUCache(1024),
+	)
+}
+
+func TestPeerExchange(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pipeTransport := transport.NewPipeTransport()
+
+	// nodeB knows only nodeA, nodeC knows only nodeA
+	keeperA := newTestPeerExchangeKeeper(t, pipeTransport, "A", []string{})
+	keeperB := newTestPeerExchangeKeeper(t, pipeTransport, "B", []string{"A"})
+	keeperC := newTestPeerExchangeKeeper(t, pipeTransport, "C", []string{"A"})
+
+	for _, k := range []IConnKeeper{keeperA, keeperB, keeperC} {
+		go func(k IConnKeeper) { _ = k.GetNetworkNode().Run(ctx) }(k)
+		go func(k IConnKeeper) { _ = k.Run(ctx) }(k)
+	}
+
+	err1 := testutils.TryN(200, 20*time.Millisecond, func() error {
+		for _, addr := range []string{"A", "B"} {
+			if _, ok := keeperC.GetNetworkNode().GetConnections()[addr]; !ok {
+				return errors.New("node C is not connected to " + addr)
+			}
+		}
+		return nil
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+
+	info, ok := keeperC.GetSettings().GetAddrBook().GetAddress("B")
+	if !ok || info.GetLastSeen().IsZero() {
+		t.Error("address book is not updated")
+		return
+	}
+}
+
+func TestHandlePeerExchange(t *testing.T) {
+	t.Parallel()
+
+	keeper := newTestPeerExchangeKeeper(t, transport.NewPipeTransport(), "A", []string{}).(*sConnKeeper)
+	msgSett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: keeper.GetNetworkNode().GetSettings().GetConnSettings().GetMessageSettings(),
+	})
+
+	msg1 := layer1.NewMessage(msgSett, payload.NewPayload32(tcPeerExchangeHead, []byte("invalid")))
+	if err := keeper.handlePeerExchange(context.Background(), keeper.GetNetworkNode(), nil, msg1); err == nil {
+		t.Error("success handle invalid peer exchange")
+		return
+	}
+
+	peers := make([]string, cMaxSharedPeers+1)
+	for i := range peers {
+		peers[i] = fmt.Sprintf("peer-%d", i)
+	}
+	pexBytes := encoding.SerializeJSON(&sPeerExchange{FPeers: peers})
+	msg2 := layer1.NewMessage(msgSett, payload.NewPayload32(tcPeerExchangeHead, pexBytes))
+	if err := keeper.handlePeerExchange(context.Background(), keeper.GetNetworkNode(), nil, msg2); err == nil {
+		t.Error("success handle peer exchange with limit of addresses")
+		return
+	}
+
+	pexBytes = encoding.SerializeJSON(&sPeerExchange{FPeers: []string{"A", "D"}})
+	msg3 := layer1.NewMessage(msgSett, payload.NewPayload32(tcPeerExchangeHead, pexBytes))
+	if err := keeper.handlePeerExchange(context.Background(), keeper.GetNetworkNode(), nil, msg3); err != nil {
+		t.Error(err)
+		return
+	}
+
+	addrBook := keeper.GetSettings().GetAddrBook()
+	if _, ok := addrBook.GetAddress("A"); ok {
+		t.Error("own address saved into address book")
+		return
+	}
+	if _, ok := addrBook.GetAddress("D"); !ok {
+		t.Error("address is not saved into address book")
+		return
+	}
+}
+
+type tsBanNode struct {
+	network.INode
+	fBans map[string]time.Time
+}
+
+func (p *tsBanNode) GetBanList() map[string]time.Time {
+	return p.fBans
+}
+
+func TestHandlePeerExchangeLimits(t *testing.T) {
+	t.Parallel()
+
+	kvDB := &tsKVDatabase{fMap: make(map[string][]byte)}
+	addrBook, err := addrbook.NewAddrBook(
+		addrbook.NewSettings(&addrbook.SSettings{
+			FCapacity:    64,
+			FMaxFailures: 3,
+		}),
+		kvDB,
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	keeper := NewConnKeeper(
+		NewSettings(&SSettings{
+			FConnections:      func() []string { return nil },
+			FDuration:         time.Minute,
+			FAddrBook:         addrBook,
+			FPeerExchangeHead: tcPeerExchangeHead,
+			FTargetConnects:   4,
+		}),
+		newTestPipeNode(transport.NewPipeTransport(), "203.0.113.0:9571"),
+	).(*sConnKeeper)
+
+	node := &tsBanNode{
+		INode: keeper.GetNetworkNode(),
+		fBans: map[string]time.Time{"203.0.113.1": time.Now().Add(time.Hour)},
+	}
+
+	invalidPeers := []string{
+		"203.0.113.0:9571", // own address
+		"203.0.113.1:9571", // banned host
+		"invalid address",
+		"203.0.113.2:0",
+		"203.0.113.2:port",
+		"0.0.0.0:9571",
+		":9571",
+		"127.0.0.1:9571",   // loopback
+		"[::1]:9571",       // loopback
+		"localhost:9571",   // loopback
+		"10.0.0.2:9571",    // private
+		"192.168.0.2:9571", // private
+		"169.254.0.2:9571", // link-local
+	}
+	validPeers := make([]string, 0, cMaxNewPeers+1)
+	for i := 0; i < cMaxNewPeers+1; i++ {
+		validPeers = append(validPeers, fmt.Sprintf("198.51.100.%d:9571", i))
+	}
+
+	msgSett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: node.GetSettings().GetConnSettings().GetMessageSettings(),
+	})
+	pexBytes := encoding.SerializeJSON(&sPeerExchange{FPeers: append(invalidPeers, validPeers...)})
+	msg := layer1.NewMessage(msgSett, payload.NewPayload32(tcPeerExchangeHead, pexBytes))
+	if err := keeper.handlePeerExchange(context.Background(), node, nil, msg); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, addr := range invalidPeers {
+		if _, ok := addrBook.GetAddress(addr); ok {
+			t.Errorf("invalid address %s saved into address book", addr)
+			return
+		}
+	}
+	if len(addrBook.GetAddresses()) != cMaxNewPeers {
+		t.Error("count of new addresses is not limited by the exchange")
+		return
+	}
+	if kvDB.fSets != 1 {
+		t.Error("address book is saved many times by the exchange")
+		return
+	}
+}
+
+func newTestPeerExchangeKeeper(t *testing.T, pTransport transport.ITransport, pAddr string, pConns []string) IConnKeeper {
+	return NewConnKeeper(
+		NewSettings(&SSettings{
+			FConnections:      func() []string { return pConns },
+			FDuration:         50 * time.Millisecond,
+			FAddrBook:         testNewAddrBook(t),
+			FPeerExchangeHead: tcPeerExchangeHead,
+			FTargetConnects:   4,
+		}),
+		newTestPipeNode(pTransport, pAddr),
+	)
+}
+
+func testNewAddrBook(t *testing.T) addrbook.IAddrBook {
+	addrBook, err := addrbook.NewAddrBook(
+		addrbook.NewSettings(&addrbook.SSettings{
+			FCapacity:    64,
+			FMaxFailures: 3,
+		}),
+		&tsKVDatabase{fMap: make(map[string][]byte)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addrBook
+}
+
+type tsKVDatabase struct {
+	fMutex sync.Mutex
+	fMap   map[string][]byte
+	fSets  uint64
+}
+
+func (p *tsKVDatabase) Get(pKey []byte) ([]byte, error) {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	v, ok := p.fMap[string(pKey)]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	return v, nil
+}
+
+func (p *tsKVDatabase) Set(pKey []byte, pValue []byte) error {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	p.fMap[string(pKey)] = pValue
+	p.fSets++
+	return nil
+}
+
+func (p *tsKVDatabase) Del(pKey []byte) error {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	delete(p.fMap, string(pKey))
+	return nil
+}
+
+func (p *tsKVDatabase) Close() error { return nil }
+
 func newTestConnKeeper(pDuration time.Duration) IConnKeeper {
 	return NewConnKeeper(
 		NewSettings(&SSettings{
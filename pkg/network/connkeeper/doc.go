@@ -1,2 +1,8 @@
 // Package connkeeper allows you to periodically reconnect to the list of connections.
+//
+// If the address book is set, the connkeeper exchanges the known addresses with
+// the connected nodes and maintains the target number of connections from the book.
+//
+// Unavailable addresses are retried with the exponential backoff and jitter
+// (limited by the max backoff). The list of addresses can be changed at runtime.
 package connkeeper
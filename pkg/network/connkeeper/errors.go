@@ -13,5 +13,7 @@ func (err *SConnKeeperError) Error() string {
 }
 
 var (
-	ErrRunning = &SConnKeeperError{"connkeeper running"}
+	ErrRunning            = &SConnKeeperError{"connkeeper running"}
+	ErrDecodePeerExchange = &SConnKeeperError{"decode peer exchange"}
+	ErrLimitPeerExchange  = &SConnKeeperError{"limit of peer exchange addresses"}
 )
@@ -0,0 +1,192 @@
+package connkeeper
+
+import (
+	"time"
+
+	"github.com/number571/go-peer/pkg/crypto/random"
+)
+
+var (
+	_ IAddrHealth = &sAddrHealth{}
+)
+
+type sAddrHealth struct {
+	fSuccesses   uint64
+	fFailures    uint64
+	fConsecutive uint64
+	fNextAttempt time.Time
+}
+
+func (p *sAddrHealth) GetSuccesses() uint64 {
+	return p.fSuccesses
+}
+
+func (p *sAddrHealth) GetFailures() uint64 {
+	return p.fFailures
+}
+
+func (p *sAddrHealth) GetConsecutiveFailures() uint64 {
+	return p.fConsecutive
+}
+
+func (p *sAddrHealth) GetNextAttempt() time.Time {
+	return p.fNextAttempt
+}
+
+func (p *sConnKeeper) GetHealth() map[string]IAddrHealth {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	result := make(map[string]IAddrHealth, len(p.fHealth))
+	for addr, health := range p.fHealth {
+		healthCopy := *health
+		result[addr] = &healthCopy
+	}
+	return result
+}
+
+// Returns the addresses from the settings with the runtime changes.
+func (p *sConnKeeper) GetAddresses() []string {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	return p.getAddresses()
+}
+
+// Adds the address to the list of connections and wakes up the keeper.
+func (p *sConnKeeper) AddAddress(pAddress string) {
+	p.fMutex.Lock()
+	delete(p.fDeleted, pAddress)
+	p.fAdded[pAddress] = struct{}{}
+	p.fMutex.Unlock()
+
+	select {
+	case p.fWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Removes the address from the list of connections.
+// Existing connection with the address is not closed.
+func (p *sConnKeeper) DelAddress(pAddress string) {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	delete(p.fAdded, pAddress)
+	delete(p.fHealth, pAddress)
+	p.fDeleted[pAddress] = struct{}{}
+}
+
+func (p *sConnKeeper) getAddresses() []string {
+	connList := p.fSettings.GetConnections()
+
+	result := make([]string, 0, len(connList)+len(p.fAdded))
+	mapAddrs := make(map[string]struct{}, cap(result))
+	for _, addr := range connList {
+		if _, ok := p.fDeleted[addr]; ok {
+			continue
+		}
+		if _, ok := mapAddrs[addr]; ok {
+			continue
+		}
+		mapAddrs[addr] = struct{}{}
+		result = append(result, addr)
+	}
+	for addr := range p.fAdded {
+		if _, ok := mapAddrs[addr]; ok {
+			continue
+		}
+		mapAddrs[addr] = struct{}{}
+		result = append(result, addr)
+	}
+
+	return result
+}
+
+func (p *sConnKeeper) isReadyToConnect(pAddress string) bool {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	health, ok := p.fHealth[pAddress]
+	if !ok {
+		return true
+	}
+	return !time.Now().Before(health.fNextAttempt)
+}
+
+func (p *sConnKeeper) setSuccess(pAddress string) {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	health := p.getHealth(pAddress)
+	health.fSuccesses++
+	health.fConsecutive = 0
+	health.fNextAttempt = time.Time{}
+}
+
+func (p *sConnKeeper) setFailure(pAddress string) {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	health := p.getHealth(pAddress)
+	health.fFailures++
+	health.fConsecutive++
+
+	backoff := getBackoff(p.fSettings, health.fConsecutive)
+	health.fNextAttempt = time.Now().Add(withJitter(backoff))
+}
+
+func (p *sConnKeeper) getHealth(pAddress string) *sAddrHealth {
+	health, ok := p.fHealth[pAddress]
+	if !ok {
+		health = &sAddrHealth{}
+		p.fHealth[pAddress] = health
+	}
+	return health
+}
+
+// Deletes the health of addresses which are not in the list or in the book.
+func (p *sConnKeeper) pruneHealth() {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	mapAddrs := make(map[string]struct{}, len(p.fHealth))
+	for _, addr := range p.getAddresses() {
+		mapAddrs[addr] = struct{}{}
+	}
+
+	addrBook := p.fSettings.GetAddrBook()
+	for addr := range p.fHealth {
+		if _, ok := mapAddrs[addr]; ok {
+			continue
+		}
+		if addrBook != nil {
+			if _, ok := addrBook.GetAddress(addr); ok {
+				continue
+			}
+		}
+		delete(p.fHealth, addr)
+	}
+}
+
+// Backoff = min(duration * 2^(failures-1), max_backoff).
+func getBackoff(pSett ISettings, pFailures uint64) time.Duration {
+	var (
+		backoff    = pSett.GetDuration()
+		maxBackoff = pSett.GetMaxBackoff()
+	)
+	for i := uint64(1); i < pFailures && backoff < maxBackoff; i++ {
+		backoff <<= 1
+	}
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
+
+// Result is in the range [backoff/2, backoff].
+func withJitter(pBackoff time.Duration) time.Duration {
+	half := uint64(pBackoff / 2)
+	jitter := random.NewRandom().GetUint64() % (uint64(pBackoff) - half + 1)
+	return time.Duration(half + jitter)
+}
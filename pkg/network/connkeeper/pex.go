@@ -0,0 +1,188 @@
+package connkeeper
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/number571/go-peer/pkg/crypto/random"
+	"github.com/number571/go-peer/pkg/encoding"
+	"github.com/number571/go-peer/pkg/message/layer1"
+	"github.com/number571/go-peer/pkg/network"
+	"github.com/number571/go-peer/pkg/network/conn"
+	"github.com/number571/go-peer/pkg/payload"
+)
+
+const (
+	cMaxSharedPeers = 32
+	cMaxNewPeers    = 8
+	cMaxAddressSize = 255
+)
+
+// The nonce makes each message unique, otherwise
+// the message would be rejected by the cache of hashes.
+type sPeerExchange struct {
+	FNonce   uint64   `json:"nonce"`
+	FAddress string   `json:"address,omitempty"`
+	FPeers   []string `json:"peers,omitempty"`
+}
+
+// Shares the listening address of the node and the reliable addresses from the book.
+func (p *sConnKeeper) sharePeers(pCtx context.Context) {
+	addrBook := p.fSettings.GetAddrBook()
+	peers := make([]string, 0, cMaxSharedPeers)
+	for _, info := range addrBook.GetAddresses() {
+		if len(peers) == cMaxSharedPeers {
+			break
+		}
+		if info.GetFailures() != 0 || info.GetLastSeen().IsZero() {
+			continue
+		}
+		peers = append(peers, info.GetAddress())
+	}
+
+	ownAddress := p.fNode.GetSettings().GetAddress()
+	if ownAddress == "" && len(peers) == 0 {
+		return
+	}
+
+	pexBytes := encoding.SerializeJSON(&sPeerExchange{
+		FNonce:   random.NewRandom().GetUint64(),
+		FAddress: ownAddress,
+		FPeers:   peers,
+	})
+
+	sett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: p.fNode.GetSettings().GetConnSettings().GetMessageSettings(),
+	})
+	msg := layer1.NewMessage(sett, payload.NewPayload32(p.fSettings.GetPeerExchangeHead(), pexBytes))
+
+	// the node can have no connections
+	_ = p.fNode.BroadcastMessage(pCtx, msg)
+}
+
+// Saves the received addresses into the book. The message is not redirected.
+func (p *sConnKeeper) handlePeerExchange(
+	_ context.Context,
+	pNode network.INode,
+	pConn conn.IConn,
+	pMsg layer1.IMessage,
+) error {
+	var pex sPeerExchange
+	if err := encoding.DeserializeJSON(pMsg.GetPayload().GetBody(), &pex); err != nil {
+		return errors.Join(ErrDecodePeerExchange, err)
+	}
+
+	if len(pex.FPeers) > cMaxSharedPeers {
+		return ErrLimitPeerExchange
+	}
+
+	addresses := make([]string, 0, len(pex.FPeers)+1)
+	if pex.FAddress != "" {
+		addresses = append(addresses, withRemoteHost(pex.FAddress, pConn))
+	}
+	addresses = append(addresses, pex.FPeers...)
+
+	var (
+		addrBook   = p.fSettings.GetAddrBook()
+		banList    = pNode.GetBanList()
+		ownAddress = pNode.GetSettings().GetAddress()
+		allowLocal = p.fSettings.GetAllowLocalPeers()
+	)
+
+	// each exchange adds a few new addresses, so one peer
+	// can not replace the addresses of the book by its own
+	newAddresses := make([]string, 0, cMaxNewPeers)
+	for _, addr := range addresses {
+		if len(newAddresses) == cMaxNewPeers {
+			break
+		}
+		if addr == ownAddress || !isValidAddress(addr, allowLocal) {
+			continue
+		}
+		if _, ok := banList[getHost(addr)]; ok {
+			continue
+		}
+		if _, ok := addrBook.GetAddress(addr); ok {
+			continue
+		}
+		if slices.Contains(newAddresses, addr) {
+			continue
+		}
+		newAddresses = append(newAddresses, addr)
+	}
+
+	// the book can be full
+	_ = addrBook.AddAddresses(newAddresses)
+	return nil
+}
+
+// Address is host:port with the known host and the valid port.
+// Addresses without the port (pipe, unix) are only printable names.
+// Loopback, private and link-local hosts are rejected if the local
+// peers are not allowed (received addresses can point to the local network).
+func isValidAddress(pAddress string, pAllowLocal bool) bool {
+	if pAddress == "" || len(pAddress) > cMaxAddressSize {
+		return false
+	}
+	for _, c := range pAddress {
+		if !unicode.IsPrint(c) || unicode.IsSpace(c) {
+			return false
+		}
+	}
+
+	host, port, err := net.SplitHostPort(pAddress)
+	if err != nil {
+		return !strings.Contains(pAddress, ":")
+	}
+	if host == "" {
+		return false
+	}
+	if host == "localhost" && !pAllowLocal {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsUnspecified() {
+			return false
+		}
+		if !pAllowLocal && isLocalIP(ip) {
+			return false
+		}
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	return err == nil && portNum != 0
+}
+
+func isLocalIP(pIP net.IP) bool {
+	return pIP.IsLoopback() ||
+		pIP.IsPrivate() ||
+		pIP.IsLinkLocalUnicast()
+}
+
+func getHost(pAddress string) string {
+	host, _, err := net.SplitHostPort(pAddress)
+	if err != nil {
+		return pAddress
+	}
+	return host
+}
+
+// Address in the format ":port" is listened on all interfaces,
+// so the host is taken from the remote address of the connection.
+func withRemoteHost(pAddress string, pConn conn.IConn) string {
+	host, port, err := net.SplitHostPort(pAddress)
+	if err != nil || host != "" {
+		return pAddress
+	}
+
+	remoteHost, _, err := net.SplitHostPort(pConn.GetSocket().RemoteAddr().String())
+	if err != nil {
+		return pAddress
+	}
+
+	return net.JoinHostPort(remoteHost, port)
+}
@@ -1,6 +1,10 @@
 package connkeeper
 
-import "time"
+import (
+	"time"
+
+	"github.com/number571/go-peer/pkg/network/addrbook"
+)
 
 var (
 	_ ISettings = &sSettings{}
@@ -8,14 +12,24 @@ var (
 
 type SSettings sSettings
 type sSettings struct {
-	FConnections func() []string
-	FDuration    time.Duration
+	FConnections      func() []string
+	FDuration         time.Duration
+	FMaxBackoff       time.Duration
+	FAddrBook         addrbook.IAddrBook
+	FPeerExchangeHead uint32
+	FTargetConnects   uint64
+	FAllowLocalPeers  bool
 }
 
 func NewSettings(pSett *SSettings) ISettings {
 	return (&sSettings{
-		FConnections: pSett.FConnections,
-		FDuration:    pSett.FDuration,
+		FConnections:      pSett.FConnections,
+		FDuration:         pSett.FDuration,
+		FMaxBackoff:       pSett.FMaxBackoff,
+		FAddrBook:         pSett.FAddrBook,
+		FPeerExchangeHead: pSett.FPeerExchangeHead,
+		FTargetConnects:   pSett.FTargetConnects,
+		FAllowLocalPeers:  pSett.FAllowLocalPeers,
 	}).mustNotNull()
 }
 
@@ -26,6 +40,21 @@ func (p *sSettings) mustNotNull() ISettings {
 	if p.FConnections == nil {
 		panic(`p.FConnections == nil`)
 	}
+	// p.FMaxBackoff can be = 0 (retry every duration)
+	if p.FMaxBackoff == 0 {
+		p.FMaxBackoff = p.FDuration
+	}
+	if p.FMaxBackoff < p.FDuration {
+		panic(`p.FMaxBackoff < p.FDuration`)
+	}
+	// p.FAddrBook can be = nil (peer exchange is disabled)
+	if p.FAddrBook != nil && p.FTargetConnects == 0 {
+		panic(`p.FAddrBook != nil && p.FTargetConnects == 0`)
+	}
+	// head = 0 can collide with the routes of messages
+	if p.FAddrBook != nil && p.FPeerExchangeHead == 0 {
+		panic(`p.FAddrBook != nil && p.FPeerExchangeHead == 0`)
+	}
 	return p
 }
 
@@ -36,3 +65,23 @@ func (p *sSettings) GetConnections() []string {
 func (p *sSettings) GetDuration() time.Duration {
 	return p.FDuration
 }
+
+func (p *sSettings) GetMaxBackoff() time.Duration {
+	return p.FMaxBackoff
+}
+
+func (p *sSettings) GetAddrBook() addrbook.IAddrBook {
+	return p.FAddrBook
+}
+
+func (p *sSettings) GetPeerExchangeHead() uint32 {
+	return p.FPeerExchangeHead
+}
+
+func (p *sSettings) GetTargetConnects() uint64 {
+	return p.FTargetConnects
+}
+
+func (p *sSettings) GetAllowLocalPeers() bool {
+	return p.FAllowLocalPeers
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/number571/go-peer/pkg/network"
+	"github.com/number571/go-peer/pkg/network/addrbook"
 	"github.com/number571/go-peer/pkg/types"
 )
 
@@ -12,9 +13,26 @@ type IConnKeeper interface {
 
 	GetSettings() ISettings
 	GetNetworkNode() network.INode
+
+	GetHealth() map[string]IAddrHealth
+	GetAddresses() []string
+	AddAddress(string)
+	DelAddress(string)
+}
+
+type IAddrHealth interface {
+	GetSuccesses() uint64
+	GetFailures() uint64
+	GetConsecutiveFailures() uint64
+	GetNextAttempt() time.Time
 }
 
 type ISettings interface {
 	GetConnections() []string
 	GetDuration() time.Duration
+	GetMaxBackoff() time.Duration
+	GetAddrBook() addrbook.IAddrBook
+	GetPeerExchangeHead() uint32
+	GetTargetConnects() uint64
+	GetAllowLocalPeers() bool
 }
@@ -2,4 +2,22 @@
 //
 // The main purpose of the node is to process received messages from other nodes in the network.
 // The package creates a P2P connection between the network nodes.
+//
+// Lifecycle of the connections (connected, disconnected with the reason, write timeout,
+// protocol error) can be observed by the subscription to the events of the node.
+//
+// Protocol violations of the peers (invalid size, proof of work, auth hash, unknown route,
+// failed handshake or proof of network key) lower their score. Hosts reaching the ban
+// threshold are banned for the ban duration. Bans are keyed by the host, so the inbound
+// peers of transports without the stable host (pipe, unix) are not scored.
+//
+// Accepted sockets are established (preamble, network key proof, handshake) under the limit
+// of pending connections and one deadline, so strangers can not hold an unlimited count of sockets.
+//
+// Reading and broadcasting of messages can be limited by the rate (messages/sec, bytes/sec)
+// for the node and for each connection. Messages over the limit are delayed or dropped.
+// Delayed messages of the node can be ordered by the work of proof (priority queue).
+//
+// Broadcast can use the gossip fan-out: the message is sent to the random k connections
+// and other connections receive only the hash of message to pull it on demand (lazy push).
 package network
@@ -23,4 +23,11 @@ var (
 	ErrConnectionIsNotExist = &SNetworkError{"connection is not exist"}
 	ErrCloseConnection      = &SNetworkError{"close connection"}
 	ErrAddConnections       = &SNetworkError{"add connection"}
+	ErrReadTimeout          = &SNetworkError{"read timeout"}
+	ErrReadMessage          = &SNetworkError{"read message"}
+	ErrUnknownRoute         = &SNetworkError{"unknown route"}
+	ErrHandleMessage        = &SNetworkError{"handle message"}
+	ErrPeerIsBanned         = &SNetworkError{"peer is banned"}
+	ErrBanIsNotExist        = &SNetworkError{"ban is not exist"}
+	ErrRateLimit            = &SNetworkError{"rate limit"}
 )
@@ -0,0 +1,70 @@
+package network
+
+var (
+	_ IEvent = &sEvent{}
+)
+
+type sEvent struct {
+	fType    IEventType
+	fAddress string
+	fError   error
+}
+
+func newEvent(pType IEventType, pAddress string, pErr error) IEvent {
+	return &sEvent{
+		fType:    pType,
+		fAddress: pAddress,
+		fError:   pErr,
+	}
+}
+
+func (p *sEvent) GetType() IEventType {
+	return p.fType
+}
+
+func (p *sEvent) GetAddress() string {
+	return p.fAddress
+}
+
+// Error is nil for the connected events and for
+// the connections deleted by the DelConnection method.
+func (p *sEvent) GetError() error {
+	return p.fError
+}
+
+// Saves the function which receives all the connection events.
+// The function is called synchronously, so it should not block.
+// Returns the function which cancels the subscription.
+func (p *sNode) SubscribeEvents(pEvent IEventF) func() {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	id := p.fEventCounter
+	p.fEventCounter++
+	p.fEventRoutes[id] = pEvent
+
+	return func() {
+		p.fMutex.Lock()
+		defer p.fMutex.Unlock()
+
+		delete(p.fEventRoutes, id)
+	}
+}
+
+func (p *sNode) emitEvent(pType IEventType, pAddress string, pErr error) {
+	p.fMutex.RLock()
+	routes := make([]IEventF, 0, len(p.fEventRoutes))
+	for _, f := range p.fEventRoutes {
+		routes = append(routes, f)
+	}
+	p.fMutex.RUnlock()
+
+	if len(routes) == 0 {
+		return
+	}
+
+	event := newEvent(pType, pAddress, pErr)
+	for _, f := range routes {
+		f(event)
+	}
+}
@@ -0,0 +1,286 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/number571/go-peer/pkg/crypto/hashing"
+	"github.com/number571/go-peer/pkg/crypto/random"
+	"github.com/number571/go-peer/pkg/encoding"
+	"github.com/number571/go-peer/pkg/message/layer1"
+	"github.com/number571/go-peer/pkg/network/conn"
+	"github.com/number571/go-peer/pkg/storage/cache"
+)
+
+func newGossipCache(pCapacity uint64) *sGossipCache {
+	return &sGossipCache{
+		fMap:   make(map[string]layer1.IMessage, pCapacity),
+		fQueue: make([]string, pCapacity),
+	}
+}
+
+/*
+	LAZY PUSH (control frames)
+
+	1. A -> B: ANNOUNCE || H
+	2. B -> A: PULL || H (if B has not got the message)
+	3. A -> B: message with the hash H
+
+	The pull is requested from one peer at a time. If the peer does not
+	answer in the read timeout, the next announce of H requests it again.
+*/
+
+const (
+	cControlAnnounce = byte(1)
+	cControlPull     = byte(2)
+)
+
+const (
+	cDefaultControlPerSec = 1024
+	cControlQueueSize     = 64
+	cMaxConnPulls         = 64
+)
+
+// Messages are saved after the verification of proof, so the pulls
+// do not load (verify) them again. Old messages are replaced by the new ones.
+type sGossipCache struct {
+	fMutex sync.RWMutex
+	fMap   map[string]layer1.IMessage
+	fQueue []string
+	fIndex uint64
+}
+
+type sPull struct {
+	fConn     conn.IConn
+	fDeadline time.Time
+}
+
+type sTarget struct {
+	fAddress string
+	fConn    conn.IConn
+	fEager   bool
+}
+
+// Returns the connections receiving the message. With the fan-out only the random
+// k connections receive the message (eager), other connections receive the hash
+// of message (lazy) if the lazy push is enabled, otherwise they are skipped.
+// Connections without the gossip feature (see conn preamble) are always eager.
+func (p *sNode) selectTargets(pConnections map[string]conn.IConn) []sTarget {
+	targets := make([]sTarget, 0, len(pConnections))
+	for a, c := range pConnections {
+		targets = append(targets, sTarget{fAddress: a, fConn: c, fEager: true})
+	}
+
+	fanout := p.fSettings.GetGossipFanout()
+	if fanout == 0 || fanout >= uint64(len(targets)) {
+		return targets
+	}
+
+	// Fisher–Yates shuffle
+	prng := random.NewRandom()
+	for i := len(targets) - 1; i > 0; i-- {
+		j := prng.GetUint64() % uint64(i+1)
+		targets[i], targets[j] = targets[j], targets[i]
+	}
+
+	if !p.fSettings.GetGossipLazyPush() {
+		return targets[:fanout]
+	}
+
+	for i := fanout; i < uint64(len(targets)); i++ {
+		targets[i].fEager = !targets[i].fConn.GetFeatures().Has(conn.CFeatureGossip)
+	}
+	return targets
+}
+
+// Saves the message to serve the pull requests of the lazy push.
+func (p *sNode) storeMessage(pMsg layer1.IMessage) {
+	if p.fGossipCache == nil {
+		return
+	}
+	p.fGossipCache.set(pMsg)
+	p.completePull(pMsg.GetHash())
+}
+
+func (p *sNode) announceMessage(pCtx context.Context, pConn conn.IConn, pMsg layer1.IMessage) error {
+	return pConn.WriteControl(pCtx, joinControl(cControlAnnounce, pMsg.GetHash()))
+}
+
+// Control frames of the connection are processed in order by one worker.
+// Frames over the rate limit or over the size of queue are dropped.
+func (p *sNode) runControlWorker(pCtx context.Context, pAddress string, pConn conn.IConn) func() {
+	if p.fGossipCache == nil {
+		return func() {}
+	}
+
+	var (
+		controlCh = make(chan []byte, cControlQueueSize)
+		doneCh    = make(chan struct{})
+		limiter   = newLimiter(p.fSettings.GetGossipControlLimit())
+	)
+
+	pConn.SetControlHandler(func(pData []byte) {
+		if !limiter.Allow(uint64(len(pData))) {
+			return
+		}
+		select {
+		case controlCh <- pData:
+		default:
+			// queue is full
+		}
+	})
+
+	go func() {
+		defer p.releasePulls(pConn)
+
+		// pulls of the peer are answered once for each hash
+		served := cache.NewLRUCache(p.fSettings.GetGossipCacheSize())
+		for {
+			select {
+			case <-pCtx.Done():
+				return
+			case <-doneCh:
+				return
+			case data := <-controlCh:
+				p.handleControl(pCtx, pAddress, pConn, served, data)
+			}
+		}
+	}()
+
+	return func() { close(doneCh) }
+}
+
+// Processes the control frames of the lazy push. Unknown control frames are ignored.
+// Pulled messages are written by the limits of node as the broadcasted messages.
+func (p *sNode) handleControl(
+	pCtx context.Context,
+	pAddress string,
+	pConn conn.IConn,
+	pServed cache.ICache,
+	pData []byte,
+) {
+	if len(pData) != 1+hashing.CHasherSize {
+		return
+	}
+
+	hash := pData[1:]
+	switch pData[0] {
+	case cControlAnnounce:
+		if _, ok := p.fGossipCache.get(hash); ok {
+			return // message already exists
+		}
+		if !p.requestPull(pConn, hash) {
+			return // message already requested
+		}
+		_ = pConn.WriteControl(pCtx, joinControl(cControlPull, hash))
+	case cControlPull:
+		msg, ok := p.fGossipCache.get(hash)
+		if !ok {
+			return
+		}
+		if _, ok := pServed.Get(hash); ok {
+			return // message already sent
+		}
+		if err := p.writeMessage(pCtx, pAddress, pConn, msg, true); err != nil {
+			return // message is limited or the connection is deleted
+		}
+		_ = pServed.Set(hash, []byte{})
+	}
+}
+
+// Saves the pull of the message if it is not requested from another peer.
+// Count of the pulls is limited for the node and for each connection.
+func (p *sNode) requestPull(pConn conn.IConn, pHash []byte) bool {
+	p.fPullMutex.Lock()
+	defer p.fPullMutex.Unlock()
+
+	now := time.Now()
+	key := encoding.HexEncode(pHash)
+	if pull, ok := p.fPulls[key]; ok && now.Before(pull.fDeadline) {
+		return false
+	}
+
+	maxPulls := p.fSettings.GetGossipCacheSize()
+	if uint64(len(p.fPulls)) >= maxPulls || p.fPullCounts[pConn] >= cMaxConnPulls {
+		p.expirePulls(now)
+	}
+	if uint64(len(p.fPulls)) >= maxPulls || p.fPullCounts[pConn] >= cMaxConnPulls {
+		return false
+	}
+
+	p.deletePull(key)
+	p.fPulls[key] = sPull{
+		fConn:     pConn,
+		fDeadline: now.Add(p.fSettings.GetReadTimeout()),
+	}
+	p.fPullCounts[pConn]++
+	return true
+}
+
+// Pull is completed by the message with the hash.
+func (p *sNode) completePull(pHash []byte) {
+	p.fPullMutex.Lock()
+	defer p.fPullMutex.Unlock()
+
+	p.deletePull(encoding.HexEncode(pHash))
+}
+
+// Pulls of the closed connection are never answered.
+func (p *sNode) releasePulls(pConn conn.IConn) {
+	p.fPullMutex.Lock()
+	defer p.fPullMutex.Unlock()
+
+	for key, pull := range p.fPulls {
+		if pull.fConn == pConn {
+			p.deletePull(key)
+		}
+	}
+}
+
+func (p *sNode) expirePulls(pNow time.Time) {
+	for key, pull := range p.fPulls {
+		if !pNow.Before(pull.fDeadline) {
+			p.deletePull(key)
+		}
+	}
+}
+
+func (p *sNode) deletePull(pKey string) {
+	pull, ok := p.fPulls[pKey]
+	if !ok {
+		return
+	}
+	delete(p.fPulls, pKey)
+	if p.fPullCounts[pull.fConn]--; p.fPullCounts[pull.fConn] == 0 {
+		delete(p.fPullCounts, pull.fConn)
+	}
+}
+
+func joinControl(pType byte, pHash []byte) []byte {
+	return bytes.Join([][]byte{{pType}, pHash}, []byte{})
+}
+
+func (p *sGossipCache) get(pHash []byte) (layer1.IMessage, bool) {
+	p.fMutex.RLock()
+	defer p.fMutex.RUnlock()
+
+	msg, ok := p.fMap[encoding.HexEncode(pHash)]
+	return msg, ok
+}
+
+func (p *sGossipCache) set(pMsg layer1.IMessage) {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	key := encoding.HexEncode(pMsg.GetHash())
+	if _, ok := p.fMap[key]; ok {
+		return
+	}
+
+	delete(p.fMap, p.fQueue[p.fIndex])
+	p.fQueue[p.fIndex] = key
+	p.fIndex = (p.fIndex + 1) % uint64(len(p.fQueue))
+	p.fMap[key] = pMsg
+}
@@ -0,0 +1,63 @@
+package network
+
+import (
+	"context"
+
+	"github.com/number571/go-peer/pkg/message/layer1"
+	"github.com/number571/go-peer/pkg/network/conn"
+	"github.com/number571/go-peer/pkg/network/ratelimit"
+)
+
+func newLimiter(pSett ratelimit.ISettings) ratelimit.ILimiter {
+	if pSett == nil {
+		return nil // unlimited
+	}
+	return ratelimit.NewLimiter(pSett)
+}
+
+// Limit of the connection is checked first, then the limit of the node.
+func (p *sNode) limitRead(pCtx context.Context, pLimiter ratelimit.ILimiter, pMsg layer1.IMessage) error {
+	size := uint64(len(pMsg.ToBytes()))
+	if err := useLimiter(pCtx, pLimiter, size); err != nil {
+		return err
+	}
+	return useLimiter(pCtx, p.fReadLimiter, size)
+}
+
+// Limit of the connection is checked first, then the limit of the node.
+func (p *sNode) limitWrite(pCtx context.Context, pConn conn.IConn, pMsg layer1.IMessage) error {
+	size := uint64(len(pMsg.ToBytes()))
+	if err := useLimiter(pCtx, p.getWriteLimiter(pConn), size); err != nil {
+		return err
+	}
+	if p.fWriteQueue != nil {
+		// messages with more work are written first by the congestion
+		return p.fWriteQueue.wait(pCtx, pMsg.GetWorkSizeBits(), size)
+	}
+	return useLimiter(pCtx, p.fWriteLimiter, size)
+}
+
+func (p *sNode) getWriteLimiter(pConn conn.IConn) ratelimit.ILimiter {
+	p.fMutex.RLock()
+	defer p.fMutex.RUnlock()
+
+	state, ok := p.fConnStates[pConn]
+	if !ok {
+		return nil
+	}
+	return state.fWriteLimiter
+}
+
+// Delays or drops the message over the limit by the settings of limiter.
+func useLimiter(pCtx context.Context, pLimiter ratelimit.ILimiter, pSize uint64) error {
+	if pLimiter == nil {
+		return nil
+	}
+	if pLimiter.GetSettings().GetDelayOnLimit() {
+		return pLimiter.Wait(pCtx, pSize)
+	}
+	if !pLimiter.Allow(pSize) {
+		return ErrRateLimit
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/number571/go-peer/pkg/message/layer1"
 	"github.com/number571/go-peer/pkg/network/conn"
+	"github.com/number571/go-peer/pkg/network/ratelimit"
 	"github.com/number571/go-peer/pkg/storage/cache"
 )
 
@@ -22,7 +23,30 @@ type sNode struct {
 	fListener     net.Listener
 	fCacheSetter  cache.ICacheSetter
 	fConnections  map[string]conn.IConn
+	fConnStates   map[conn.IConn]*sConnState
+	fReadLimiter  ratelimit.ILimiter
+	fWriteLimiter ratelimit.ILimiter
+	fWriteQueue   *sPriorityQueue
+	fGossipCache  *sGossipCache
+	fPullMutex    sync.Mutex
+	fPulls        map[string]sPull
+	fPullCounts   map[conn.IConn]uint64
+	fScores       map[string]*sScore
+	fBans         map[string]time.Time
 	fHandleRoutes map[uint32]IHandlerF
+	fEventRoutes  map[uint64]IEventF
+	fEventCounter uint64
+	fPending      chan struct{}
+}
+
+type sConnState struct {
+	fDuplicates   uint64
+	fWriteLimiter ratelimit.ILimiter
+}
+
+type sReadResult struct {
+	fMsg layer1.IMessage
+	fErr error
 }
 
 // Creating a node object managed by connections with multiple nodes.
@@ -32,12 +56,26 @@ func NewNode(
 	pSettings ISettings,
 	pCacheSetter cache.ICacheSetter,
 ) INode {
-	return &sNode{
+	node := &sNode{
 		fSettings:     pSettings,
 		fCacheSetter:  pCacheSetter,
 		fConnections:  make(map[string]conn.IConn, pSettings.GetMaxConnects()),
+		fConnStates:   make(map[conn.IConn]*sConnState, pSettings.GetMaxConnects()),
+		fReadLimiter:  newLimiter(pSettings.GetReadLimit()),
+		fWriteLimiter: newLimiter(pSettings.GetWriteLimit()),
+		fScores:       make(map[string]*sScore, 64),
+		fBans:         make(map[string]time.Time, 64),
 		fHandleRoutes: make(map[uint32]IHandlerF, 64),
+		fEventRoutes:  make(map[uint64]IEventF, 8),
+		fPending:      make(chan struct{}, pSettings.GetMaxPending()),
+	}
+	node.fWriteQueue = newPriorityQueue(node.fWriteLimiter, pSettings.GetPriorityQueueSize())
+	if pSettings.GetGossipLazyPush() {
+		node.fGossipCache = newGossipCache(pSettings.GetGossipCacheSize())
+		node.fPulls = make(map[string]sPull, pSettings.GetGossipCacheSize())
+		node.fPullCounts = make(map[conn.IConn]uint64, pSettings.GetMaxConnects())
 	}
+	return node
 }
 
 // Return settings interface.
@@ -49,62 +87,82 @@ func (p *sNode) GetCacheSetter() cache.ICacheSetter {
 	return p.fCacheSetter
 }
 
-// Puts the hash of the message in the buffer and sends the message to all connections of the node.
+// Puts the hash of the message in the buffer and sends the message to the connections of the node.
+// By default the message is sent to all connections, with the gossip fan-out only to the random k
+// connections (other connections receive the hash of message if the lazy push is enabled).
 func (p *sNode) BroadcastMessage(pCtx context.Context, pMsg layer1.IMessage) error {
 	connections := p.GetConnections()
-	lenConnections := len(connections)
 
 	// can't broadcast message to the network if len(connections) = 0
-	if lenConnections == 0 {
+	if len(connections) == 0 {
 		return ErrNoConnections
 	}
 
 	// node can redirect received message
 	_ = p.fCacheSetter.Set(pMsg.GetHash(), []byte{})
+	p.storeMessage(pMsg)
 
-	wg := sync.WaitGroup{}
-	wg.Add(lenConnections)
-
-	listErr := make([]error, lenConnections)
-	i := 0
+	targets := p.selectTargets(connections)
+	lenTargets := len(targets)
 
-	for a, c := range connections {
-		chErr := make(chan error)
+	wg := sync.WaitGroup{}
+	wg.Add(lenTargets)
 
-		go func(c conn.IConn) {
-			chErr <- c.WriteMessage(pCtx, pMsg)
-		}(c)
+	listErr := make([]error, lenTargets)
 
-		go func(i int, a string) {
+	for i, t := range targets {
+		go func(i int, a string, c conn.IConn, eager bool) {
 			defer wg.Done()
+			listErr[i] = p.writeMessage(pCtx, a, c, pMsg, eager)
+		}(i, t.fAddress, t.fConn, t.fEager)
+	}
 
-			timer := time.NewTimer(p.fSettings.GetWriteTimeout())
-			defer timer.Stop()
+	wg.Wait()
+	return errors.Join(listErr...)
+}
 
-			select {
-			case <-pCtx.Done():
-				listErr[i] = pCtx.Err()
-			case <-timer.C:
-				listErr[i] = ErrWriteTimeout
-			case err := <-chErr:
-				if err == nil {
-					return
-				}
-				listErr[i] = errors.Join(ErrBroadcastMessage, err)
-			}
+// Writes the message (eager) or the hash of message (lazy) by the limits of node.
+// The connection is deleted by the error of writing, but not by the limit.
+func (p *sNode) writeMessage(pCtx context.Context, pAddress string, pConn conn.IConn, pMsg layer1.IMessage, pEager bool) error {
+	write := func() error { return pConn.WriteMessage(pCtx, pMsg) }
+	if !pEager {
+		write = func() error { return p.announceMessage(pCtx, pConn, pMsg) }
+	} else if err := p.limitWrite(pCtx, pConn, pMsg); err != nil {
+		// limited message does not delete the connection
+		return err
+	}
 
-			// if got error -> delete connection
-			_ = p.DelConnection(a)
-		}(i, a)
+	chErr := make(chan error, 1)
+	go func() { chErr <- write() }()
+
+	timer := time.NewTimer(p.fSettings.GetWriteTimeout())
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-pCtx.Done():
+		err = pCtx.Err()
+	case <-timer.C:
+		err = ErrWriteTimeout
+		p.emitEvent(CEventWriteTimeout, pAddress, ErrWriteTimeout)
+	case err = <-chErr:
+		if err == nil {
+			return nil
+		}
+		err = errors.Join(ErrBroadcastMessage, err)
+	}
 
-		i++
+	// message is too large for the peer, but the connection is valid
+	if errors.Is(err, conn.ErrPeerLimitSize) {
+		return err
 	}
 
-	wg.Wait()
-	return errors.Join(listErr...)
+	// if got error -> delete connection
+	_ = p.delConnection(pAddress, pConn, err)
+	return err
 }
 
-// Opens a tcp connection to receive data from outside.
+// Opens a listener of the transport to receive data from outside.
 // Checks the number of valid connections.
 // Redirects connections to the handle router.
 func (p *sNode) Run(pCtx context.Context) error {
@@ -113,7 +171,8 @@ func (p *sNode) Run(pCtx context.Context) error {
 		return pCtx.Err()
 	}
 
-	listener, err := net.Listen("tcp", p.fSettings.GetAddress())
+	transport := p.fSettings.GetTransport()
+	listener, err := transport.Listen(pCtx, p.fSettings.GetAddress())
 	if err != nil {
 		return errors.Join(ErrCreateListener, err)
 	}
@@ -135,20 +194,60 @@ func (p *sNode) Run(pCtx context.Context) error {
 				return errors.Join(ErrListenerAccept, err)
 			}
 
-			if p.hasMaxConnSize() {
+			remoteAddr := tconn.RemoteAddr().String()
+			if p.hasMaxConnSize(remoteAddr, true) || p.isBanned(remoteAddr) {
 				tconn.Close()
 				continue
 			}
 
-			conn := conn.LoadConn(p.fSettings.GetConnSettings(), tconn)
-			address := tconn.RemoteAddr().String()
+			// strangers can not hold more establishing sockets than the limit
+			select {
+			case p.fPending <- struct{}{}:
+			default:
+				tconn.Close()
+				continue
+			}
 
-			p.setConnection(address, conn)
-			go p.handleConn(pCtx, address, conn)
+			go p.acceptConn(pCtx, tconn)
 		}
 	}
 }
 
+// Establishes the accepted connection. Peers failing the
+// establishment are dropped before they are counted as connections.
+func (p *sNode) acceptConn(pCtx context.Context, pSocket net.Conn) {
+	address := pSocket.RemoteAddr().String()
+
+	conn, err := p.establishConn(pCtx, pSocket)
+	if err != nil {
+		// failed handshakes and proofs of network key are scored
+		if isScoredAddress(address, true) {
+			p.penalizePeer(address, err)
+		}
+		return
+	}
+
+	if err := p.setConnection(address, conn); err != nil {
+		conn.Close()
+		return
+	}
+
+	p.emitEvent(CEventConnectedInbound, address, nil)
+
+	p.handleConn(pCtx, address, conn)
+}
+
+// Establishment holds the slot of pending connections until the end
+// of all the steps, which are limited by the one deadline.
+func (p *sNode) establishConn(pCtx context.Context, pSocket net.Conn) (conn.IConn, error) {
+	defer func() { <-p.fPending }()
+
+	ctx, cancel := context.WithTimeout(pCtx, p.fSettings.GetHandshakeTimeout())
+	defer cancel()
+
+	return conn.AcceptConn(ctx, p.fSettings.GetConnSettings(), pSocket)
+}
+
 // Saves the function to the map by key for subsequent redirection.
 func (p *sNode) HandleFunc(pHead uint32, pHandle IHandlerF) INode {
 	p.fMutex.Lock()
@@ -174,7 +273,7 @@ func (p *sNode) GetConnections() map[string]conn.IConn {
 // Connects to the node at the specified address and automatically starts reading all incoming messages.
 // Checks the number of connections.
 func (p *sNode) AddConnection(pCtx context.Context, pAddress string) error {
-	if p.hasMaxConnSize() {
+	if p.hasMaxConnSize(pAddress, false) {
 		return ErrHasLimitConnections
 	}
 
@@ -182,13 +281,23 @@ func (p *sNode) AddConnection(pCtx context.Context, pAddress string) error {
 		return ErrConnectionIsExist
 	}
 
+	if p.isBanned(pAddress) {
+		return ErrPeerIsBanned
+	}
+
 	sett := p.fSettings.GetConnSettings()
-	conn, err := conn.Connect(pCtx, sett, pAddress)
+	conn, err := conn.ConnectWith(pCtx, sett, p.fSettings.GetTransport(), pAddress)
 	if err != nil {
 		return errors.Join(ErrAddConnections, err)
 	}
 
-	p.setConnection(pAddress, conn)
+	if err := p.setConnection(pAddress, conn); err != nil {
+		conn.Close()
+		return err
+	}
+
+	p.emitEvent(CEventConnectedOutbound, pAddress, nil)
+
 	go p.handleConn(pCtx, pAddress, conn)
 
 	return nil
@@ -196,15 +305,23 @@ func (p *sNode) AddConnection(pCtx context.Context, pAddress string) error {
 
 // Disables the connection at the address and removes the connection from the connection list.
 func (p *sNode) DelConnection(pAddress string) error {
-	p.fMutex.Lock()
-	defer p.fMutex.Unlock()
+	return p.delConnection(pAddress, nil, nil)
+}
 
+// Deletes the connection (if pConn is not nil, then only the same connection)
+// and sends the disconnected event with the reason.
+func (p *sNode) delConnection(pAddress string, pConn conn.IConn, pReason error) error {
+	p.fMutex.Lock()
 	conn, ok := p.fConnections[pAddress]
-	if !ok {
+	if !ok || (pConn != nil && conn != pConn) {
+		p.fMutex.Unlock()
 		return ErrConnectionIsNotExist
 	}
-
 	delete(p.fConnections, pAddress)
+	delete(p.fConnStates, conn)
+	p.fMutex.Unlock()
+
+	p.emitEvent(CEventDisconnected, pAddress, pReason)
 
 	if err := conn.Close(); err != nil {
 		return errors.Join(ErrCloseConnection, err)
@@ -215,13 +332,24 @@ func (p *sNode) DelConnection(pAddress string) error {
 
 // Processes the received data from the connection.
 func (p *sNode) handleConn(pCtx context.Context, pAddress string, pConn conn.IConn) {
-	defer func() { _ = p.DelConnection(pAddress) }()
+	var reason error
+	defer func() {
+		_ = p.delConnection(pAddress, pConn, reason)
+		if isScoredAddress(pAddress, pConn.IsInbound()) {
+			p.penalizePeer(pAddress, reason)
+		}
+	}()
 
 	var (
-		readHeadCh = make(chan struct{})
-		readFullCh = make(chan layer1.IMessage)
+		readHeadCh  = make(chan struct{})
+		readFullCh  = make(chan sReadResult)
+		readLimiter = newLimiter(p.fSettings.GetConnReadLimit())
 	)
 
+	// control frames are processed in parallel with the reading
+	stopControl := p.runControlWorker(pCtx, pAddress, pConn)
+	defer stopControl()
+
 	go p.messageReader(
 		pCtx,
 		pConn,
@@ -232,31 +360,52 @@ func (p *sNode) handleConn(pCtx context.Context, pAddress string, pConn conn.ICo
 	for {
 		select {
 		case <-pCtx.Done():
+			reason = pCtx.Err()
 			return
 		case <-readHeadCh:
-			select {
-			case <-pCtx.Done():
+			result, err := p.waitReadResult(pCtx, readFullCh)
+			if err != nil {
+				reason = err
 				return
-			case <-time.After(p.fSettings.GetReadTimeout()):
+			}
+			if result.fErr != nil {
+				reason = errors.Join(ErrReadMessage, result.fErr)
 				return
-			case msg := <-readFullCh:
-				if msg == nil {
-					return
-				}
-				if ok := p.handleMessage(pCtx, pConn, msg); !ok {
-					return
+			}
+			if err := p.limitRead(pCtx, readLimiter, result.fMsg); err != nil {
+				if errors.Is(err, ErrRateLimit) {
+					break // message is dropped
 				}
-				break
+				reason = err
+				return
+			}
+			if err := p.handleMessage(pCtx, pConn, result.fMsg); err != nil {
+				reason = err
+				p.emitEvent(CEventProtocolError, pAddress, err)
+				return
 			}
 		}
 	}
 }
 
+// Waits the result of reading after the header of message. Control frames
+// (pings, gossip) are not signaled, so they do not start the read timeout.
+func (p *sNode) waitReadResult(pCtx context.Context, readFullCh <-chan sReadResult) (sReadResult, error) {
+	select {
+	case <-pCtx.Done():
+		return sReadResult{}, pCtx.Err()
+	case <-time.After(p.fSettings.GetReadTimeout()):
+		return sReadResult{}, ErrReadTimeout
+	case result := <-readFullCh:
+		return result, nil
+	}
+}
+
 func (p *sNode) messageReader(
 	pCtx context.Context,
 	pConn conn.IConn,
 	readHeadCh chan<- struct{},
-	readFullCh chan<- layer1.IMessage,
+	readFullCh chan<- sReadResult,
 ) {
 	for {
 		select {
@@ -264,39 +413,80 @@ func (p *sNode) messageReader(
 			return
 		default:
 			msg, err := pConn.ReadMessage(pCtx, readHeadCh)
+			readFullCh <- sReadResult{fMsg: msg, fErr: err}
 			if err != nil {
-				readFullCh <- nil
 				return
 			}
-			readFullCh <- msg
 		}
 	}
 }
 
 // Processes the message for correctness and redirects it to the handler function.
-// Returns true if the message was successfully redirected to the handler function
+// Returns nil if the message was successfully redirected to the handler function
 // > or if the message already existed in the hash value store.
-func (p *sNode) handleMessage(pCtx context.Context, pConn conn.IConn, pMsg layer1.IMessage) bool {
+func (p *sNode) handleMessage(pCtx context.Context, pConn conn.IConn, pMsg layer1.IMessage) error {
 	if !p.fCacheSetter.Set(pMsg.GetHash(), []byte{}) {
-		return true // hash of message already in queue
+		p.addDuplicate(pConn)
+		return nil // hash of message already in queue
 	}
+	p.storeMessage(pMsg)
 
 	f, ok := p.getFunction(pMsg.GetPayload().GetHead())
 	if !ok || f == nil {
-		return false // function is not found = protocol error
+		return ErrUnknownRoute // function is not found = protocol error
 	}
 
-	err := f(pCtx, p, pConn, pMsg)
-	return err == nil // function error = protocol error
+	if err := f(pCtx, p, pConn, pMsg); err != nil {
+		return errors.Join(ErrHandleMessage, err) // function error = protocol error
+	}
+	return nil
 }
 
-// Checks the current number of connections with the limit.
-func (p *sNode) hasMaxConnSize() bool {
+// Checks the current number of connections with the limits.
+func (p *sNode) hasMaxConnSize(pAddress string, pInbound bool) bool {
 	p.fMutex.RLock()
 	defer p.fMutex.RUnlock()
 
-	maxConns := p.fSettings.GetMaxConnects()
-	return uint64(len(p.fConnections)) >= maxConns
+	return p.isLimited(pAddress, pInbound)
+}
+
+// Addresses from the allow list bypass the limits. Limit of the
+// direction (inbound/outbound) is checked with the total limit.
+func (p *sNode) isLimited(pAddress string, pInbound bool) bool {
+	if p.isAllowed(pAddress) {
+		return false
+	}
+
+	if uint64(len(p.fConnections)) >= p.fSettings.GetMaxConnects() {
+		return true
+	}
+
+	maxDirConns := p.fSettings.GetMaxOutbound()
+	if pInbound {
+		maxDirConns = p.fSettings.GetMaxInbound()
+	}
+	if maxDirConns == 0 {
+		return false
+	}
+
+	dirConns := uint64(0)
+	for _, conn := range p.fConnections {
+		if conn.IsInbound() == pInbound {
+			dirConns++
+		}
+	}
+	return dirConns >= maxDirConns
+}
+
+// Checks the address or the host of address in the allow list.
+func (p *sNode) isAllowed(pAddress string) bool {
+	host := getHost(pAddress)
+	for _, addr := range p.fSettings.GetAllowList() {
+		if addr == pAddress || addr == host {
+			return true
+		}
+	}
+	return false
 }
 
 // Saves the connection to the map.
@@ -308,12 +498,24 @@ func (p *sNode) getConnection(pAddress string) (conn.IConn, bool) {
 	return conn, ok
 }
 
-// Saves the connection to the map.
-func (p *sNode) setConnection(pAddress string, pConn conn.IConn) {
+// Saves the connection to the map if the limits are not reached.
+func (p *sNode) setConnection(pAddress string, pConn conn.IConn) error {
 	p.fMutex.Lock()
 	defer p.fMutex.Unlock()
 
+	if p.isLimited(pAddress, pConn.IsInbound()) {
+		return ErrHasLimitConnections
+	}
+
+	if _, ok := p.fConnections[pAddress]; ok {
+		return ErrConnectionIsExist
+	}
+
 	p.fConnections[pAddress] = pConn
+	p.fConnStates[pConn] = &sConnState{
+		fWriteLimiter: newLimiter(p.fSettings.GetConnWriteLimit()),
+	}
+	return nil
 }
 
 // Gets the handler function by key.
@@ -2,16 +2,23 @@
 package network
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/number571/go-peer/pkg/crypto/asymmetric"
+	"github.com/number571/go-peer/pkg/crypto/hashing"
 	"github.com/number571/go-peer/pkg/message/layer1"
 	"github.com/number571/go-peer/pkg/network/conn"
+	"github.com/number571/go-peer/pkg/network/ratelimit"
+	"github.com/number571/go-peer/pkg/network/transport"
 	"github.com/number571/go-peer/pkg/payload"
 	"github.com/number571/go-peer/pkg/storage/cache"
 	testutils "github.com/number571/go-peer/test/utils"
@@ -37,7 +44,7 @@ func TestError(t *testing.T) {
 func TestSettings(t *testing.T) {
 	t.Parallel()
 
-	for i := 0; i < 4; i++ {
+	for i := 0; i < 7; i++ {
 		testSettings(t, i)
 	}
 }
@@ -99,6 +106,57 @@ func testSettings(t *testing.T, n int) {
 			FReadTimeout:  tcTimeWait,
 			FWriteTimeout: tcTimeWait,
 		})
+	case 4:
+		_ = NewSettings(&SSettings{
+			FAddress:      "test",
+			FMaxConnects:  16,
+			FReadTimeout:  tcTimeWait,
+			FWriteTimeout: tcTimeWait,
+			FBanThreshold: 10,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings:       layer1.NewSettings(&layer1.SSettings{}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           time.Minute,
+				FWriteTimeout:          time.Minute,
+			}),
+		})
+	case 5:
+		_ = NewSettings(&SSettings{
+			FAddress:        "test",
+			FMaxConnects:    16,
+			FReadTimeout:    tcTimeWait,
+			FWriteTimeout:   tcTimeWait,
+			FGossipLazyPush: true,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings:       layer1.NewSettings(&layer1.SSettings{}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           time.Minute,
+				FWriteTimeout:          time.Minute,
+			}),
+		})
+	case 6:
+		_ = NewSettings(&SSettings{
+			FAddress:           "test",
+			FMaxConnects:       16,
+			FReadTimeout:       tcTimeWait,
+			FWriteTimeout:      tcTimeWait,
+			FPriorityQueueSize: 16,
+			FWriteLimit: ratelimit.NewSettings(&ratelimit.SSettings{
+				FMessagesPerSec: 1,
+			}),
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings:       layer1.NewSettings(&layer1.SSettings{}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           time.Minute,
+				FWriteTimeout:          time.Minute,
+			}),
+		})
 	}
 }
 
@@ -292,7 +350,7 @@ func TestHandleMessage(t *testing.T) {
 
 	node.HandleFunc(1, nil)
 	msg1 := layer1.NewMessage(sett, payload.NewPayload32(1, []byte{1}))
-	if ok := node.handleMessage(ctx, nil, msg1); ok {
+	if err := node.handleMessage(ctx, nil, msg1); err == nil {
 		t.Error("success handle message with nil function")
 		return
 	}
@@ -301,7 +359,7 @@ func TestHandleMessage(t *testing.T) {
 		return errors.New("some error")
 	})
 	msg2 := layer1.NewMessage(sett, payload.NewPayload32(1, []byte{2}))
-	if ok := node.handleMessage(ctx, nil, msg2); ok {
+	if err := node.handleMessage(ctx, nil, msg2); err == nil {
 		t.Error("success handle message with got error from function")
 		return
 	}
@@ -310,7 +368,7 @@ func TestHandleMessage(t *testing.T) {
 		return nil
 	})
 	msg3 := layer1.NewMessage(sett, payload.NewPayload32(1, []byte{3}))
-	if ok := node.handleMessage(ctx, nil, msg3); !ok {
+	if err := node.handleMessage(ctx, nil, msg3); err != nil {
 		t.Error("failed handle message with correct function")
 		return
 	}
@@ -323,94 +381,1540 @@ func TestNodeSettings(t *testing.T) {
 	if gotSett.GetMaxConnects() != 16 {
 		t.Error("invalid setting's value")
 	}
+	if gotSett.GetTransport() == nil {
+		t.Error("default transport is nil")
+	}
+	if gotSett.GetMaxPending() != 16 {
+		t.Error("default max pending != max connects")
+	}
+	if gotSett.GetHandshakeTimeout() != gotSett.GetReadTimeout() {
+		t.Error("default handshake timeout != read timeout")
+	}
+	if gotSett.GetGossipControlLimit().GetMessagesPerSec() != cDefaultControlPerSec {
+		t.Error("invalid default limit of control frames")
+	}
 }
 
-func TestContextCancel(t *testing.T) {
+func TestPipeTransport(t *testing.T) {
 	t.Parallel()
 
-	node1 := newTestNode(testutils.TgAddrs[6], 16)
-	node2 := newTestNode("", 16)
-
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go func() { _ = node1.Run(ctx) }()
+	pipeTransport := transport.NewPipeTransport()
+
+	node1 := newTestNodeWithTransport("service", 16, pipeTransport)
+	node2 := newTestNodeWithTransport("", 16, pipeTransport)
+
+	go func() {
+		if err := node1.Run(ctx); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, net.ErrClosed) {
+			t.Error(err)
+			return
+		}
+	}()
+
+	headHandle := uint32(123)
+	chMsg := make(chan []byte, 1)
+	node1.HandleFunc(headHandle, func(_ context.Context, _ INode, _ conn.IConn, pMsg layer1.IMessage) error {
+		chMsg <- pMsg.GetPayload().GetBody()
+		return nil
+	})
 
 	err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
-		return node2.AddConnection(ctx, testutils.TgAddrs[6])
+		return node2.AddConnection(ctx, "service")
 	})
 	if err1 != nil {
 		t.Error(err1)
 		return
 	}
 
-	headHandle := uint32(123)
+	if err := node2.AddConnection(ctx, "unknown"); err == nil {
+		t.Error("success add connection to unknown pipe address")
+		return
+	}
+
 	sett := layer1.NewConstructSettings(&layer1.SConstructSettings{
 		FSettings: node2.GetSettings().GetConnSettings().GetMessageSettings(),
 	})
+	body := fmt.Sprintf(tcBodyTemplate, 0)
+	msg := layer1.NewMessage(sett, payload.NewPayload32(headHandle, []byte(body)))
+	if err := node2.BroadcastMessage(ctx, msg); err != nil {
+		t.Error(err)
+		return
+	}
+
+	select {
+	case got := <-chMsg:
+		if string(got) != body {
+			t.Error("got invalid message body")
+			return
+		}
+	case <-time.After(tcTimeWait):
+		t.Error("limit of waiting time for message")
+		return
+	}
+}
+
+func TestUnixTransport(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("network_test_%d.sock", time.Now().UnixNano()))
+	defer os.Remove(path)
+
+	unixTransport := transport.NewUnixTransport()
+	node1 := newTestNodeWithTransport(path, 16, unixTransport)
 
 	go func() {
-		for i := 0; i < 1000; i++ {
-			pld := payload.NewPayload32(
-				headHandle,
-				[]byte(fmt.Sprintf(tcBodyTemplate, i)),
-			)
-			if err := node2.BroadcastMessage(ctx, layer1.NewMessage(sett, pld)); err != nil {
-				return
-			}
+		if err := node1.Run(ctx); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, net.ErrClosed) {
+			t.Error(err)
+			return
 		}
-		t.Error("success all broadcast messages with canceled context")
 	}()
 
-	cancel()
+	// inbound Unix-domain connections are not rejected as the same address
+	for i := 0; i < 2; i++ {
+		client := newTestNodeWithTransport("", 16, unixTransport)
+		err := testutils.TryN(50, 10*time.Millisecond, func() error {
+			return client.AddConnection(ctx, path)
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	err := testutils.TryN(50, 10*time.Millisecond, func() error {
+		if len(node1.GetConnections()) != 2 {
+			return errors.New("inbound connections are not added")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
 }
 
-func testNodes(ctx context.Context) ([5]INode, map[INode]map[string]bool, error) {
-	nodes := [5]INode{}
-	addrs := [5]string{"", "", testutils.TgAddrs[0], "", testutils.TgAddrs[1]}
+func TestNodeEvents(t *testing.T) {
+	t.Parallel()
 
-	for i := 0; i < 5; i++ {
-		nodes[i] = newTestNode(addrs[i], 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pipeTransport := transport.NewPipeTransport()
+
+	node1 := newTestNodeWithTransport("service", 16, pipeTransport)
+	node2 := newTestNodeWithTransport("", 16, pipeTransport)
+
+	chEvents1 := make(chan IEvent, 16)
+	node1.SubscribeEvents(func(pEvent IEvent) { chEvents1 <- pEvent })
+
+	chEvents2 := make(chan IEvent, 16)
+	unsubscribe := node2.SubscribeEvents(func(pEvent IEvent) { chEvents2 <- pEvent })
+
+	go func() { _ = node1.Run(ctx) }()
+
+	err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		return node2.AddConnection(ctx, "service")
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
 	}
 
-	go func() { _ = nodes[2].Run(ctx) }()
-	go func() { _ = nodes[4].Run(ctx) }()
+	if err := testEvent(chEvents2, CEventConnectedOutbound, false); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := testEvent(chEvents1, CEventConnectedInbound, false); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// node1 has not the route of handler = protocol error
+	sett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: node2.GetSettings().GetConnSettings().GetMessageSettings(),
+	})
+	msg := layer1.NewMessage(sett, payload.NewPayload32(123, []byte("hello")))
+	if err := node2.BroadcastMessage(ctx, msg); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := testEvent(chEvents1, CEventProtocolError, true); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := testEvent(chEvents1, CEventDisconnected, true); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := testEvent(chEvents2, CEventDisconnected, true); err != nil {
+		t.Error(err)
+		return
+	}
+
+	unsubscribe()
+
+	if err := node2.AddConnection(ctx, "service"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := node2.DelConnection("service"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	select {
+	case event := <-chEvents2:
+		t.Errorf("got event (%d) after unsubscribe", event.GetType())
+		return
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNodeStats(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pipeTransport := transport.NewPipeTransport()
+
+	node1 := newTestNodeWithTransport("service", 16, pipeTransport)
+	node2 := newTestNodeWithTransport("", 16, pipeTransport)
+
+	headHandle := uint32(123)
+	node1.HandleFunc(headHandle, func(_ context.Context, _ INode, _ conn.IConn, _ layer1.IMessage) error {
+		return nil
+	})
+
+	go func() { _ = node1.Run(ctx) }()
 
 	err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
-		return nodes[0].AddConnection(ctx, testutils.TgAddrs[0])
+		return node2.AddConnection(ctx, "service")
 	})
 	if err1 != nil {
-		return [5]INode{}, nil, err1
+		t.Error(err1)
+		return
+	}
+
+	sett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: node2.GetSettings().GetConnSettings().GetMessageSettings(),
+	})
+	msg := layer1.NewMessage(sett, payload.NewPayload32(headHandle, []byte("hello")))
+
+	// the same message is sent twice = one duplicate
+	conn2 := node2.GetConnections()["service"]
+	for i := 0; i < 2; i++ {
+		if err := conn2.WriteMessage(ctx, msg); err != nil {
+			t.Error(err)
+			return
+		}
 	}
+
 	err2 := testutils.TryN(50, 10*time.Millisecond, func() error {
-		return nodes[1].AddConnection(ctx, testutils.TgAddrs[1])
+		stats := node1.GetStats()
+		if len(stats) != 1 {
+			return errors.New("got invalid length of stats")
+		}
+		for _, s := range stats {
+			if s.GetMessagesIn() != 2 || s.GetDuplicates() != 1 {
+				return errors.New("got invalid stats of connection")
+			}
+		}
+		return nil
 	})
 	if err2 != nil {
-		return [5]INode{}, nil, err2
+		t.Error(err2)
+		return
 	}
 
-	_ = nodes[3].AddConnection(ctx, testutils.TgAddrs[0])
-	_ = nodes[3].AddConnection(ctx, testutils.TgAddrs[1])
+	stats := node2.GetStats()["service"]
+	if stats.GetMessagesOut() != 2 || stats.GetDuplicates() != 0 || stats.GetBytesOut() == 0 {
+		t.Error("got invalid stats of sender")
+		return
+	}
+}
 
-	mapp := make(map[INode]map[string]bool)
-	for _, node := range nodes {
-		// pass sender
-		if node == nodes[0] {
-			continue
-		}
-		mapp[node] = make(map[string]bool)
-		for i := 0; i < tcIter; i++ {
-			mapp[node][fmt.Sprintf(tcBodyTemplate, i)] = false
-		}
+func TestKeepAlive(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pipeTransport := transport.NewPipeTransport()
+
+	// interval of pings is greater than the read timeout of node
+	node1 := newTestNodeWithKeepAlive("service", pipeTransport, 100*time.Millisecond, 300*time.Millisecond)
+	node2 := newTestNodeWithKeepAlive("", pipeTransport, 100*time.Millisecond, 300*time.Millisecond)
+
+	chEvents := make(chan IEvent, 16)
+	node1.SubscribeEvents(func(pEvent IEvent) { chEvents <- pEvent })
+
+	go func() { _ = node1.Run(ctx) }()
+
+	err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		return node2.AddConnection(ctx, "service")
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+	if err := testEvent(chEvents, CEventConnectedInbound, false); err != nil {
+		t.Error(err)
+		return
 	}
 
-	return nodes, mapp, nil
+	// pings do not start the reading of message
+	select {
+	case event := <-chEvents:
+		t.Errorf("idle connection is closed by the pings (%v)", event.GetError())
+		return
+	case <-time.After(time.Second):
+	}
+
+	if len(node1.GetConnections()) != 1 || len(node2.GetConnections()) != 1 {
+		t.Error("idle connection is not kept alive")
+		return
+	}
 }
 
-func newTestNode(pAddr string, pMaxConns uint64) INode {
+func TestBanList(t *testing.T) {
+	t.Parallel()
+
+	node := newTestNodeWithBan("", 10).(*sNode)
+
+	// handler errors are not the protocol violations
+	node.penalizePeer("127.0.0.1:1111", ErrHandleMessage)
+	node.penalizePeer("127.0.0.1:2222", layer1.ErrInvalidProofOfWork)
+	if len(node.GetBanList()) != 1 {
+		t.Error("got invalid length of ban list")
+		return
+	}
+
+	ctx := context.Background()
+	if err := node.AddConnection(ctx, "127.0.0.1:3333"); !errors.Is(err, ErrPeerIsBanned) {
+		t.Error("success add connection to banned host")
+		return
+	}
+
+	node.penalizePeer("127.0.0.2:1111", ErrUnknownRoute)
+	if _, ok := node.GetBanList()["127.0.0.2"]; ok {
+		t.Error("host is banned before the threshold")
+		return
+	}
+	node.penalizePeer("127.0.0.2:2222", ErrUnknownRoute)
+	until, ok := node.GetBanList()["127.0.0.2"]
+	if !ok || until.Before(time.Now()) {
+		t.Error("host is not banned after the threshold")
+		return
+	}
+
+	if err := node.DelBan("127.0.0.1"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := node.DelBan("127.0.0.1:3333"); !errors.Is(err, ErrBanIsNotExist) {
+		t.Error("success delete not exist ban")
+		return
+	}
+	if node.isBanned("127.0.0.1:3333") || !node.isBanned("127.0.0.2:3333") {
+		t.Error("got invalid ban list after unban")
+		return
+	}
+
+	// ban is disabled
+	nodeWithoutBan := newTestNode("", 16).(*sNode)
+	for i := 0; i < 10; i++ {
+		nodeWithoutBan.penalizePeer("127.0.0.1:1111", ErrUnknownRoute)
+	}
+	if len(nodeWithoutBan.GetBanList()) != 0 {
+		t.Error("host is banned with disabled ban")
+		return
+	}
+}
+
+func TestBanInbound(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	node1 := newTestNodeWithBan(testutils.TgAddrs[19], 10)
+	node2 := newTestNode("", 16)
+
+	chBanned := make(chan IEvent, 1)
+	node1.SubscribeEvents(func(pEvent IEvent) {
+		if pEvent.GetType() == CEventPeerBanned {
+			chBanned <- pEvent
+		}
+	})
+
+	go func() { _ = node1.Run(ctx) }()
+
+	sett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: node2.GetSettings().GetConnSettings().GetMessageSettings(),
+	})
+
+	// two messages with the unknown route = ban
+	for i := 0; i < 2; i++ {
+		err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+			return node2.AddConnection(ctx, testutils.TgAddrs[19])
+		})
+		if err1 != nil {
+			t.Error(err1)
+			return
+		}
+		msg := layer1.NewMessage(sett, payload.NewPayload32(123, []byte{byte(i)}))
+		if err := node2.BroadcastMessage(ctx, msg); err != nil {
+			t.Error(err)
+			return
+		}
+		err2 := testutils.TryN(50, 10*time.Millisecond, func() error {
+			if len(node2.GetConnections()) != 0 {
+				return errors.New("connection is not closed")
+			}
+			return nil
+		})
+		if err2 != nil {
+			t.Error(err2)
+			return
+		}
+	}
+
+	select {
+	case event := <-chBanned:
+		if !errors.Is(event.GetError(), ErrUnknownRoute) {
+			t.Error("got invalid reason of ban")
+			return
+		}
+	case <-time.After(tcTimeWait):
+		t.Error("peer is not banned")
+		return
+	}
+
+	// the socket of banned peer is closed without the establishment
+	_ = node2.AddConnection(ctx, testutils.TgAddrs[19])
+	time.Sleep(100 * time.Millisecond)
+	if len(node1.GetConnections()) != 0 {
+		t.Error("banned peer is connected")
+		return
+	}
+}
+
+func TestBanHandshake(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// failed proof of network key bans the host
+	node1 := newTestNodeWithBanProof(10, "network-key-1").(*sNode)
+	node2 := newTestNodeWithProof("", nil, "network-key-2", 0)
+
+	tcpTransport := transport.NewTCPTransport()
+	if err := testAcceptFailed(ctx, node1, node2, tcpTransport, "127.0.0.1:0"); err != nil {
+		t.Error(err)
+		return
+	}
+	until, ok := node1.GetBanList()["127.0.0.1"]
+	if !ok || until.Before(time.Now()) {
+		t.Error("host is not banned after the failed handshake")
+		return
+	}
+
+	// inbound addresses of pipe are unique, so they are not scored
+	node3 := newTestNodeWithBanProof(10, "network-key-1").(*sNode)
+	if err := testAcceptFailed(ctx, node3, node2, transport.NewPipeTransport(), "service"); err != nil {
+		t.Error(err)
+		return
+	}
+	node3.fMutex.Lock()
+	scores, bans := len(node3.fScores), len(node3.fBans)
+	node3.fMutex.Unlock()
+	if scores != 0 || bans != 0 {
+		t.Error("inbound address of pipe is scored")
+		return
+	}
+}
+
+func testAcceptFailed(pCtx context.Context, pServer *sNode, pClient INode, pTransport transport.ITransport, pAddr string) error {
+	listener, err := pTransport.Listen(pCtx, pAddr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	go func() {
+		socket, err := pTransport.Dial(pCtx, listener.Addr().String())
+		if err != nil {
+			return
+		}
+		_, _ = conn.InitConn(pCtx, pClient.GetSettings().GetConnSettings(), socket)
+	}()
+
+	socket, err := listener.Accept()
+	if err != nil {
+		return err
+	}
+
+	pServer.fPending <- struct{}{}
+	pServer.acceptConn(pCtx, socket)
+
+	if len(pServer.GetConnections()) != 0 {
+		return errors.New("success accept with invalid network key")
+	}
+	return nil
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pipeTransport := transport.NewPipeTransport()
+
+	// reader drops the messages over the limit of connection
+	node1 := newTestNodeWithLimits("service", pipeTransport, ratelimit.NewSettings(&ratelimit.SSettings{
+		FMessagesPerSec: 1,
+	}), nil)
+	// writer delays the messages over the limit of node
+	node2 := newTestNodeWithLimits("", pipeTransport, nil, ratelimit.NewSettings(&ratelimit.SSettings{
+		FMessagesPerSec: 4,
+		FDelayOnLimit:   true,
+	}))
+	// writer drops the messages over the limit of node
+	node3 := newTestNodeWithLimits("", pipeTransport, nil, ratelimit.NewSettings(&ratelimit.SSettings{
+		FMessagesPerSec: 1,
+	}))
+
+	headHandle := uint32(123)
+	chMsg := make(chan struct{}, 16)
+	node1.HandleFunc(headHandle, func(_ context.Context, _ INode, _ conn.IConn, _ layer1.IMessage) error {
+		chMsg <- struct{}{}
+		return nil
+	})
+
+	go func() { _ = node1.Run(ctx) }()
+
+	for _, node := range []INode{node2, node3} {
+		err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+			return node.AddConnection(ctx, "service")
+		})
+		if err1 != nil {
+			t.Error(err1)
+			return
+		}
+	}
+
+	sett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: node2.GetSettings().GetConnSettings().GetMessageSettings(),
+	})
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		msg := layer1.NewMessage(sett, payload.NewPayload32(headHandle, []byte{byte(i)}))
+		if err := node2.BroadcastMessage(ctx, msg); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if time.Since(start) < 200*time.Millisecond {
+		t.Error("messages over the write limit are not delayed")
+		return
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if len(chMsg) != 1 {
+		t.Errorf("messages over the read limit are not dropped (%d)", len(chMsg))
+		return
+	}
+	if len(node1.GetConnections()) != 2 {
+		t.Error("connection is closed by the rate limit")
+		return
+	}
+
+	msg1 := layer1.NewMessage(sett, payload.NewPayload32(headHandle, []byte{10}))
+	if err := node3.BroadcastMessage(ctx, msg1); err != nil {
+		t.Error(err)
+		return
+	}
+	msg2 := layer1.NewMessage(sett, payload.NewPayload32(headHandle, []byte{11}))
+	if err := node3.BroadcastMessage(ctx, msg2); !errors.Is(err, ErrRateLimit) {
+		t.Error("message over the write limit is not dropped")
+		return
+	}
+	if len(node3.GetConnections()) != 1 {
+		t.Error("connection is deleted by the rate limit")
+		return
+	}
+}
+
+func TestPriorityQueue(t *testing.T) {
+	t.Parallel()
+
+	limiter := ratelimit.NewLimiter(ratelimit.NewSettings(&ratelimit.SSettings{
+		FMessagesPerSec: 10,
+		FDelayOnLimit:   true,
+	}))
+	queue := newPriorityQueue(limiter, 2)
+
+	ctx := context.Background()
+
+	// tokens of the burst are taken without the queue
+	for limiter.Allow(1) {
+	}
+
+	mutex := sync.Mutex{}
+	order := make([]uint64, 0, 3)
+
+	wg := sync.WaitGroup{}
+	chErr := make(chan error, 4)
+
+	// first message is in the flight, others are in the queue
+	for i, work := range []uint64{1, 2, 2, 5} {
+		wg.Add(1)
+		go func(seq, work uint64) {
+			defer wg.Done()
+			if err := queue.wait(ctx, work, 1); err != nil {
+				chErr <- err
+				return
+			}
+			mutex.Lock()
+			order = append(order, seq)
+			mutex.Unlock()
+		}(uint64(i), work)
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// queue is full and the work is not more than the least
+	if err := queue.wait(ctx, 1, 1); !errors.Is(err, ErrRateLimit) {
+		t.Error("message with the least work is not dropped")
+		return
+	}
+
+	wg.Wait()
+	close(chErr)
+
+	// latest message with the least work is dropped by the queue
+	if err := <-chErr; !errors.Is(err, ErrRateLimit) {
+		t.Error("message with the least work is not dropped by the full queue")
+		return
+	}
+	if len(order) != 3 || order[0] != 0 || order[1] != 3 || order[2] != 1 {
+		t.Errorf("messages are not ordered by the work (%v)", order)
+		return
+	}
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	for limiter.Allow(1) {
+	}
+	if err := queue.wait(cancelCtx, 1, 1); !errors.Is(err, context.Canceled) {
+		t.Error("success wait with canceled context")
+		return
+	}
+
+	if newPriorityQueue(nil, 2) != nil || newPriorityQueue(limiter, 0) != nil {
+		t.Error("priority queue is created without the limiter or the size")
+		return
+	}
+}
+
+func TestDirectionLimits(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pipeTransport := transport.NewPipeTransport()
+
+	services := make([]INode, 3)
+	for i := range services {
+		services[i] = newTestNodeWithDirections(fmt.Sprintf("service-%d", i), pipeTransport, nil)
+		go func(node INode) { _ = node.Run(ctx) }(services[i])
+	}
+
+	node := newTestNodeWithDirections("", pipeTransport, []string{"service-2"})
+
+	err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		return node.AddConnection(ctx, "service-0")
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+
+	// limit of outbound connections = 1
+	if err := node.AddConnection(ctx, "service-1"); !errors.Is(err, ErrHasLimitConnections) {
+		t.Error("success add connection over the outbound limit")
+		return
+	}
+
+	// address from the allow list bypasses the limit
+	err2 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		return node.AddConnection(ctx, "service-2")
+	})
+	if err2 != nil {
+		t.Error(err2)
+		return
+	}
+
+	for _, c := range node.GetConnections() {
+		if c.IsInbound() {
+			t.Error("got inbound connection of dialer")
+			return
+		}
+	}
+
+	// limit of inbound connections = 1
+	other := newTestNodeWithDirections("", pipeTransport, nil)
+	if err := other.AddConnection(ctx, "service-0"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	conns := services[0].GetConnections()
+	if len(conns) != 1 {
+		t.Errorf("got invalid count of inbound connections (%d)", len(conns))
+		return
+	}
+	for _, c := range conns {
+		if !c.IsInbound() {
+			t.Error("got outbound connection of listener")
+			return
+		}
+	}
+}
+
+func TestGossipFanout(t *testing.T) {
+	t.Parallel()
+
+	testGossip(t, false)
+	testGossip(t, true)
+}
+
+func testGossip(t *testing.T, pLazyPush bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pipeTransport := transport.NewPipeTransport()
+
+	hub := newTestNodeWithGossip("hub", pipeTransport, 1, pLazyPush)
+	go func() { _ = hub.Run(ctx) }()
+
+	headHandle := uint32(123)
+	chMsg := make(chan string, 16)
+
+	leaves := make([]INode, 4)
+	for i := range leaves {
+		leaves[i] = newTestNodeWithGossip("", pipeTransport, 1, pLazyPush)
+		leaves[i].HandleFunc(headHandle, func(_ context.Context, _ INode, _ conn.IConn, pMsg layer1.IMessage) error {
+			chMsg <- string(pMsg.GetPayload().GetBody())
+			return nil
+		})
+		err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+			return leaves[i].AddConnection(ctx, "hub")
+		})
+		if err1 != nil {
+			t.Error(err1)
+			return
+		}
+	}
+
+	err2 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		if len(hub.GetConnections()) != len(leaves) {
+			return errors.New("leaves are not connected")
+		}
+		return nil
+	})
+	if err2 != nil {
+		t.Error(err2)
+		return
+	}
+
+	sett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: hub.GetSettings().GetConnSettings().GetMessageSettings(),
+	})
+	msg := layer1.NewMessage(sett, payload.NewPayload32(headHandle, []byte("hello")))
+	if err := hub.BroadcastMessage(ctx, msg); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// eager push = 1 leaf, lazy push = all leaves (others pull the message)
+	mustCount := 1
+	if pLazyPush {
+		mustCount = len(leaves)
+	}
+
+	for i := 0; i < mustCount; i++ {
+		select {
+		case body := <-chMsg:
+			if body != "hello" {
+				t.Error("got invalid message body")
+				return
+			}
+		case <-time.After(tcTimeWait):
+			t.Errorf("limit of waiting time for message (lazy=%t)", pLazyPush)
+			return
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if len(chMsg) != 0 {
+		t.Errorf("got redundant messages (lazy=%t)", pLazyPush)
+		return
+	}
+}
+
+func testEvent(pChEvents <-chan IEvent, pType IEventType, pWithError bool) error {
+	select {
+	case event := <-pChEvents:
+		if event.GetType() != pType {
+			return fmt.Errorf("got invalid event type (%d != %d)", event.GetType(), pType)
+		}
+		if event.GetAddress() == "" {
+			return errors.New("got empty address of event")
+		}
+		if (event.GetError() != nil) != pWithError {
+			return fmt.Errorf("got invalid error of event (%v)", event.GetError())
+		}
+		if pType == CEventProtocolError && !errors.Is(event.GetError(), ErrUnknownRoute) {
+			return errors.New("got invalid reason of protocol error")
+		}
+		return nil
+	case <-time.After(tcTimeWait):
+		return errors.New("limit of waiting time for event")
+	}
+}
+
+type tsConn struct {
+	conn.IConn
+	fControls uint64
+	fMessages uint64
+	fLastMsg  layer1.IMessage
+}
+
+func (p *tsConn) WriteControl(_ context.Context, _ []byte) error {
+	p.fControls++
+	return nil
+}
+
+func (p *tsConn) WriteMessage(_ context.Context, pMsg layer1.IMessage) error {
+	p.fMessages++
+	p.fLastMsg = pMsg
+	return nil
+}
+
+func TestGossipPulls(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	node := NewNode(
+		NewSettings(&SSettings{
+			FMaxConnects:     16,
+			FReadTimeout:     100 * time.Millisecond,
+			FWriteTimeout:    time.Minute,
+			FGossipFanout:    1,
+			FGossipLazyPush:  true,
+			FGossipCacheSize: 2 * cMaxConnPulls,
+			FWriteLimit: ratelimit.NewSettings(&ratelimit.SSettings{
+				FMessagesPerSec: 1,
+			}),
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+					FWorkSizeBits: 1,
+				}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           time.Minute,
+				FWriteTimeout:          time.Minute,
+			}),
+		}),
+		cache.NewLRUCache(1024),
+	).(*sNode)
+
+	var (
+		conn1 = &tsConn{}
+		conn2 = &tsConn{}
+		conn3 = &tsConn{}
+		hash  = hashing.NewHasher([]byte("hash")).ToBytes()
+	)
+
+	// pull is requested from one peer at a time
+	node.handleControl(ctx, "", conn1, nil, joinControl(cControlAnnounce, hash))
+	node.handleControl(ctx, "", conn2, nil, joinControl(cControlAnnounce, hash))
+	if conn1.fControls != 1 || conn2.fControls != 0 {
+		t.Error("pull is requested from many peers")
+		return
+	}
+
+	// unanswered pull is expired by the read timeout
+	time.Sleep(150 * time.Millisecond)
+	node.handleControl(ctx, "", conn2, nil, joinControl(cControlAnnounce, hash))
+	if conn2.fControls != 1 {
+		t.Error("expired pull is not requested again")
+		return
+	}
+
+	// pulls of the closed connection are released
+	node.releasePulls(conn2)
+	node.handleControl(ctx, "", conn1, nil, joinControl(cControlAnnounce, hash))
+	if conn1.fControls != 2 {
+		t.Error("released pull is not requested again")
+		return
+	}
+
+	// count of the pulls is limited for each connection
+	for i := 0; i < cMaxConnPulls+1; i++ {
+		h := hashing.NewHasher([]byte(fmt.Sprintf("hash-%d", i))).ToBytes()
+		node.handleControl(ctx, "", conn3, nil, joinControl(cControlAnnounce, h))
+	}
+	if conn3.fControls != cMaxConnPulls {
+		t.Error("count of the pulls of connection is not limited")
+		return
+	}
+
+	// pull of the message is answered once for each connection
+	sett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: node.GetSettings().GetConnSettings().GetMessageSettings(),
+	})
+	msg := layer1.NewMessage(sett, payload.NewPayload32(1, []byte{1}))
+	node.storeMessage(msg)
+
+	served := cache.NewLRUCache(16)
+	node.handleControl(ctx, "", conn1, served, joinControl(cControlPull, msg.GetHash()))
+	node.handleControl(ctx, "", conn1, served, joinControl(cControlPull, msg.GetHash()))
+	if conn1.fMessages != 1 {
+		t.Error("pull of the message is answered many times")
+		return
+	}
+	if conn1.fLastMsg != msg {
+		t.Error("pulled message is loaded again")
+		return
+	}
+
+	// pulled messages are written by the limits of node
+	msg2 := layer1.NewMessage(sett, payload.NewPayload32(1, []byte{2}))
+	node.storeMessage(msg2)
+
+	node.handleControl(ctx, "", conn2, cache.NewLRUCache(16), joinControl(cControlPull, msg2.GetHash()))
+	if conn2.fMessages != 0 {
+		t.Error("pull of the message is answered over the write limit")
+		return
+	}
+}
+
+func TestGossipAnnounceIdle(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pipeTransport := transport.NewPipeTransport()
+
+	node1 := NewNode(
+		NewSettings(&SSettings{
+			FTransport:       pipeTransport,
+			FAddress:         "service",
+			FMaxConnects:     16,
+			FReadTimeout:     100 * time.Millisecond,
+			FWriteTimeout:    time.Minute,
+			FGossipFanout:    1,
+			FGossipLazyPush:  true,
+			FGossipCacheSize: 64,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+					FWorkSizeBits: 1,
+				}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           time.Minute,
+				FWriteTimeout:          time.Minute,
+			}),
+		}),
+		cache.NewLRUCache(1024),
+	)
+	node2 := newTestNodeWithGossip("", pipeTransport, 1, true)
+
+	go func() { _ = node1.Run(ctx) }()
+
+	err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		return node2.AddConnection(ctx, "service")
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+
+	// peer announces the message and goes quiet
+	hash := hashing.NewHasher([]byte("hash")).ToBytes()
+	if err := node2.GetConnections()["service"].WriteControl(ctx, joinControl(cControlAnnounce, hash)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	if len(node1.GetConnections()) != 1 {
+		t.Error("connection is closed after the announce")
+		return
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	t.Parallel()
+
+	node1 := newTestNode(testutils.TgAddrs[6], 16)
+	node2 := newTestNode("", 16)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { _ = node1.Run(ctx) }()
+
+	err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		return node2.AddConnection(ctx, testutils.TgAddrs[6])
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+
+	headHandle := uint32(123)
+	sett := layer1.NewConstructSettings(&layer1.SConstructSettings{
+		FSettings: node2.GetSettings().GetConnSettings().GetMessageSettings(),
+	})
+
+	go func() {
+		for i := 0; i < 1000; i++ {
+			pld := payload.NewPayload32(
+				headHandle,
+				[]byte(fmt.Sprintf(tcBodyTemplate, i)),
+			)
+			if err := node2.BroadcastMessage(ctx, layer1.NewMessage(sett, pld)); err != nil {
+				return
+			}
+		}
+		t.Error("success all broadcast messages with canceled context")
+	}()
+
+	cancel()
+}
+
+func testNodes(ctx context.Context) ([5]INode, map[INode]map[string]bool, error) {
+	nodes := [5]INode{}
+	addrs := [5]string{"", "", testutils.TgAddrs[0], "", testutils.TgAddrs[1]}
+
+	for i := 0; i < 5; i++ {
+		nodes[i] = newTestNode(addrs[i], 16)
+	}
+
+	go func() { _ = nodes[2].Run(ctx) }()
+	go func() { _ = nodes[4].Run(ctx) }()
+
+	err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		return nodes[0].AddConnection(ctx, testutils.TgAddrs[0])
+	})
+	if err1 != nil {
+		return [5]INode{}, nil, err1
+	}
+	err2 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		return nodes[1].AddConnection(ctx, testutils.TgAddrs[1])
+	})
+	if err2 != nil {
+		return [5]INode{}, nil, err2
+	}
+
+	_ = nodes[3].AddConnection(ctx, testutils.TgAddrs[0])
+	_ = nodes[3].AddConnection(ctx, testutils.TgAddrs[1])
+
+	mapp := make(map[INode]map[string]bool)
+	for _, node := range nodes {
+		// pass sender
+		if node == nodes[0] {
+			continue
+		}
+		mapp[node] = make(map[string]bool)
+		for i := 0; i < tcIter; i++ {
+			mapp[node][fmt.Sprintf(tcBodyTemplate, i)] = false
+		}
+	}
+
+	return nodes, mapp, nil
+}
+
+func TestLinkHandshake(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pipeTransport := transport.NewPipeTransport()
+	privKey1 := asymmetric.NewPrivKey()
+	privKey2 := asymmetric.NewPrivKey()
+
+	node1 := newTestNodeWithLink("service", pipeTransport, privKey1, asymmetric.NewMapPubKeys(privKey2.GetPubKey()))
+	node2 := newTestNodeWithLink("", pipeTransport, privKey2, asymmetric.NewMapPubKeys(privKey1.GetPubKey()))
+	node3 := newTestNodeWithLink("", pipeTransport, asymmetric.NewPrivKey(), nil)
+
+	go func() { _ = node1.Run(ctx) }()
+
+	err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		return node2.AddConnection(ctx, "service")
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+
+	// node1 does not trust the node3
+	if err := node3.AddConnection(ctx, "service"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	err2 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		conns := node1.GetConnections()
+		if len(conns) != 1 {
+			return errors.New("length of connections != 1")
+		}
+		for _, c := range conns {
+			if !bytes.Equal(c.GetPeerPubKey().ToBytes(), privKey2.GetPubKey().ToBytes()) {
+				return errors.New("got invalid peer public key")
+			}
+		}
+		return nil
+	})
+	if err2 != nil {
+		t.Error(err2)
+		return
+	}
+}
+
+func TestPendingLimit(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pipeTransport := transport.NewPipeTransport()
+	privKey1 := asymmetric.NewPrivKey()
+	privKey2 := asymmetric.NewPrivKey()
+
+	timeout := time.Minute
+	node1 := NewNode(
+		NewSettings(&SSettings{
+			FTransport:        pipeTransport,
+			FAddress:          "service",
+			FMaxConnects:      16,
+			FMaxPending:       1,
+			FHandshakeTimeout: 500 * time.Millisecond,
+			FReadTimeout:      timeout,
+			FWriteTimeout:     timeout,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+					FWorkSizeBits: 10,
+				}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           timeout,
+				FWriteTimeout:          timeout,
+				FLinkPrivKey:           privKey1,
+				FLinkPubKeys:           asymmetric.NewMapPubKeys(privKey2.GetPubKey()),
+			}),
+		}),
+		cache.NewLRUCache(1024),
+	)
+	node2 := newTestNodeWithLink("", pipeTransport, privKey2, asymmetric.NewMapPubKeys(privKey1.GetPubKey()))
+
+	go func() { _ = node1.Run(ctx) }()
+
+	// silent socket holds the only slot of the pending connections
+	var silent net.Conn
+	err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		var err error
+		silent, err = pipeTransport.Dial(ctx, "service")
+		return err
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+	defer silent.Close()
+
+	// other sockets are closed while the slot is held
+	err2 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		other, err := pipeTransport.Dial(ctx, "service")
+		if err != nil {
+			return err
+		}
+		defer other.Close()
+		_ = other.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		if _, err := other.Read(make([]byte, 1)); errors.Is(err, os.ErrDeadlineExceeded) {
+			return errors.New("socket is not closed")
+		}
+		return nil
+	})
+	if err2 != nil {
+		t.Error(err2)
+		return
+	}
+
+	// silent socket is dropped by the deadline of establishment
+	start := time.Now()
+	_ = silent.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := silent.Read(make([]byte, 1)); errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error("silent socket is not closed by the handshake timeout")
+		return
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("silent socket is closed too late")
+		return
+	}
+
+	// released slot is used by the trusted peer
+	err3 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		return node2.AddConnection(ctx, "service")
+	})
+	if err3 != nil {
+		t.Error(err3)
+		return
+	}
+}
+
+func TestNetworkProofPending(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pipeTransport := transport.NewPipeTransport()
+
+	node1 := newTestNodeWithProof("service", pipeTransport, "key", 1)
+	node2 := newTestNodeWithProof("", pipeTransport, "key", 0)
+	node3 := newTestNodeWithProof("", pipeTransport, "other-key", 0)
+
+	go func() { _ = node1.Run(ctx) }()
+
+	var silent net.Conn
+	err1 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		var err error
+		silent, err = pipeTransport.Dial(ctx, "service")
+		return err
+	})
+	if err1 != nil {
+		t.Error(err1)
+		return
+	}
+	defer silent.Close()
+
+	// proof is not started while the slot is held by the stranger
+	if err := node2.AddConnection(ctx, "service"); err == nil {
+		t.Error("success proof over the limit of pending connections")
+		return
+	}
+
+	_ = silent.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := silent.Read(make([]byte, 1)); errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error("silent socket is not closed by the handshake timeout")
+		return
+	}
+
+	// failed proof releases the slot
+	if err := node3.AddConnection(ctx, "service"); err == nil {
+		t.Error("success proof with another network key")
+		return
+	}
+	err2 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		return node2.AddConnection(ctx, "service")
+	})
+	if err2 != nil {
+		t.Error(err2)
+		return
+	}
+	err3 := testutils.TryN(50, 10*time.Millisecond, func() error {
+		if len(node1.GetConnections()) != 1 {
+			return errors.New("length of connections != 1")
+		}
+		return nil
+	})
+	if err3 != nil {
+		t.Error(err3)
+		return
+	}
+}
+
+func newTestNodeWithLink(
+	pAddr string,
+	pTransport transport.ITransport,
+	pPrivKey asymmetric.IPrivKey,
+	pPubKeys asymmetric.IMapPubKeys,
+) INode {
+	timeout := time.Minute
+	return NewNode(
+		NewSettings(&SSettings{
+			FTransport:    pTransport,
+			FAddress:      pAddr,
+			FMaxConnects:  16,
+			FReadTimeout:  timeout,
+			FWriteTimeout: timeout,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+					FWorkSizeBits: 10,
+				}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           timeout,
+				FWriteTimeout:          timeout,
+				FLinkPrivKey:           pPrivKey,
+				FLinkPubKeys:           pPubKeys,
+			}),
+		}),
+		cache.NewLRUCache(1024),
+	)
+}
+
+func newTestNodeWithProof(
+	pAddr string,
+	pTransport transport.ITransport,
+	pNetworkKey string,
+	pMaxPending uint64,
+) INode {
+	timeout := time.Minute
+	return NewNode(
+		NewSettings(&SSettings{
+			FTransport:        pTransport,
+			FAddress:          pAddr,
+			FMaxConnects:      16,
+			FMaxPending:       pMaxPending,
+			FHandshakeTimeout: 500 * time.Millisecond,
+			FReadTimeout:      timeout,
+			FWriteTimeout:     timeout,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+					FWorkSizeBits: 10,
+					FNetworkKey:   pNetworkKey,
+				}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           timeout,
+				FWriteTimeout:          timeout,
+				FNetworkKeyProof:       true,
+			}),
+		}),
+		cache.NewLRUCache(1024),
+	)
+}
+
+func newTestNodeWithKeepAlive(
+	pAddr string,
+	pTransport transport.ITransport,
+	pReadTimeout time.Duration,
+	pPingInterval time.Duration,
+) INode {
+	timeout := time.Minute
+	return NewNode(
+		NewSettings(&SSettings{
+			FTransport:    pTransport,
+			FAddress:      pAddr,
+			FMaxConnects:  16,
+			FReadTimeout:  pReadTimeout,
+			FWriteTimeout: timeout,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+					FWorkSizeBits: 10,
+				}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           timeout,
+				FWriteTimeout:          timeout,
+				FPingInterval:          pPingInterval,
+				FPingTimeout:           time.Second,
+			}),
+		}),
+		cache.NewLRUCache(1024),
+	)
+}
+
+func newTestNodeWithBan(pAddr string, pThreshold uint64) INode {
+	timeout := time.Minute
+	return NewNode(
+		NewSettings(&SSettings{
+			FAddress:      pAddr,
+			FMaxConnects:  16,
+			FReadTimeout:  timeout,
+			FWriteTimeout: timeout,
+			FBanThreshold: pThreshold,
+			FBanDuration:  time.Hour,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+					FWorkSizeBits: 10,
+				}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           timeout,
+				FWriteTimeout:          timeout,
+			}),
+		}),
+		cache.NewLRUCache(1024),
+	)
+}
+
+func newTestNodeWithBanProof(pThreshold uint64, pNetworkKey string) INode {
+	timeout := time.Minute
+	return NewNode(
+		NewSettings(&SSettings{
+			FMaxConnects:      16,
+			FHandshakeTimeout: 500 * time.Millisecond,
+			FReadTimeout:      timeout,
+			FWriteTimeout:     timeout,
+			FBanThreshold:     pThreshold,
+			FBanDuration:      time.Hour,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+					FWorkSizeBits: 10,
+					FNetworkKey:   pNetworkKey,
+				}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           timeout,
+				FWriteTimeout:          timeout,
+				FNetworkKeyProof:       true,
+			}),
+		}),
+		cache.NewLRUCache(1024),
+	)
+}
+
+func newTestNodeWithLimits(
+	pAddr string,
+	pTransport transport.ITransport,
+	pConnReadLimit ratelimit.ISettings,
+	pWriteLimit ratelimit.ISettings,
+) INode {
+	timeout := time.Minute
+	return NewNode(
+		NewSettings(&SSettings{
+			FTransport:     pTransport,
+			FAddress:       pAddr,
+			FMaxConnects:   16,
+			FReadTimeout:   timeout,
+			FWriteTimeout:  timeout,
+			FConnReadLimit: pConnReadLimit,
+			FWriteLimit:    pWriteLimit,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+					FWorkSizeBits: 1,
+				}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           timeout,
+				FWriteTimeout:          timeout,
+			}),
+		}),
+		cache.NewLRUCache(1024),
+	)
+}
+
+func newTestNodeWithGossip(pAddr string, pTransport transport.ITransport, pFanout uint64, pLazyPush bool) INode {
+	timeout := time.Minute
+	return NewNode(
+		NewSettings(&SSettings{
+			FTransport:       pTransport,
+			FAddress:         pAddr,
+			FMaxConnects:     16,
+			FReadTimeout:     timeout,
+			FWriteTimeout:    timeout,
+			FGossipFanout:    pFanout,
+			FGossipLazyPush:  pLazyPush,
+			FGossipCacheSize: 64,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+					FWorkSizeBits: 1,
+				}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           timeout,
+				FWriteTimeout:          timeout,
+			}),
+		}),
+		cache.NewLRUCache(1024),
+	)
+}
+
+func newTestNodeWithDirections(pAddr string, pTransport transport.ITransport, pAllowList []string) INode {
+	timeout := time.Minute
+	return NewNode(
+		NewSettings(&SSettings{
+			FTransport:    pTransport,
+			FAddress:      pAddr,
+			FMaxConnects:  16,
+			FMaxInbound:   1,
+			FMaxOutbound:  1,
+			FAllowList:    pAllowList,
+			FReadTimeout:  timeout,
+			FWriteTimeout: timeout,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+					FWorkSizeBits: 1,
+				}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           timeout,
+				FWriteTimeout:          timeout,
+			}),
+		}),
+		cache.NewLRUCache(1024),
+	)
+}
+
+func newTestNode(pAddr string, pMaxConns uint64) INode {
+	timeout := time.Minute
+	return NewNode(
+		NewSettings(&SSettings{
+			FAddress:      pAddr,
+			FMaxConnects:  pMaxConns,
+			FReadTimeout:  timeout,
+			FWriteTimeout: timeout,
+			FConnSettings: conn.NewSettings(&conn.SSettings{
+				FMessageSettings: layer1.NewSettings(&layer1.SSettings{
+					FWorkSizeBits: 10,
+				}),
+				FLimitMessageSizeBytes: (8 << 10),
+				FWaitReadTimeout:       time.Hour,
+				FDialTimeout:           time.Minute,
+				FReadTimeout:           timeout,
+				FWriteTimeout:          timeout,
+			}),
+		}),
+		cache.NewLRUCache(1024),
+	)
+}
+
+func newTestNodeWithTransport(pAddr string, pMaxConns uint64, pTransport transport.ITransport) INode {
 	timeout := time.Minute
 	return NewNode(
 		NewSettings(&SSettings{
+			FTransport:    pTransport,
 			FAddress:      pAddr,
 			FMaxConnects:  pMaxConns,
 			FReadTimeout:  timeout,
@@ -0,0 +1,155 @@
+package network
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+
+	"github.com/number571/go-peer/pkg/network/ratelimit"
+)
+
+// Queue of the writes delayed by the limiter of node. The messages with
+// more work (see layer1.IMessage.GetWorkSizeBits) are written first. If the
+// queue is full, then the message with the least work is dropped.
+type sPriorityQueue struct {
+	fMutex   sync.Mutex
+	fLimiter ratelimit.ILimiter
+	fSize    uint64
+	fWaiters sWaiters
+	fCounter uint64
+	fRunning bool
+}
+
+type sWaiter struct {
+	fCtx   context.Context
+	fWork  uint64
+	fSize  uint64
+	fSeq   uint64
+	fIndex int
+	fDone  chan error
+}
+
+type sWaiters []*sWaiter
+
+func newPriorityQueue(pLimiter ratelimit.ILimiter, pSize uint64) *sPriorityQueue {
+	if pLimiter == nil || pSize == 0 {
+		return nil // order of arrival
+	}
+	return &sPriorityQueue{
+		fLimiter: pLimiter,
+		fSize:    pSize,
+		fWaiters: make(sWaiters, 0, pSize),
+	}
+}
+
+// Waits until the tokens of the message are taken by the queue.
+func (p *sPriorityQueue) wait(pCtx context.Context, pWork, pSize uint64) error {
+	p.fMutex.Lock()
+
+	// queue is not congested => message is written without the delay
+	if len(p.fWaiters) == 0 && !p.fRunning && p.fLimiter.Allow(pSize) {
+		p.fMutex.Unlock()
+		return nil
+	}
+
+	if uint64(len(p.fWaiters)) >= p.fSize {
+		lowest := p.fWaiters.getLowest()
+		if lowest.fWork >= pWork {
+			p.fMutex.Unlock()
+			return ErrRateLimit
+		}
+		heap.Remove(&p.fWaiters, lowest.fIndex)
+		lowest.fDone <- ErrRateLimit
+	}
+
+	p.fCounter++
+	waiter := &sWaiter{
+		fCtx:  pCtx,
+		fWork: pWork,
+		fSize: pSize,
+		fSeq:  p.fCounter,
+		fDone: make(chan error, 1),
+	}
+	heap.Push(&p.fWaiters, waiter)
+
+	if !p.fRunning {
+		p.fRunning = true
+		go p.run()
+	}
+	p.fMutex.Unlock()
+
+	select {
+	case <-pCtx.Done():
+		p.fMutex.Lock()
+		if waiter.fIndex >= 0 {
+			heap.Remove(&p.fWaiters, waiter.fIndex)
+		}
+		p.fMutex.Unlock()
+		return pCtx.Err()
+	case err := <-waiter.fDone:
+		return err
+	}
+}
+
+// Takes the tokens for the waiters by the priority. Goroutine
+// is finished when the queue becomes empty.
+func (p *sPriorityQueue) run() {
+	for {
+		p.fMutex.Lock()
+		if len(p.fWaiters) == 0 {
+			p.fRunning = false
+			p.fMutex.Unlock()
+			return
+		}
+		waiter := heap.Pop(&p.fWaiters).(*sWaiter)
+		p.fMutex.Unlock()
+
+		// canceled waiter does not take the tokens
+		waiter.fDone <- p.fLimiter.Wait(waiter.fCtx, waiter.fSize)
+	}
+}
+
+func (p sWaiters) Len() int {
+	return len(p)
+}
+
+// More work is the higher priority, then the order of arrival.
+func (p sWaiters) Less(i, j int) bool {
+	if p[i].fWork != p[j].fWork {
+		return p[i].fWork > p[j].fWork
+	}
+	return p[i].fSeq < p[j].fSeq
+}
+
+func (p sWaiters) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+	p[i].fIndex = i
+	p[j].fIndex = j
+}
+
+func (p *sWaiters) Push(x any) {
+	waiter := x.(*sWaiter)
+	waiter.fIndex = len(*p)
+	*p = append(*p, waiter)
+}
+
+func (p *sWaiters) Pop() any {
+	old := *p
+	n := len(old)
+	waiter := old[n-1]
+	old[n-1] = nil
+	waiter.fIndex = -1
+	*p = old[:n-1]
+	return waiter
+}
+
+// Returns the waiter with the least work (the latest of them).
+func (p sWaiters) getLowest() *sWaiter {
+	lowest := p[0]
+	for _, w := range p[1:] {
+		if p.Less(lowest.fIndex, w.fIndex) {
+			lowest = w
+		}
+	}
+	return lowest
+}
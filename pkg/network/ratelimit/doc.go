@@ -0,0 +1,5 @@
+// Package ratelimit allows you to limit the rate of messages and bytes.
+//
+// The limiter is based on the token buckets with the burst equal to one second of the rate.
+// The message larger than the burst is allowed when the bucket is full (the bucket goes into debt).
+package ratelimit
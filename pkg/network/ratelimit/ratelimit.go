@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var (
+	_ ILimiter = &sLimiter{}
+)
+
+type sLimiter struct {
+	fMutex    sync.Mutex
+	fSettings ISettings
+	fMessages *sBucket
+	fBytes    *sBucket
+}
+
+type sBucket struct {
+	fRate    float64
+	fTokens  float64
+	fUpdated time.Time
+}
+
+func NewLimiter(pSett ISettings) ILimiter {
+	now := time.Now()
+	return &sLimiter{
+		fSettings: pSett,
+		fMessages: newBucket(pSett.GetMessagesPerSec(), now),
+		fBytes:    newBucket(pSett.GetBytesPerSec(), now),
+	}
+}
+
+func newBucket(pRate uint64, pNow time.Time) *sBucket {
+	if pRate == 0 {
+		return nil // unlimited
+	}
+	return &sBucket{
+		fRate:    float64(pRate),
+		fTokens:  float64(pRate),
+		fUpdated: pNow,
+	}
+}
+
+func (p *sLimiter) GetSettings() ISettings {
+	return p.fSettings
+}
+
+// Takes the tokens of one message with the size if they are available.
+func (p *sLimiter) Allow(pSize uint64) bool {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	return p.reserve(pSize) == 0
+}
+
+// Waits until the tokens of one message with the size are available and takes them.
+func (p *sLimiter) Wait(pCtx context.Context, pSize uint64) error {
+	for {
+		p.fMutex.Lock()
+		delay := p.reserve(pSize)
+		p.fMutex.Unlock()
+
+		if delay == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-pCtx.Done():
+			timer.Stop()
+			return pCtx.Err()
+		case <-timer.C:
+			// try again
+		}
+	}
+}
+
+// Returns zero and takes the tokens from both buckets if they are
+// available, otherwise returns the delay to wait for the tokens.
+func (p *sLimiter) reserve(pSize uint64) time.Duration {
+	now := time.Now()
+
+	delay := max(p.fMessages.getDelay(now, 1), p.fBytes.getDelay(now, pSize))
+	if delay != 0 {
+		return delay
+	}
+
+	p.fMessages.take(1)
+	p.fBytes.take(pSize)
+	return 0
+}
+
+func (p *sBucket) getDelay(pNow time.Time, pSize uint64) time.Duration {
+	if p == nil {
+		return 0
+	}
+
+	p.fTokens = min(p.fRate, p.fTokens+pNow.Sub(p.fUpdated).Seconds()*p.fRate)
+	p.fUpdated = pNow
+
+	// the size larger than burst waits for the full bucket
+	need := min(float64(pSize), p.fRate)
+	if p.fTokens >= need {
+		return 0
+	}
+
+	delay := time.Duration((need - p.fTokens) / p.fRate * float64(time.Second))
+	return max(delay, time.Millisecond)
+}
+
+func (p *sBucket) take(pSize uint64) {
+	if p == nil {
+		return
+	}
+	p.fTokens -= float64(pSize)
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSettings(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 1; i++ {
+		testSettings(t, i)
+	}
+}
+
+func testSettings(t *testing.T, n int) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("nothing panics")
+			return
+		}
+	}()
+	switch n {
+	case 0:
+		_ = NewSettings(&SSettings{
+			FDelayOnLimit: true,
+		})
+	}
+}
+
+func TestAllow(t *testing.T) {
+	t.Parallel()
+
+	sett := NewSettings(&SSettings{
+		FMessagesPerSec: 10,
+	})
+	limiter := NewLimiter(sett)
+	if limiter.GetSettings() != sett {
+		t.Error("got invalid settings")
+		return
+	}
+
+	// burst = one second of the rate
+	for i := 0; i < 10; i++ {
+		if !limiter.Allow(1 << 20) {
+			t.Errorf("message (%d) is not allowed", i)
+			return
+		}
+	}
+	if limiter.Allow(1) {
+		t.Error("message over the limit is allowed")
+		return
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if !limiter.Allow(1) {
+		t.Error("message is not allowed after the refill")
+		return
+	}
+}
+
+func TestAllowBytes(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewLimiter(NewSettings(&SSettings{
+		FBytesPerSec: 100,
+	}))
+
+	if !limiter.Allow(60) || limiter.Allow(60) {
+		t.Error("got invalid limit of bytes")
+		return
+	}
+
+	// the message larger than the burst is allowed with the full bucket
+	largeLimiter := NewLimiter(NewSettings(&SSettings{
+		FBytesPerSec: 100,
+	}))
+	if !largeLimiter.Allow(1000) {
+		t.Error("large message is not allowed with the full bucket")
+		return
+	}
+	if largeLimiter.Allow(1) {
+		t.Error("bucket has not gone into debt")
+		return
+	}
+}
+
+func TestWait(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewLimiter(NewSettings(&SSettings{
+		FMessagesPerSec: 20,
+		FBytesPerSec:    1 << 20,
+		FDelayOnLimit:   true,
+	}))
+
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		if err := limiter.Wait(ctx, 1); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	start := time.Now()
+	if err := limiter.Wait(ctx, 1); err != nil {
+		t.Error(err)
+		return
+	}
+	if time.Since(start) < 25*time.Millisecond {
+		t.Error("message over the limit is not delayed")
+		return
+	}
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	for i := 0; i < 20; i++ {
+		_ = limiter.Allow(1)
+	}
+	if err := limiter.Wait(cancelCtx, 1); !errors.Is(err, context.Canceled) {
+		t.Error("success wait with canceled context")
+		return
+	}
+}
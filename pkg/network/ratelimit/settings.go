@@ -0,0 +1,42 @@
+package ratelimit
+
+var (
+	_ ISettings = &sSettings{}
+)
+
+type SSettings sSettings
+type sSettings struct {
+	FMessagesPerSec uint64
+	FBytesPerSec    uint64
+	FDelayOnLimit   bool
+}
+
+func NewSettings(pSett *SSettings) ISettings {
+	return (&sSettings{
+		FMessagesPerSec: pSett.FMessagesPerSec,
+		FBytesPerSec:    pSett.FBytesPerSec,
+		FDelayOnLimit:   pSett.FDelayOnLimit,
+	}).mustNotNull()
+}
+
+func (p *sSettings) mustNotNull() ISettings {
+	// p.FMessagesPerSec can be = 0 (messages are unlimited)
+	// p.FBytesPerSec can be = 0 (bytes are unlimited)
+	if p.FMessagesPerSec == 0 && p.FBytesPerSec == 0 {
+		panic(`p.FMessagesPerSec == 0 && p.FBytesPerSec == 0`)
+	}
+	return p
+}
+
+func (p *sSettings) GetMessagesPerSec() uint64 {
+	return p.FMessagesPerSec
+}
+
+func (p *sSettings) GetBytesPerSec() uint64 {
+	return p.FBytesPerSec
+}
+
+// Messages over the limit are delayed (true) or dropped (false).
+func (p *sSettings) GetDelayOnLimit() bool {
+	return p.FDelayOnLimit
+}
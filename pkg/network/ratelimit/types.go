@@ -0,0 +1,18 @@
+package ratelimit
+
+import (
+	"context"
+)
+
+type ILimiter interface {
+	GetSettings() ISettings
+
+	Allow(uint64) bool
+	Wait(context.Context, uint64) error
+}
+
+type ISettings interface {
+	GetMessagesPerSec() uint64
+	GetBytesPerSec() uint64
+	GetDelayOnLimit() bool
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/number571/go-peer/pkg/network/conn"
+	"github.com/number571/go-peer/pkg/network/ratelimit"
+	"github.com/number571/go-peer/pkg/network/transport"
 )
 
 var (
@@ -13,19 +15,65 @@ var (
 type SSettings sSettings
 type sSettings struct {
 	FConnSettings conn.ISettings
+	FTransport    transport.ITransport
 	FAddress      string
 	FMaxConnects  uint64
+	FMaxInbound   uint64
+	FMaxOutbound  uint64
+	FAllowList    []string
 	FReadTimeout  time.Duration
 	FWriteTimeout time.Duration
+	FBanThreshold uint64
+	FBanDuration  time.Duration
+
+	// Establishment of the accepted connections (before they are counted).
+	FMaxPending       uint64
+	FHandshakeTimeout time.Duration
+
+	// Limits of the node (global) and of each connection.
+	FReadLimit      ratelimit.ISettings
+	FWriteLimit     ratelimit.ISettings
+	FConnReadLimit  ratelimit.ISettings
+	FConnWriteLimit ratelimit.ISettings
+
+	// Delayed writes of the node are ordered by the work of messages.
+	FPriorityQueueSize uint64
+
+	// Dissemination of the messages by the gossip.
+	FGossipFanout       uint64
+	FGossipLazyPush     bool
+	FGossipCacheSize    uint64
+	FGossipControlLimit ratelimit.ISettings
 }
 
 func NewSettings(pSett *SSettings) ISettings {
 	return (&sSettings{
 		FConnSettings: pSett.FConnSettings,
+		FTransport:    pSett.FTransport,
 		FAddress:      pSett.FAddress,
 		FMaxConnects:  pSett.FMaxConnects,
+		FMaxInbound:   pSett.FMaxInbound,
+		FMaxOutbound:  pSett.FMaxOutbound,
+		FAllowList:    copyAddresses(pSett.FAllowList),
 		FReadTimeout:  pSett.FReadTimeout,
 		FWriteTimeout: pSett.FWriteTimeout,
+		FBanThreshold: pSett.FBanThreshold,
+		FBanDuration:  pSett.FBanDuration,
+
+		FMaxPending:       pSett.FMaxPending,
+		FHandshakeTimeout: pSett.FHandshakeTimeout,
+
+		FReadLimit:      pSett.FReadLimit,
+		FWriteLimit:     pSett.FWriteLimit,
+		FConnReadLimit:  pSett.FConnReadLimit,
+		FConnWriteLimit: pSett.FConnWriteLimit,
+
+		FPriorityQueueSize: pSett.FPriorityQueueSize,
+
+		FGossipFanout:       pSett.FGossipFanout,
+		FGossipLazyPush:     pSett.FGossipLazyPush,
+		FGossipCacheSize:    pSett.FGossipCacheSize,
+		FGossipControlLimit: pSett.FGossipControlLimit,
 	}).mustNotNull()
 }
 
@@ -36,12 +84,48 @@ func (p *sSettings) mustNotNull() ISettings {
 	if p.FMaxConnects == 0 {
 		panic(`p.FMaxConnects == 0`)
 	}
+	// p.FMaxInbound, p.FMaxOutbound can be = 0 (only p.FMaxConnects is used)
+	// p.FAllowList can be = nil (all addresses have the limits)
 	if p.FReadTimeout == 0 {
 		panic(`p.FReadTimeout == 0`)
 	}
 	if p.FWriteTimeout == 0 {
 		panic(`p.FWriteTimeout == 0`)
 	}
+	// p.FBanThreshold can be = 0 (ban of peers is disabled)
+	if p.FBanThreshold != 0 && p.FBanDuration == 0 {
+		panic(`p.FBanThreshold != 0 && p.FBanDuration == 0`)
+	}
+	// p.FReadLimit, p.FWriteLimit, p.FConnReadLimit,
+	// p.FConnWriteLimit can be = nil (rate is unlimited)
+	// p.FPriorityQueueSize can be = 0 (order of arrival)
+	if p.FPriorityQueueSize != 0 && (p.FWriteLimit == nil || !p.FWriteLimit.GetDelayOnLimit()) {
+		panic(`p.FPriorityQueueSize != 0 && (p.FWriteLimit == nil || !p.FWriteLimit.GetDelayOnLimit())`)
+	}
+	// p.FGossipFanout can be = 0 (message is sent to all connections)
+	if p.FGossipLazyPush && p.FGossipFanout == 0 {
+		panic(`p.FGossipLazyPush && p.FGossipFanout == 0`)
+	}
+	if p.FGossipLazyPush && p.FGossipCacheSize == 0 {
+		panic(`p.FGossipLazyPush && p.FGossipCacheSize == 0`)
+	}
+	if p.FGossipControlLimit == nil {
+		// control frames of the lazy push are always limited
+		p.FGossipControlLimit = ratelimit.NewSettings(&ratelimit.SSettings{
+			FMessagesPerSec: cDefaultControlPerSec,
+		})
+	}
+	if p.FMaxPending == 0 {
+		// count of the pending accepted connections is always limited
+		p.FMaxPending = p.FMaxConnects
+	}
+	if p.FHandshakeTimeout == 0 {
+		p.FHandshakeTimeout = p.FReadTimeout
+	}
+	if p.FTransport == nil {
+		// default transport is used by the historical behavior
+		p.FTransport = transport.NewTCPTransport()
+	}
 	return p
 }
 
@@ -53,10 +137,27 @@ func (p *sSettings) GetMaxConnects() uint64 {
 	return p.FMaxConnects
 }
 
+func (p *sSettings) GetMaxInbound() uint64 {
+	return p.FMaxInbound
+}
+
+func (p *sSettings) GetMaxOutbound() uint64 {
+	return p.FMaxOutbound
+}
+
+// Addresses (host:port) or hosts which bypass the limits of connections.
+func (p *sSettings) GetAllowList() []string {
+	return p.FAllowList
+}
+
 func (p *sSettings) GetConnSettings() conn.ISettings {
 	return p.FConnSettings
 }
 
+func (p *sSettings) GetTransport() transport.ITransport {
+	return p.FTransport
+}
+
 func (p *sSettings) GetReadTimeout() time.Duration {
 	return p.FReadTimeout
 }
@@ -64,3 +165,73 @@ func (p *sSettings) GetReadTimeout() time.Duration {
 func (p *sSettings) GetWriteTimeout() time.Duration {
 	return p.FWriteTimeout
 }
+
+func (p *sSettings) GetBanThreshold() uint64 {
+	return p.FBanThreshold
+}
+
+func (p *sSettings) GetBanDuration() time.Duration {
+	return p.FBanDuration
+}
+
+// Limit of the accepted connections which are establishing (preamble,
+// network key proof, handshake). Other accepted sockets are closed.
+func (p *sSettings) GetMaxPending() uint64 {
+	return p.FMaxPending
+}
+
+// Deadline of the whole establishment of the accepted connection.
+func (p *sSettings) GetHandshakeTimeout() time.Duration {
+	return p.FHandshakeTimeout
+}
+
+func (p *sSettings) GetReadLimit() ratelimit.ISettings {
+	return p.FReadLimit
+}
+
+func (p *sSettings) GetWriteLimit() ratelimit.ISettings {
+	return p.FWriteLimit
+}
+
+func (p *sSettings) GetConnReadLimit() ratelimit.ISettings {
+	return p.FConnReadLimit
+}
+
+func (p *sSettings) GetConnWriteLimit() ratelimit.ISettings {
+	return p.FConnWriteLimit
+}
+
+// Size of the queue of messages delayed by the write limit of node.
+// Messages with more work are relayed first when the node is congested.
+func (p *sSettings) GetPriorityQueueSize() uint64 {
+	return p.FPriorityQueueSize
+}
+
+func copyAddresses(pAddresses []string) []string {
+	if pAddresses == nil {
+		return nil
+	}
+	result := make([]string, len(pAddresses))
+	copy(result, pAddresses)
+	return result
+}
+
+// Count of the random connections receiving the message.
+func (p *sSettings) GetGossipFanout() uint64 {
+	return p.FGossipFanout
+}
+
+// Other connections receive the hash of message and pull it on demand.
+func (p *sSettings) GetGossipLazyPush() bool {
+	return p.FGossipLazyPush
+}
+
+func (p *sSettings) GetGossipCacheSize() uint64 {
+	return p.FGossipCacheSize
+}
+
+// Limit of the control frames (announce, pull) received from each connection.
+// Control frames over the limit are dropped.
+func (p *sSettings) GetGossipControlLimit() ratelimit.ISettings {
+	return p.FGossipControlLimit
+}
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"github.com/number571/go-peer/pkg/network/conn"
+)
+
+var (
+	_ IConnStats = &sConnStats{}
+)
+
+type sConnStats struct {
+	conn.IStats
+	fDuplicates uint64
+}
+
+// Messages rejected by the cache setter as already received.
+func (p *sConnStats) GetDuplicates() uint64 {
+	return p.fDuplicates
+}
+
+// Returns the snapshot of statistics for all the connections.
+func (p *sNode) GetStats() map[string]IConnStats {
+	p.fMutex.RLock()
+	defer p.fMutex.RUnlock()
+
+	mapping := make(map[string]IConnStats, len(p.fConnections))
+	for addr, conn := range p.fConnections {
+		mapping[addr] = &sConnStats{
+			IStats:      conn.GetStats(),
+			fDuplicates: p.getDuplicates(conn),
+		}
+	}
+
+	return mapping
+}
+
+// Increments the duplicates of the connection if it is stored.
+func (p *sNode) addDuplicate(pConn conn.IConn) {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	if state, ok := p.fConnStates[pConn]; ok {
+		state.fDuplicates++
+	}
+}
+
+func (p *sNode) getDuplicates(pConn conn.IConn) uint64 {
+	if state, ok := p.fConnStates[pConn]; ok {
+		return state.fDuplicates
+	}
+	return 0
+}
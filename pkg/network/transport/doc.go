@@ -0,0 +1,5 @@
+// Package transport abstracts the way network nodes listen for and dial connections.
+//
+// The package ships TCP, Unix-domain socket and in-process (net.Pipe) transports.
+// The in-process transport does not bind real ports and is useful for tests.
+package transport
@@ -0,0 +1,21 @@
+package transport
+
+const (
+	errPrefix = "pkg/network/transport = "
+)
+
+type STransportError struct {
+	str string
+}
+
+func (err *STransportError) Error() string {
+	return errPrefix + err.str
+}
+
+var (
+	ErrListen          = &STransportError{"listen"}
+	ErrDial            = &STransportError{"dial"}
+	ErrAddressInUse    = &STransportError{"address already in use"}
+	ErrAddressNotFound = &STransportError{"address not found"}
+	ErrListenerClosed  = &STransportError{"listener closed"}
+)
@@ -0,0 +1,151 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	cNetworkPipe = "pipe"
+)
+
+var (
+	_ ITransport   = &sPipeTransport{}
+	_ net.Listener = &sPipeListener{}
+	_ net.Conn     = &sPipeConn{}
+	_ net.Addr     = sPipeAddr("")
+)
+
+type sPipeTransport struct {
+	fMutex     sync.Mutex
+	fCounter   uint64 // atomic variable
+	fListeners map[string]*sPipeListener
+}
+
+type sPipeListener struct {
+	fOnce      sync.Once
+	fAddr      sPipeAddr
+	fTransport *sPipeTransport
+	fAccept    chan net.Conn
+	fClosed    chan struct{}
+}
+
+type sPipeConn struct {
+	net.Conn
+	fLocalAddr  sPipeAddr
+	fRemoteAddr sPipeAddr
+}
+
+type sPipeAddr string
+
+// In-process transport based on net.Pipe. Ports are not bound, so
+// the addresses are just names unique within one transport object.
+// Nodes must share the same transport object to see each other.
+func NewPipeTransport() ITransport {
+	return &sPipeTransport{
+		fListeners: make(map[string]*sPipeListener, 16),
+	}
+}
+
+func (p *sPipeTransport) Listen(_ context.Context, pAddr string) (net.Listener, error) {
+	p.fMutex.Lock()
+	defer p.fMutex.Unlock()
+
+	if _, ok := p.fListeners[pAddr]; ok {
+		return nil, errors.Join(ErrListen, ErrAddressInUse)
+	}
+
+	listener := &sPipeListener{
+		fAddr:      sPipeAddr(pAddr),
+		fTransport: p,
+		fAccept:    make(chan net.Conn),
+		fClosed:    make(chan struct{}),
+	}
+
+	p.fListeners[pAddr] = listener
+	return listener, nil
+}
+
+func (p *sPipeTransport) Dial(pCtx context.Context, pAddr string) (net.Conn, error) {
+	p.fMutex.Lock()
+	listener, ok := p.fListeners[pAddr]
+	p.fMutex.Unlock()
+
+	if !ok {
+		return nil, errors.Join(ErrDial, ErrAddressNotFound)
+	}
+
+	id := atomic.AddUint64(&p.fCounter, 1)
+	dialAddr := sPipeAddr(fmt.Sprintf("%s-%d", cNetworkPipe, id))
+
+	serverConn, clientConn := net.Pipe()
+	select {
+	case <-pCtx.Done():
+		clientConn.Close()
+		serverConn.Close()
+		return nil, errors.Join(ErrDial, pCtx.Err())
+	case <-listener.fClosed:
+		clientConn.Close()
+		serverConn.Close()
+		return nil, errors.Join(ErrDial, ErrListenerClosed)
+	case listener.fAccept <- &sPipeConn{
+		Conn:        serverConn,
+		fLocalAddr:  listener.fAddr,
+		fRemoteAddr: dialAddr,
+	}:
+		return &sPipeConn{
+			Conn:        clientConn,
+			fLocalAddr:  dialAddr,
+			fRemoteAddr: listener.fAddr,
+		}, nil
+	}
+}
+
+func (p *sPipeListener) Accept() (net.Conn, error) {
+	select {
+	case <-p.fClosed:
+		return nil, net.ErrClosed
+	case conn := <-p.fAccept:
+		return conn, nil
+	}
+}
+
+func (p *sPipeListener) Close() error {
+	closed := false
+	p.fOnce.Do(func() {
+		p.fTransport.fMutex.Lock()
+		delete(p.fTransport.fListeners, string(p.fAddr))
+		p.fTransport.fMutex.Unlock()
+
+		close(p.fClosed)
+		closed = true
+	})
+	if !closed {
+		return net.ErrClosed
+	}
+	return nil
+}
+
+func (p *sPipeListener) Addr() net.Addr {
+	return p.fAddr
+}
+
+func (p *sPipeConn) LocalAddr() net.Addr {
+	return p.fLocalAddr
+}
+
+func (p *sPipeConn) RemoteAddr() net.Addr {
+	return p.fRemoteAddr
+}
+
+func (p sPipeAddr) Network() string {
+	return cNetworkPipe
+}
+
+func (p sPipeAddr) String() string {
+	return string(p)
+}
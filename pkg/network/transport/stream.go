@@ -0,0 +1,95 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+)
+
+const (
+	cNetworkTCP  = "tcp"
+	cNetworkUnix = "unix"
+)
+
+var (
+	_ ITransport   = &sStreamTransport{}
+	_ net.Listener = &sUnixListener{}
+	_ net.Conn     = &sUnixConn{}
+	_ net.Addr     = sUnixAddr("")
+)
+
+type sStreamTransport struct {
+	fNetwork string
+	fCounter uint64 // atomic variable
+}
+
+type sUnixListener struct {
+	net.Listener
+	fTransport *sStreamTransport
+}
+
+type sUnixConn struct {
+	net.Conn
+	fRemoteAddr sUnixAddr
+}
+
+type sUnixAddr string
+
+// Transport over TCP sockets. Address format = host:port.
+func NewTCPTransport() ITransport {
+	return &sStreamTransport{fNetwork: cNetworkTCP}
+}
+
+// Transport over Unix-domain sockets. Address format = path to socket file.
+func NewUnixTransport() ITransport {
+	return &sStreamTransport{fNetwork: cNetworkUnix}
+}
+
+func (p *sStreamTransport) Listen(pCtx context.Context, pAddr string) (net.Listener, error) {
+	listenConfig := &net.ListenConfig{}
+	listener, err := listenConfig.Listen(pCtx, p.fNetwork, pAddr)
+	if err != nil {
+		return nil, errors.Join(ErrListen, err)
+	}
+	if p.fNetwork == cNetworkUnix {
+		return &sUnixListener{Listener: listener, fTransport: p}, nil
+	}
+	return listener, nil
+}
+
+func (p *sStreamTransport) Dial(pCtx context.Context, pAddr string) (net.Conn, error) {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(pCtx, p.fNetwork, pAddr)
+	if err != nil {
+		return nil, errors.Join(ErrDial, err)
+	}
+	return conn, nil
+}
+
+// Accepted Unix-domain sockets have the same remote address ("@" or ""),
+// so each connection gets the unique address (as in the pipe transport).
+func (p *sUnixListener) Accept() (net.Conn, error) {
+	conn, err := p.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	id := atomic.AddUint64(&p.fTransport.fCounter, 1)
+	return &sUnixConn{
+		Conn:        conn,
+		fRemoteAddr: sUnixAddr(fmt.Sprintf("%s-%d", cNetworkUnix, id)),
+	}, nil
+}
+
+func (p *sUnixConn) RemoteAddr() net.Addr {
+	return p.fRemoteAddr
+}
+
+func (p sUnixAddr) Network() string {
+	return cNetworkUnix
+}
+
+func (p sUnixAddr) String() string {
+	return string(p)
+}
@@ -0,0 +1,210 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	testutils "github.com/number571/go-peer/test/utils"
+)
+
+const (
+	tcPipeAddr = "pipe-service"
+	tcMessage  = "hello, world!"
+)
+
+func TestError(t *testing.T) {
+	t.Parallel()
+
+	str := "value"
+	err := &STransportError{str}
+	if err.Error() != errPrefix+str {
+		t.Error("incorrect err.Error()")
+		return
+	}
+}
+
+func TestPipeTransport(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	transport := NewPipeTransport()
+
+	if _, err := transport.Dial(ctx, tcPipeAddr); !errors.Is(err, ErrAddressNotFound) {
+		t.Error("success dial to not exist listener")
+		return
+	}
+
+	listener, err := transport.Listen(ctx, tcPipeAddr)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err := transport.Listen(ctx, tcPipeAddr); !errors.Is(err, ErrAddressInUse) {
+		t.Error("success listen already used address")
+		return
+	}
+
+	if listener.Addr().Network() != cNetworkPipe || listener.Addr().String() != tcPipeAddr {
+		t.Error("got invalid listener address")
+		return
+	}
+
+	if err := testEcho(ctx, transport, listener, tcPipeAddr); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := listener.Close(); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := listener.Close(); err == nil {
+		t.Error("success close already closed listener")
+		return
+	}
+	if _, err := listener.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Error("success accept with closed listener")
+		return
+	}
+	if _, err := transport.Dial(ctx, tcPipeAddr); err == nil {
+		t.Error("success dial to closed listener")
+		return
+	}
+
+	// address can be reused after close
+	listener2, err := transport.Listen(ctx, tcPipeAddr)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer listener2.Close()
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	// nobody accepts connection
+	if _, err := transport.Dial(cancelCtx, tcPipeAddr); err == nil {
+		t.Error("success dial with canceled context")
+		return
+	}
+}
+
+func TestTCPTransport(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	transport := NewTCPTransport()
+
+	listener, err := transport.Listen(ctx, testutils.TgAddrs[18])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer listener.Close()
+
+	if _, err := transport.Listen(ctx, testutils.TgAddrs[18]); err == nil {
+		t.Error("success listen already used address")
+		return
+	}
+
+	if err := testEcho(ctx, transport, listener, testutils.TgAddrs[18]); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err := transport.Dial(ctx, "INVALID_ADDRESS"); err == nil {
+		t.Error("success dial to invalid address")
+		return
+	}
+}
+
+func TestUnixTransport(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	transport := NewUnixTransport()
+
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("transport_test_%d.sock", time.Now().UnixNano()))
+	defer os.Remove(path)
+
+	listener, err := transport.Listen(ctx, path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer listener.Close()
+
+	if err := testEcho(ctx, transport, listener, path); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// each accepted connection has the unique remote address
+	addrs := make(map[string]struct{}, 2)
+	for i := 0; i < 2; i++ {
+		conn, err := transport.Dial(ctx, path)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		acceptedConn, err := listener.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer acceptedConn.Close()
+
+		addrs[acceptedConn.RemoteAddr().String()] = struct{}{}
+	}
+	if len(addrs) != 2 {
+		t.Error("accepted connections have the same remote address")
+		return
+	}
+}
+
+func testEcho(pCtx context.Context, pTransport ITransport, pListener net.Listener, pAddr string) error {
+	go func() {
+		conn, err := pListener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		buf := make([]byte, len(tcMessage))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		_, _ = conn.Write(buf)
+	}()
+
+	conn, err := pTransport.Dial(pCtx, pAddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(tcMessage)); err != nil {
+		return err
+	}
+
+	buf := make([]byte, len(tcMessage))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(buf, []byte(tcMessage)) {
+		return errors.New("got invalid echo message") // nolint: err113
+	}
+	return nil
+}
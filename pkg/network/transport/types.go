@@ -0,0 +1,19 @@
+package transport
+
+import (
+	"context"
+	"net"
+)
+
+type ITransport interface {
+	IListener
+	IDialer
+}
+
+type IListener interface {
+	Listen(context.Context, string) (net.Listener, error)
+}
+
+type IDialer interface {
+	Dial(context.Context, string) (net.Conn, error)
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/number571/go-peer/pkg/message/layer1"
 	"github.com/number571/go-peer/pkg/network/conn"
+	"github.com/number571/go-peer/pkg/network/ratelimit"
+	"github.com/number571/go-peer/pkg/network/transport"
 	"github.com/number571/go-peer/pkg/storage/cache"
 	"github.com/number571/go-peer/pkg/types"
 )
@@ -17,14 +19,44 @@ type IHandlerF func(
 	layer1.IMessage,
 ) error
 
+type (
+	IEventType uint8
+)
+
+const (
+	CEventConnectedInbound IEventType = iota + 1
+	CEventConnectedOutbound
+	CEventDisconnected
+	CEventWriteTimeout
+	CEventProtocolError
+	CEventPeerBanned
+)
+
+type IEventF func(IEvent)
+
+type IEvent interface {
+	GetType() IEventType
+	GetAddress() string
+	GetError() error
+}
+
+type IConnStats interface {
+	conn.IStats
+	GetDuplicates() uint64
+}
+
 type INode interface {
 	types.IRunner
 	HandleFunc(uint32, IHandlerF) INode
+	SubscribeEvents(IEventF) func()
 
 	GetSettings() ISettings
 	GetCacheSetter() cache.ICacheSetter
 
 	GetConnections() map[string]conn.IConn
+	GetStats() map[string]IConnStats
+	GetBanList() map[string]time.Time
+	DelBan(string) error
 	AddConnection(context.Context, string) error
 	DelConnection(string) error
 
@@ -33,8 +65,25 @@ type INode interface {
 
 type ISettings interface {
 	GetConnSettings() conn.ISettings
+	GetTransport() transport.ITransport
 	GetAddress() string
 	GetMaxConnects() uint64
+	GetMaxInbound() uint64
+	GetMaxOutbound() uint64
+	GetAllowList() []string
 	GetReadTimeout() time.Duration
 	GetWriteTimeout() time.Duration
+	GetBanThreshold() uint64
+	GetBanDuration() time.Duration
+	GetMaxPending() uint64
+	GetHandshakeTimeout() time.Duration
+	GetReadLimit() ratelimit.ISettings
+	GetWriteLimit() ratelimit.ISettings
+	GetConnReadLimit() ratelimit.ISettings
+	GetConnWriteLimit() ratelimit.ISettings
+	GetPriorityQueueSize() uint64
+	GetGossipFanout() uint64
+	GetGossipLazyPush() bool
+	GetGossipCacheSize() uint64
+	GetGossipControlLimit() ratelimit.ISettings
 }